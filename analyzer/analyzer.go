@@ -1,6 +1,8 @@
 package analyzer
 
 import (
+	"bytes"
+	"io"
 	"sys/fetch/base"
 	"net/http"
 	mdw "sys/fetch/middleware"
@@ -21,11 +23,41 @@ type Analyzer interface {
 }
 
 //被用于解析HTTP响应的函数类型
+//响应所对应的请求的元数据可以通过base.MetaOf(httpResp.Request)获得
 type ParseResponse func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error)
 
 // 分析器的实现类型
 type myAnalyzer struct {
-	id uint32 //ID.
+	id         uint32             //ID.
+	directives Directives         //遵守的页面指令
+	duplicates NearDuplicateIndex //近似重复页面的索引。为nil时不检测近似重复的页面
+	named      NamedParsers       //请求可以通过名称引用的响应解析函数实例
+}
+
+//分析器选项的函数类型
+type AnalyzerOption func(analyzer *myAnalyzer)
+
+//设定分析器遵守的页面指令
+func WithDirectives(directives Directives) AnalyzerOption {
+	return func(analyzer *myAnalyzer) {
+		analyzer.directives = directives
+	}
+}
+
+//设定近似重复页面的索引。与索引中的页面近似重复的页面不会被解析，
+//它们的响应的元数据中会记录base.META_DUPLICATE_OF。同一爬取中的分析器应共用一个索引
+func WithNearDuplicateIndex(index NearDuplicateIndex) AnalyzerOption {
+	return func(analyzer *myAnalyzer) {
+		analyzer.duplicates = index
+	}
+}
+
+//设定请求可以通过名称引用的响应解析函数实例。
+//请求指定的名称不在其中时，分析器会从注册表中获得不带参数的响应解析函数
+func WithNamedParsers(parsers NamedParsers) AnalyzerOption {
+	return func(analyzer *myAnalyzer) {
+		analyzer.named = parsers
+	}
 }
 
 // ID生成器。
@@ -37,8 +69,14 @@ func genAnalyzerId() uint32 {
 }
 
 //创建分析器
-func NewAnalyzer() Analyzer {
-	return &myAnalyzer{id :genAnalyzerId()}
+func NewAnalyzer(options ...AnalyzerOption) Analyzer {
+	analyzer := &myAnalyzer{id :genAnalyzerId()}
+	for _, option := range options {
+		if option != nil {
+			option(analyzer)
+		}
+	}
+	return analyzer
 }
 
 func (analyzer *myAnalyzer) Analyze(
@@ -58,20 +96,48 @@ func (analyzer *myAnalyzer) Analyze(
 	var reqUrl *url.URL = httpResp.Request.URL
 	logger.Infof("Parse the response (reqUrl=%s ...\n)", reqUrl)
 
+	parsers, err := selectParsers(respParsers, resp, analyzer.named)
+	if err != nil {
+		return nil, []error{err}
+	}
+	//响应体只会被读取一次，每个解析函数得到的都是它的一个副本
+	body, err := readBody(httpResp)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	//页面指令在解析之前被读取，规范URL因此可以通过请求的元数据被解析函数获得
+	directives := readDirectives(httpResp, body, analyzer.directives)
+	if directives.canonical != "" {
+		resp.SetMeta(base.META_CANONICAL, directives.canonical)
+	}
+	if analyzer.duplicates != nil {
+		if fingerprint, ok := responseFingerprint(httpResp, body); ok {
+			if dupOf, duplicate := analyzer.duplicates.CheckAndAdd(fingerprint, reqUrl.String()); duplicate {
+				logger.Infof("Skip the near-duplicate page of %s (reqUrl=%s)\n", dupOf, reqUrl)
+				resp.SetMeta(base.META_DUPLICATE_OF, dupOf)
+				return []base.Data{}, []error{}
+			}
+		}
+	}
+
 	respDepth := resp.Depth()
 	dataList = make([]base.Data, 0)
 	errorList = make([]error, 0)
-	for i, respParser := range respParsers {
+	for i, respParser := range parsers {
 		if respParser == nil {
 			err := errors.New(fmt.Sprintf("The document parser [%d] is invalid!", i))
 			errorList = append(errorList, err)
 			continue
 		}
 
-		pDataList, pErrorList := respParser(httpResp, respDepth)
+		pDataList, pErrorList := respParser(withBody(httpResp, body), respDepth)
 		if pDataList != nil {
 			for _, pData := range pDataList {
-				dataList = appendDataList(dataList, pData, respDepth)
+				if !directives.accept(pData) {
+					continue
+				}
+				dataList = appendDataList(dataList, pData, resp)
 			}
 		}
 
@@ -85,7 +151,47 @@ func (analyzer *myAnalyzer) Analyze(
 	return dataList, errorList
 }
 
-func appendDataList(dataList []base.Data, data base.Data, respDepth uint32) []base.Data {
+//选择解析响应所用的解析函数。
+//若响应所对应的请求指定了解析函数的名称，则从具名的实例或注册表中获得它们，否则使用给定的解析函数
+func selectParsers(respParsers []ParseResponse, resp base.Response, named NamedParsers) ([]ParseResponse, error) {
+	names := resp.Parsers()
+	if len(names) == 0 {
+		return respParsers, nil
+	}
+	parsers := make([]ParseResponse, 0, len(names))
+	for _, name := range names {
+		parser, err := named.Get(name)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid response parser for the request (reqUrl=%s): %s",
+				resp.HttpResp().Request.URL, err))
+		}
+		parsers = append(parsers, parser)
+	}
+	return parsers, nil
+}
+
+//读取并关闭响应体
+func readBody(httpResp *http.Response) ([]byte, error) {
+	if httpResp.Body == nil {
+		return nil, nil
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Can not read the response body (reqUrl=%s): %s",
+			httpResp.Request.URL, err))
+	}
+	return body, nil
+}
+
+//生成以给定内容为响应体的响应的副本
+func withBody(httpResp *http.Response, body []byte) *http.Response {
+	respCopy := *httpResp
+	respCopy.Body = io.NopCloser(bytes.NewReader(body))
+	return &respCopy
+}
+
+func appendDataList(dataList []base.Data, data base.Data, resp base.Response) []base.Data {
 	if data == nil {
 		return dataList
 	}
@@ -94,14 +200,32 @@ func appendDataList(dataList []base.Data, data base.Data, respDepth uint32) []ba
 	if !ok {
 		return append(dataList, data)
 	}
-	newDepth := respDepth + 1
+	inheritMeta(req, resp)
+	newDepth := resp.Depth() + 1
+	if req.Meta().Bool(base.META_SEED) {
+		newDepth = 0
+	}
 	if req.Depth() != newDepth {
-		req = base.NewRequest(req.HttpReq(), newDepth)
+		req = base.NewRequestWithMeta(req.HttpReq(), newDepth, req.Meta())
 	}
 	return append(dataList, req)
 
 }
 
+//使新请求继承响应的来源信息。新请求中已有的值不会被覆盖
+func inheritMeta(req *base.Request, resp base.Response) {
+	if _, ok := req.Meta().Get(base.META_REFERRER); !ok {
+		if httpResp := resp.HttpResp(); httpResp != nil && httpResp.Request != nil {
+			req.SetMeta(base.META_REFERRER, httpResp.Request.URL.String())
+		}
+	}
+	if _, ok := req.Meta().Get(base.META_SEED_ID); !ok {
+		if seedId, ok := resp.Meta().Get(base.META_SEED_ID); ok {
+			req.SetMeta(base.META_SEED_ID, seedId)
+		}
+	}
+}
+
 func appendErrorList(errorList []error, err error) []error {
 	if err == nil {
 		return errorList
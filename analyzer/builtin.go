@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"sys/fetch/base"
+)
+
+// 注册内置的响应解析函数。
+func init() {
+	Register("a-tags", ParseForATag)
+}
+
+// 只解析"A"标签的响应解析函数。
+// 它会为每个链接生成一个请求，并为每个带文本的链接生成一个条目。
+func ParseForATag(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != 200 {
+		err := errors.New(
+			fmt.Sprintf("Unsupported status code %d. (url=%s)",
+				httpResp.StatusCode, httpResp.Request.URL))
+		return nil, []error{err}
+	}
+
+	var reqUrl *url.URL = httpResp.Request.URL
+	dataList := make([]base.Data, 0)
+	errs := make([]error, 0)
+
+	doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+	if err != nil {
+		errs = append(errs, err)
+		return dataList, errs
+	}
+
+	doc.Find("a").Each(func(index int, sel *goquery.Selection) {
+		href, exists := sel.Attr("href")
+		href = strings.TrimSpace(href)
+		if !exists || href == "" || href == "#" || href == "/" {
+			return
+		}
+		text := strings.TrimSpace(sel.Text())
+		//暂不支持对javascript代码的解析
+		if !strings.HasPrefix(strings.ToLower(href), "javascript") {
+			aUrl, err := url.Parse(href)
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			aUrl = reqUrl.ResolveReference(aUrl)
+			httpReq, err := http.NewRequest("GET", aUrl.String(), nil)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				req := base.NewRequest(httpReq, respDepth)
+				if text != "" {
+					req.SetMeta(base.META_ANCHOR_TEXT, text)
+				}
+				dataList = append(dataList, req)
+			}
+		}
+		if text != "" {
+			item := base.Item{
+				"a.text":     text,
+				"parent_url": reqUrl,
+			}
+			dataList = append(dataList, &item)
+		}
+	})
+	return dataList, errs
+}
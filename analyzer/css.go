@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+
+	"sys/fetch/base"
+)
+
+// 注册内置的CSS选择器条目提取器。
+func init() {
+	RegisterFactory("css-items", func(params base.Params) (ParseResponse, error) {
+		ruleSet, err := ruleSetFromParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return NewCssExtractor(ruleSet)
+	})
+}
+
+// 创建以CSS选择器描述规则的条目提取器。它是一个响应解析函数，
+// 会根据规则从HTML页面中提取条目和需要跟随的请求，条目的类型被记录在base.ITEM_TYPE_KEY字段中。
+func NewCssExtractor(ruleSet RuleSet) (ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	argsErr.Add("", ruleSet.Check())
+	argsErr.Add("", ruleSet.checkSelectors(checkCssSelector))
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	follow := make([]FollowRule, len(ruleSet.Follow))
+	for i, rule := range ruleSet.Follow {
+		if rule.Attr == "" {
+			rule.Attr = "href"
+		}
+		follow[i] = rule
+	}
+	ruleSet.Follow = follow
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		if mediaType := mediaTypeOf(httpResp); mediaType != "" && !isHtml(mediaType) {
+			return nil, nil
+		}
+		doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		pageUrl := httpResp.Request.URL
+		return extractByRules(&cssDocument{doc: doc}, &ruleSet,
+			pageUrl, documentBaseUrl(doc, pageUrl), respDepth)
+	}, nil
+}
+
+// 检查CSS选择器的有效性。
+func checkCssSelector(selector string) error {
+	if _, err := cascadia.ParseGroup(selector); err != nil {
+		return errors.New(fmt.Sprintf("Invalid CSS selector '%s': %s", selector, err))
+	}
+	return nil
+}
+
+// 基于goquery的文档。
+type cssDocument struct {
+	doc *goquery.Document
+}
+
+func (cd *cssDocument) scopes(selector string) []ruleNode {
+	if selector == "" {
+		return []ruleNode{&cssNode{sel: cd.doc.Selection}}
+	}
+	nodes := make([]ruleNode, 0)
+	cd.doc.Find(selector).Each(func(index int, sel *goquery.Selection) {
+		nodes = append(nodes, &cssNode{sel: sel})
+	})
+	return nodes
+}
+
+// 基于goquery的文档节点。
+type cssNode struct {
+	sel *goquery.Selection
+}
+
+func (cn *cssNode) values(selector string, attr string) []interface{} {
+	target := cn.sel
+	if selector != "" {
+		target = cn.sel.Find(selector)
+	}
+	values := make([]interface{}, 0, target.Length())
+	target.Each(func(index int, sel *goquery.Selection) {
+		if attr == "" {
+			values = append(values, strings.Join(strings.Fields(sel.Text()), " "))
+			return
+		}
+		if value, exists := sel.Attr(attr); exists {
+			values = append(values, value)
+		}
+	})
+	return values
+}
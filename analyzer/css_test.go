@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+const cssTestPage = `<html><body>
+<div class="product">
+  <h2>Tea <small>green</small></h2>
+  <span class="price">Price: 1,299.50</span>
+  <time datetime="2024-03-01">March 1</time>
+  <a class="more" href="/p/1">More</a>
+  <ul><li>a</li><li>b</li></ul>
+</div>
+<div class="product">
+  <h2>Coffee</h2>
+  <span class="price">n/a</span>
+</div>
+</body></html>`
+
+func TestCssExtractor(t *testing.T) {
+	parser, err := NewParser("css-items", base.Params{
+		"items": []interface{}{map[string]interface{}{
+			"type":  "product",
+			"scope": "div.product",
+			"fields": map[string]interface{}{
+				"name":  "h2",
+				"price": map[string]interface{}{"selector": ".price", "regex": `([\d,.]+)`, "type": "float", "required": true},
+				"date":  map[string]interface{}{"selector": "time", "attr": "datetime", "type": "date"},
+				"url":   map[string]interface{}{"selector": "a.more", "attr": "href", "type": "url"},
+				"tags":  map[string]interface{}{"selector": "li", "list": true},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Can not create the extractor: %s", err)
+	}
+	resp := newTestResponse("http://example.com/shop/", "text/html", cssTestPage, nil)
+	dataList, errs := parser(resp.HttpResp(), 0)
+	if len(dataList) != 1 {
+		t.Fatalf("Unexpected data list %v!", dataList)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected an error for the item without a price, but got %v!", errs)
+	}
+	expected := base.Item{
+		base.ITEM_TYPE_KEY: "product",
+		"name":             "Tea green",
+		"price":            1299.5,
+		"date":             time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"url":              "http://example.com/p/1",
+		"tags":             []interface{}{"a", "b"},
+	}
+	if item := *dataList[0].(*base.Item); !reflect.DeepEqual(item, expected) {
+		t.Errorf("Unexpected item %v!\nexpected: %v", item, expected)
+	}
+
+	_, err = NewParser("css-items", base.Params{
+		"items": []interface{}{map[string]interface{}{
+			"type":   "product",
+			"fields": map[string]interface{}{"name": "h2[", "price": map[string]interface{}{"type": "money"}},
+		}},
+	})
+	if err == nil {
+		t.Fatalf("Expected an error for the invalid rules!")
+	}
+	if errs := err.(*base.ArgsError).Errors(); len(errs) != 2 {
+		t.Errorf("Expected 2 errors for the invalid rules, but got %v!", errs)
+	}
+}
@@ -0,0 +1,137 @@
+package analyzer
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"sys/fetch/base"
+)
+
+// 分析器遵守的页面指令的开关。零值表示不遵守任何指令。
+type Directives struct {
+	Noindex   bool // 是否遵守noindex。页面带有noindex时，解析函数生成的条目会被丢弃。
+	Nofollow  bool // 是否遵守nofollow。页面带有nofollow时，解析函数生成的请求会被丢弃。
+	Canonical bool // 是否识别rel=canonical。页面的规范URL会被记录在响应的元数据(base.META_CANONICAL)中。
+}
+
+// 页面中的指令。
+type pageDirectives struct {
+	noindex   bool   // 页面是否带有noindex。
+	nofollow  bool   // 页面是否带有nofollow。
+	canonical string // 页面的规范URL。
+}
+
+// 可以带有冒号的robots指令。其他以冒号分隔的前缀被认为是爬虫的名称。
+var robotsValueDirectives = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
+
+// 获得响应中的页面指令。指令来自X-Robots-Tag和Link响应头，以及HTML页面中的
+// <meta name="robots">和<link rel="canonical">。未启用的指令不会被读取。
+func readDirectives(httpResp *http.Response, body []byte, directives Directives) pageDirectives {
+	result := pageDirectives{}
+	if !directives.Noindex && !directives.Nofollow && !directives.Canonical {
+		return result
+	}
+	for _, value := range httpResp.Header.Values("X-Robots-Tag") {
+		result.addRobots(value)
+	}
+	if directives.Canonical {
+		result.canonical = canonicalFromLinkHeader(httpResp)
+	}
+	if mediaType := mediaTypeOf(httpResp); isHtml(mediaType) || (mediaType == "" && looksLikeHtml(body)) {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err == nil {
+			doc.Find("meta[name][content]").Each(func(index int, sel *goquery.Selection) {
+				if name, _ := sel.Attr("name"); strings.EqualFold(strings.TrimSpace(name), "robots") {
+					content, _ := sel.Attr("content")
+					result.addRobots(content)
+				}
+			})
+			if directives.Canonical {
+				doc.Find("link[href]").EachWithBreak(func(index int, sel *goquery.Selection) bool {
+					if !hasRel(sel, "canonical") {
+						return true
+					}
+					href, _ := sel.Attr("href")
+					if link, ok := resolveLink(documentBaseUrl(doc, httpResp.Request.URL), href); ok {
+						result.canonical = link
+					}
+					return false
+				})
+			}
+		}
+	}
+	result.noindex = result.noindex && directives.Noindex
+	result.nofollow = result.nofollow && directives.Nofollow
+	return result
+}
+
+// 加入robots指令。针对特定爬虫的指令(如"googlebot: noindex")会被忽略。
+func (pd *pageDirectives) addRobots(value string) {
+	agent := ""
+	for _, token := range strings.Split(strings.ToLower(value), ",") {
+		token = strings.TrimSpace(token)
+		if colon := strings.Index(token, ":"); colon >= 0 {
+			if name := strings.TrimSpace(token[:colon]); !robotsValueDirectives[name] {
+				agent, token = name, strings.TrimSpace(token[colon+1:])
+			}
+		}
+		if agent != "" && agent != "robots" {
+			continue
+		}
+		switch token {
+		case "noindex":
+			pd.noindex = true
+		case "nofollow":
+			pd.nofollow = true
+		case "none":
+			pd.noindex, pd.nofollow = true, true
+		}
+	}
+}
+
+// 获得Link响应头中的规范URL。
+func canonicalFromLinkHeader(httpResp *http.Response) string {
+	for _, header := range httpResp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.ToLower(strings.Trim(value, `" `))) {
+					if rel != "canonical" {
+						continue
+					}
+					if canonical, ok := resolveLink(httpResp.Request.URL, target[1:len(target)-1]); ok {
+						return canonical
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// 按照页面指令过滤解析函数生成的数据。
+func (pd *pageDirectives) accept(data base.Data) bool {
+	switch data.(type) {
+	case *base.Item:
+		return !pd.noindex
+	case *base.Request:
+		return !pd.nofollow
+	}
+	return true
+}
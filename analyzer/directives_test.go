@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"net/http"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+// 生成同时返回一个条目和一个请求的解析函数。条目中记录了解析时请求的元数据中的规范URL。
+func genItemAndLinkParser() ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		item := base.Item{"canonical": base.MetaOf(httpResp.Request).String(base.META_CANONICAL)}
+		httpReq, _ := http.NewRequest("GET", "http://example.com/next", nil)
+		return []base.Data{&item, base.NewRequest(httpReq, respDepth+1)}, nil
+	}
+}
+
+// 统计数据列表中的条目和请求。
+func countData(dataList []base.Data, errs []error) (items int, reqs int) {
+	for _, data := range dataList {
+		switch data.(type) {
+		case *base.Item:
+			items++
+		case *base.Request:
+			reqs++
+		}
+	}
+	return
+}
+
+func TestAnalyzeDirectives(t *testing.T) {
+	parsers := []ParseResponse{genItemAndLinkParser()}
+	all := NewAnalyzer(WithDirectives(Directives{Noindex: true, Nofollow: true, Canonical: true}))
+	cases := []struct {
+		analyzer Analyzer
+		header   http.Header
+		body     string
+		items    int
+		reqs     int
+	}{
+		{all, nil, `<html><head><meta name="robots" content="noindex"></head></html>`, 0, 1},
+		{all, nil, `<html><head><meta name="ROBOTS" content="none"></head></html>`, 0, 0},
+		{all, http.Header{"X-Robots-Tag": {"nofollow"}}, `<html></html>`, 1, 0},
+		{all, http.Header{"X-Robots-Tag": {"otherbot: noindex, nofollow"}}, `<html></html>`, 1, 1},
+		{all, http.Header{"X-Robots-Tag": {"unavailable_after: 2030-01-01, noindex"}}, `<html></html>`, 0, 1},
+		{NewAnalyzer(), http.Header{"X-Robots-Tag": {"none"}}, `<html></html>`, 1, 1},
+		{NewAnalyzer(WithDirectives(Directives{Nofollow: true})),
+			nil, `<html><head><meta name="robots" content="noindex, nofollow"></head></html>`, 1, 0},
+	}
+	for i, c := range cases {
+		resp := newTestResponse("http://example.com/page", "text/html", c.body, base.Metadata{})
+		for k, v := range c.header {
+			resp.HttpResp().Header[k] = v
+		}
+		items, reqs := countData(c.analyzer.Analyze(parsers, resp))
+		if items != c.items || reqs != c.reqs {
+			t.Errorf("Unexpected %d items and %d requests for case %d, expected %d and %d!",
+				items, reqs, i, c.items, c.reqs)
+		}
+	}
+}
+
+func TestAnalyzeCanonical(t *testing.T) {
+	parsers := []ParseResponse{genItemAndLinkParser()}
+	analyzer := NewAnalyzer(WithDirectives(Directives{Canonical: true}))
+	meta := base.Metadata{}
+	resp := newTestResponse("http://example.com/page?utm=x", "text/html",
+		`<html><head><base href="/docs/"><link rel="canonical" href="page#top"></head></html>`, meta)
+	dataList, _ := analyzer.Analyze(parsers, resp)
+	if canonical := resp.Meta().String(base.META_CANONICAL); canonical != "http://example.com/docs/page" {
+		t.Errorf("Unexpected canonical url %q!", canonical)
+	}
+	if item := *dataList[0].(*base.Item); item["canonical"] != "http://example.com/docs/page" {
+		t.Errorf("The canonical url is not visible to the parser: %v!", item)
+	}
+
+	meta = base.Metadata{}
+	resp = newTestResponse("http://example.com/file.pdf", "application/pdf", "%PDF", meta)
+	resp.HttpResp().Header.Set("Link", `<http://example.com/a>; rel="preload", </file>; rel="canonical"`)
+	analyzer.Analyze(parsers, resp)
+	if canonical := resp.Meta().String(base.META_CANONICAL); canonical != "http://example.com/file" {
+		t.Errorf("Unexpected canonical url %q from the Link header!", canonical)
+	}
+
+	meta = base.Metadata{}
+	resp = newTestResponse("http://example.com/page", "text/html", `<link rel="canonical" href="/other">`, meta)
+	NewAnalyzer().Analyze(parsers, resp)
+	if _, ok := resp.Meta().Get(base.META_CANONICAL); ok {
+		t.Errorf("The canonical url should not be recorded when it is disabled!")
+	}
+}
@@ -0,0 +1,344 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+
+	"sys/fetch/base"
+)
+
+// 订阅源条目的类型。
+const FEED_ENTRY_TYPE = "feed_entry"
+
+// 订阅源解析函数的注册名称。
+const FEED_PARSER = "feed"
+
+// 注册内置的订阅源解析函数。
+func init() {
+	RegisterFactory(FEED_PARSER, newFeedParserFromParams)
+}
+
+// 订阅源解析函数的选项。
+type FeedOptions struct {
+	FollowLinks bool     // 是否为每个条目的链接生成请求，以便提取全文。
+	Parsers     []string // 条目链接的请求所指定的解析函数的名称。
+	Discover    bool     // 是否为HTML页面中的订阅源自动发现链接生成请求。
+	FeedParser  string   // 发现的订阅源的请求所指定的解析函数的名称。为空时为FEED_PARSER。
+}
+
+// 检查订阅源解析函数的选项的有效性。
+func (options *FeedOptions) Check() error {
+	argsErr := base.NewArgsError()
+	//名称在分析时才从具名的解析函数实例或注册表中获得，这里只能检查它是否为空
+	for i, name := range options.Parsers {
+		if name == "" {
+			argsErr.Add(fmt.Sprintf("parsers[%d]", i),
+				errors.New("The response parser name can not be empty!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 创建订阅源解析函数。它支持RSS 2.0、RSS 1.0(RDF)和Atom，
+// 每个条目会被转换为类型为FEED_ENTRY_TYPE的条目，其中包括title、link、published、
+// author、summary和guid字段，以及订阅源的feed和feed_title字段。
+// published能被解析时是time.Time，否则是原始的字符串；summary中的HTML标签会被去掉。
+func NewFeedParser(options FeedOptions) (ParseResponse, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		body, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		pageUrl := httpResp.Request.URL
+		mediaType := mediaTypeOf(httpResp)
+		if isHtml(mediaType) || (mediaType == "" && looksLikeHtml(body)) {
+			if !options.Discover {
+				return nil, nil
+			}
+			feedParser := options.FeedParser
+			if feedParser == "" {
+				feedParser = FEED_PARSER
+			}
+			return discoverFeeds(body, pageUrl, respDepth, feedParser)
+		}
+		feed, err := decodeFeed(body)
+		if err != nil {
+			return nil, []error{errors.New(fmt.Sprintf(
+				"Can not parse the feed (url=%s): %s", pageUrl, err))}
+		}
+		if feed == nil {
+			return nil, nil
+		}
+		return feed.dataList(pageUrl, respDepth, &options)
+	}, nil
+}
+
+// 根据内容判断文档是否为HTML。
+func looksLikeHtml(body []byte) bool {
+	head := bytes.ToLower(bytes.TrimSpace(body))
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.Contains(head, []byte("<html"))
+}
+
+// 订阅源的内容类型。
+var feedMediaTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+	"application/xml":      true,
+	"text/xml":             true,
+}
+
+// 为HTML页面中的订阅源自动发现链接生成请求。请求指定名称为feedParser的解析函数，
+// 这样订阅源不会被默认的(通常是解析HTML的)解析函数解析。
+func discoverFeeds(body []byte, pageUrl *url.URL, respDepth uint32, feedParser string) ([]base.Data, []error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, []error{err}
+	}
+	baseUrl := documentBaseUrl(doc, pageUrl)
+	dataList := make([]base.Data, 0)
+	errs := make([]error, 0)
+	seen := make(map[string]bool)
+	doc.Find("link[href]").Each(func(index int, sel *goquery.Selection) {
+		feedType, _ := sel.Attr("type")
+		if !hasRel(sel, "alternate") || !feedMediaTypes[strings.ToLower(strings.TrimSpace(feedType))] {
+			return
+		}
+		href, _ := sel.Attr("href")
+		link, ok := resolveLink(baseUrl, href)
+		if !ok || seen[link] {
+			return
+		}
+		seen[link] = true
+		httpReq, err := http.NewRequest("GET", link, nil)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		req := base.NewRequest(httpReq, respDepth+1)
+		req.SetParsers(feedParser)
+		if title, _ := sel.Attr("title"); title != "" {
+			req.SetMeta(base.META_ANCHOR_TEXT, strings.TrimSpace(title))
+		}
+		dataList = append(dataList, req)
+	})
+	return dataList, errs
+}
+
+// 订阅源文档。元素按本地名称匹配，因此同一结构可以容纳RSS 2.0、RSS 1.0和Atom。
+type feedDocument struct {
+	XMLName xml.Name
+	Title   string `xml:"title"` // Atom的标题。
+	Channel struct {
+		Title string      `xml:"title"`
+		Items []feedEntry `xml:"item"` // RSS 2.0的条目。
+	} `xml:"channel"`
+	Items   []feedEntry `xml:"item"`  // RSS 1.0的条目。
+	Entries []feedEntry `xml:"entry"` // Atom的条目。
+}
+
+// 订阅源中的条目。
+type feedEntry struct {
+	Title       string       `xml:"title"`
+	Links       []feedLink   `xml:"link"`
+	Guid        string       `xml:"guid"`
+	Id          string       `xml:"id"`
+	About       string       `xml:"about,attr"`
+	PubDate     string       `xml:"pubDate"`
+	Date        string       `xml:"date"`
+	Published   string       `xml:"published"`
+	Updated     string       `xml:"updated"`
+	Authors     []feedAuthor `xml:"author"`
+	Creator     string       `xml:"creator"`
+	Description string       `xml:"description"`
+	Summary     string       `xml:"summary"`
+	Content     string       `xml:"content"`
+	Encoded     string       `xml:"encoded"`
+}
+
+// 订阅源中的链接。RSS的链接是元素的文本，Atom的链接是href属性。
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// 订阅源中的作者。RSS的作者是元素的文本，Atom的作者是name子元素。
+type feedAuthor struct {
+	Name string `xml:"name"`
+	Text string `xml:",chardata"`
+}
+
+// 解码订阅源。文档不是订阅源时返回nil。
+func decodeFeed(body []byte) (*feedDocument, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	feed := &feedDocument{}
+	if err := decoder.Decode(feed); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(feed.XMLName.Local) {
+	case "rss", "rdf", "feed":
+		return feed, nil
+	}
+	return nil, nil
+}
+
+// 生成订阅源中的条目和需要跟随的请求。
+func (feed *feedDocument) dataList(
+	feedUrl *url.URL, respDepth uint32, options *FeedOptions) ([]base.Data, []error) {
+	title := firstNonEmpty(feed.Channel.Title, feed.Title)
+	entries := feed.Entries
+	entries = append(entries, feed.Channel.Items...)
+	entries = append(entries, feed.Items...)
+	dataList := make([]base.Data, 0, len(entries))
+	errs := make([]error, 0)
+	seen := make(map[string]bool)
+	for i := range entries {
+		item := entries[i].item(feedUrl)
+		item["feed_title"] = strings.TrimSpace(title)
+		dataList = append(dataList, &item)
+		link, _ := item["link"].(string)
+		if !options.FollowLinks || link == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		httpReq, err := http.NewRequest("GET", link, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req := base.NewRequest(httpReq, respDepth+1)
+		if entryTitle, _ := item["title"].(string); entryTitle != "" {
+			req.SetMeta(base.META_ANCHOR_TEXT, entryTitle)
+		}
+		if len(options.Parsers) > 0 {
+			req.SetParsers(options.Parsers...)
+		}
+		dataList = append(dataList, req)
+	}
+	return dataList, errs
+}
+
+// 把订阅源中的条目转换为规范化的条目。
+func (entry *feedEntry) item(feedUrl *url.URL) base.Item {
+	link := entry.link()
+	if link != "" {
+		if u, err := url.Parse(link); err == nil {
+			link = feedUrl.ResolveReference(u).String()
+		}
+	}
+	item := base.Item{
+		base.ITEM_TYPE_KEY: FEED_ENTRY_TYPE,
+		"feed":             feedUrl.String(),
+		"title":            htmlText(entry.Title),
+		"link":             link,
+		"guid":             strings.TrimSpace(firstNonEmpty(entry.Guid, entry.Id, entry.About, link)),
+		"author":           entry.author(),
+		"summary":          htmlText(firstNonEmpty(entry.Description, entry.Summary, entry.Content, entry.Encoded)),
+	}
+	if published := strings.TrimSpace(
+		firstNonEmpty(entry.PubDate, entry.Published, entry.Date, entry.Updated)); published != "" {
+		if t, err := base.CoerceValue(published, base.TYPE_DATE, "", nil); err == nil {
+			item["published"] = t
+		} else {
+			item["published"] = published
+		}
+	}
+	return item
+}
+
+// 获得条目的链接。Atom中优先使用rel为alternate或为空的链接。
+func (entry *feedEntry) link() string {
+	for _, link := range entry.Links {
+		if link.Href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	for _, link := range entry.Links {
+		if text := strings.TrimSpace(link.Text); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// 获得条目的作者。多个作者以逗号分隔。
+func (entry *feedEntry) author() string {
+	names := make([]string, 0, len(entry.Authors)+1)
+	for _, author := range entry.Authors {
+		if name := strings.TrimSpace(firstNonEmpty(author.Name, author.Text)); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && strings.TrimSpace(entry.Creator) != "" {
+		names = append(names, strings.TrimSpace(entry.Creator))
+	}
+	return strings.Join(names, ", ")
+}
+
+// 获得第一个不为空白的字符串。
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 去掉文本中的HTML标签并合并空白。
+func htmlText(s string) string {
+	if strings.Contains(s, "<") {
+		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(s)); err == nil {
+			s = doc.Text()
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// 根据参数生成订阅源解析函数。参数包括followLinks、parsers、discover和feedParser。
+func newFeedParserFromParams(params base.Params) (ParseResponse, error) {
+	options := FeedOptions{}
+	argsErr := base.NewArgsError()
+	var err error
+	if options.FollowLinks, err = params.Bool("followLinks", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.Parsers, err = params.Strings("parsers"); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.Discover, err = params.Bool("discover", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.FeedParser, err = params.String("feedParser", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewFeedParser(options)
+}
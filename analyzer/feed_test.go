@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+const feedTestRss = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Example News</title>
+    <link>http://example.com/</link>
+    <item>
+      <title>First &amp; foremost</title>
+      <link>/news/1</link>
+      <guid isPermaLink="false">news-1</guid>
+      <pubDate>Tue, 5 Mar 2024 08:30:00 +0000</pubDate>
+      <dc:creator>Ann</dc:creator>
+      <description><![CDATA[<p>Hello <b>world</b></p>]]></description>
+    </item>
+    <item>
+      <title>Second</title>
+      <link>http://example.com/news/2</link>
+      <pubDate>sometime</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+const feedTestRdf = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="http://example.com/"><title>RDF News</title></channel>
+  <item rdf:about="http://example.com/rdf/1">
+    <title>RDF entry</title>
+    <link>http://example.com/rdf/1</link>
+    <dc:date>2024-03-05T08:30:00Z</dc:date>
+  </item>
+</rdf:RDF>`
+
+const feedTestAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom News</title>
+  <entry>
+    <title>Atom entry</title>
+    <link rel="edit" href="http://example.com/edit/1"/>
+    <link rel="alternate" href="http://example.com/atom/1"/>
+    <id>urn:uuid:1</id>
+    <updated>2024-03-05T08:30:00Z</updated>
+    <author><name>Bob</name></author>
+    <author><name>Cid</name></author>
+    <summary>Short summary</summary>
+  </entry>
+</feed>`
+
+// 解析订阅源并返回条目和请求。
+func parseTestFeed(t *testing.T, parser ParseResponse, url string, ct string, body string) ([]base.Item, []*base.Request) {
+	dataList, errs := parser(newTestHttpResponse(url, ct, body, nil), 1)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	items := make([]base.Item, 0)
+	reqs := make([]*base.Request, 0)
+	for _, data := range dataList {
+		switch d := data.(type) {
+		case *base.Item:
+			items = append(items, *d)
+		case *base.Request:
+			reqs = append(reqs, d)
+		}
+	}
+	return items, reqs
+}
+
+func TestFeedParser(t *testing.T) {
+	parser, err := NewFeedParser(FeedOptions{})
+	if err != nil {
+		t.Fatalf("Can not create the feed parser: %s", err)
+	}
+	items, reqs := parseTestFeed(t, parser, "http://example.com/rss.xml", "application/rss+xml", feedTestRss)
+	if len(items) != 2 || len(reqs) != 0 {
+		t.Fatalf("Unexpected items %v and requests %v!", items, reqs)
+	}
+	expected := base.Item{
+		base.ITEM_TYPE_KEY: FEED_ENTRY_TYPE,
+		"feed":             "http://example.com/rss.xml",
+		"feed_title":       "Example News",
+		"title":            "First & foremost",
+		"link":             "http://example.com/news/1",
+		"guid":             "news-1",
+		"author":           "Ann",
+		"summary":          "Hello world",
+		"published":        time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC),
+	}
+	if published, ok := items[0]["published"].(time.Time); ok {
+		items[0]["published"] = published.UTC()
+	}
+	if !reflect.DeepEqual(items[0], expected) {
+		t.Errorf("Unexpected item %v!\nexpected: %v", items[0], expected)
+	}
+	if items[1]["published"] != "sometime" || items[1]["guid"] != "http://example.com/news/2" {
+		t.Errorf("Unexpected item %v!", items[1])
+	}
+
+	items, _ = parseTestFeed(t, parser, "http://example.com/index.rdf", "", feedTestRdf)
+	if len(items) != 1 || items[0]["guid"] != "http://example.com/rdf/1" ||
+		items[0]["feed_title"] != "RDF News" || items[0]["published"] == nil {
+		t.Errorf("Unexpected RDF items %v!", items)
+	}
+
+	items, _ = parseTestFeed(t, parser, "http://example.com/atom.xml", "application/atom+xml", feedTestAtom)
+	if len(items) != 1 || items[0]["link"] != "http://example.com/atom/1" || items[0]["guid"] != "urn:uuid:1" ||
+		items[0]["author"] != "Bob, Cid" || items[0]["summary"] != "Short summary" {
+		t.Errorf("Unexpected Atom items %v!", items)
+	}
+
+	items, _ = parseTestFeed(t, parser, "http://example.com/sitemap.xml", "text/xml", sitemapTestIndex)
+	if len(items) != 0 {
+		t.Errorf("Expected no items for a document that is not a feed, but got %v!", items)
+	}
+}
+
+func TestFeedParserFollowAndDiscover(t *testing.T) {
+	parser, err := NewParser("feed", base.Params{
+		"followLinks": true, "parsers": []interface{}{"a-tags"}, "discover": true,
+	})
+	if err != nil {
+		t.Fatalf("Can not create the feed parser: %s", err)
+	}
+	_, reqs := parseTestFeed(t, parser, "http://example.com/rss.xml", "application/rss+xml", feedTestRss)
+	if len(reqs) != 2 || reqs[0].HttpReq().URL.String() != "http://example.com/news/1" ||
+		reqs[0].Depth() != 2 || !reflect.DeepEqual(reqs[0].Parsers(), []string{"a-tags"}) ||
+		reqs[0].Meta().String(base.META_ANCHOR_TEXT) != "First & foremost" {
+		t.Fatalf("Unexpected entry requests %v!", reqs)
+	}
+
+	page := `<html><head>
+<link rel="alternate" type="application/rss+xml" title="News" href="/rss.xml">
+<link rel="alternate" type="application/atom+xml" href="http://example.com/atom.xml">
+<link rel="alternate" hreflang="de" href="/de/">
+<link rel="stylesheet" type="text/css" href="/style.css">
+</head><body></body></html>`
+	items, reqs := parseTestFeed(t, parser, "http://example.com/", "text/html", page)
+	urls := make([]string, 0)
+	for _, req := range reqs {
+		urls = append(urls, req.HttpReq().URL.String())
+		if !reflect.DeepEqual(req.Parsers(), []string{FEED_PARSER}) {
+			t.Errorf("The discovered feed %s should be parsed by the feed parser, but it names %v!",
+				req.HttpReq().URL, req.Parsers())
+		}
+	}
+	if len(items) != 0 || !reflect.DeepEqual(urls, []string{"http://example.com/rss.xml", "http://example.com/atom.xml"}) {
+		t.Errorf("Unexpected discovered feeds %v!", urls)
+	}
+
+	//发现的订阅源也可以由具名的解析函数实例解析
+	parser, err = NewParser(FEED_PARSER, base.Params{"discover": true, "feedParser": "news-feed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reqs = parseTestFeed(t, parser, "http://example.com/", "text/html", page)
+	if len(reqs) != 2 || !reflect.DeepEqual(reqs[0].Parsers(), []string{"news-feed"}) {
+		t.Errorf("Unexpected discovered feed requests %v!", reqs)
+	}
+
+	//名称在分析时才被解析，因此只有空的名称会被拒绝
+	if _, err := NewFeedParser(FeedOptions{Parsers: []string{""}}); err == nil {
+		t.Errorf("Expected an error for the empty parser name!")
+	}
+	if _, err := NewFeedParser(FeedOptions{Parsers: []string{"configured-parser"}}); err != nil {
+		t.Errorf("The parser name should be resolved when analyzing, but got %s!", err)
+	}
+}
@@ -0,0 +1,368 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"sys/fetch/base"
+)
+
+// 注册内置的JSON解析函数。
+func init() {
+	RegisterFactory("json", newJsonParserFromParams)
+}
+
+// 翻页规则。以下三种方式只能选择一种：
+// 由NextUrl给出下一页的URL；由Cursor给出游标并把它设置到CursorParam查询参数中；
+// 把PageParam查询参数中的页码加1，直到某页中不再有条目为止。
+type Pagination struct {
+	NextUrl      string // 下一页的URL的JSONPath表达式。URL可以是相对的。
+	Cursor       string // 游标的JSONPath表达式。游标为空或不存在时停止翻页。
+	CursorParam  string // 放置游标的查询参数。
+	PageParam    string // 放置页码的查询参数。
+	FirstPage    int    // URL中没有页码时当前页的页码。默认为1。
+	MaxPages     int    // 最多爬取的页数，包括第一页。为0时不限制。
+	RespectDepth bool   // 翻页产生的请求是否受爬取深度的限制。默认不受限制。
+}
+
+// 检查翻页规则的有效性。
+func (pagination *Pagination) Check() error {
+	argsErr := base.NewArgsError()
+	modes := 0
+	if pagination.NextUrl != "" {
+		modes++
+		if _, err := compileJsonPath(pagination.NextUrl); err != nil {
+			argsErr.Add("nextUrl", err)
+		}
+	}
+	if pagination.Cursor != "" || pagination.CursorParam != "" {
+		modes++
+		if pagination.Cursor == "" || pagination.CursorParam == "" {
+			argsErr.Add("cursor", errors.New("Both the cursor and the cursorParam are required!"))
+		} else if _, err := compileJsonPath(pagination.Cursor); err != nil {
+			argsErr.Add("cursor", err)
+		}
+	}
+	if pagination.PageParam != "" {
+		modes++
+	}
+	if modes != 1 {
+		argsErr.Add("", errors.New(
+			"Exactly one of the nextUrl, cursor and pageParam should be specified!"))
+	}
+	if pagination.MaxPages < 0 {
+		argsErr.Add("maxPages", errors.New("The max pages can not be negative!"))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 生成下一页的请求。没有下一页时返回nil。
+func (pagination *Pagination) next(
+	root interface{}, httpResp *http.Response, respDepth uint32, hasItems bool) (*base.Request, error) {
+	reqMeta := base.MetaOf(httpResp.Request)
+	page := reqMeta.Int(base.META_PAGE) + 1
+	if pagination.MaxPages > 0 && page >= pagination.MaxPages {
+		return nil, nil
+	}
+	pageUrl := httpResp.Request.URL
+	var nextUrl *url.URL
+	switch {
+	case pagination.NextUrl != "":
+		path, _ := compileJsonPath(pagination.NextUrl)
+		link := firstJsonString(path.eval(root))
+		if link == "" {
+			return nil, nil
+		}
+		u, err := url.Parse(link)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid next page url '%s': %s", link, err))
+		}
+		nextUrl = pageUrl.ResolveReference(u)
+	case pagination.Cursor != "":
+		path, _ := compileJsonPath(pagination.Cursor)
+		cursor := firstJsonString(path.eval(root))
+		if cursor == "" {
+			return nil, nil
+		}
+		nextUrl = withQueryParam(pageUrl, pagination.CursorParam, cursor)
+	default:
+		if !hasItems {
+			return nil, nil
+		}
+		current := pagination.FirstPage
+		if current == 0 {
+			current = 1
+		}
+		if s := pageUrl.Query().Get(pagination.PageParam); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid page number '%s' in %s!", s, pageUrl))
+			}
+			current = n
+		}
+		nextUrl = withQueryParam(pageUrl, pagination.PageParam, strconv.Itoa(current+1))
+	}
+	if nextUrl.String() == pageUrl.String() {
+		return nil, nil
+	}
+	httpReq, err := http.NewRequest("GET", nextUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = httpResp.Request.Header.Clone()
+	req := base.NewRequest(httpReq, respDepth+1)
+	req.SetMeta(base.META_PAGE, page)
+	if !pagination.RespectDepth {
+		req.SetMeta(base.META_IGNORE_DEPTH, true)
+	}
+	if parsers := reqMeta.Strings(base.META_PARSERS); len(parsers) > 0 {
+		req.SetParsers(parsers...)
+	}
+	return req, nil
+}
+
+// 获得一组值中第一个非空的值的字符串形式。
+func firstJsonString(values []interface{}) string {
+	for _, v := range values {
+		if s := valueString(v); v != nil && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// 生成设置了查询参数的URL的副本。
+func withQueryParam(u *url.URL, key string, value string) *url.URL {
+	result := *u
+	query := result.Query()
+	query.Set(key, value)
+	result.RawQuery = query.Encode()
+	return &result
+}
+
+// 创建JSON解析函数。它把JSON响应中由JSONPath表达式选出的值映射为条目的字段，
+// 并可以按照翻页规则生成下一页的请求。规则集中的选择器都是JSONPath表达式，
+// 条目范围的表达式选出的数组会被展开，其中每个元素生成一个条目；
+// 字段的表达式相对于条目范围，字段规则中的Attr表示再从选出的对象中取出的字段。
+// 参数pagination可以为nil。
+func NewJsonParser(ruleSet RuleSet, pagination *Pagination) (ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	if len(ruleSet.Items) > 0 || len(ruleSet.Follow) > 0 || pagination == nil {
+		argsErr.Add("", ruleSet.Check())
+	}
+	argsErr.Add("", ruleSet.checkSelectors(func(selector string) error {
+		_, err := compileJsonPath(selector)
+		return err
+	}))
+	if pagination != nil {
+		argsErr.Add("pagination", pagination.Check())
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		if mediaType := mediaTypeOf(httpResp); mediaType != "" && !isJson(mediaType) {
+			return nil, nil
+		}
+		pageUrl := httpResp.Request.URL
+		root, err := decodeJson(httpResp.Body)
+		if err != nil {
+			return nil, []error{errors.New(fmt.Sprintf(
+				"Can not parse the JSON document (url=%s): %s", pageUrl, err))}
+		}
+		doc := &jsonDocument{root: root, paths: make(map[string]*jsonPath)}
+		dataList, errs := extractByRules(doc, &ruleSet, pageUrl, pageUrl, respDepth)
+		if pagination != nil {
+			hasItems := false
+			for _, data := range dataList {
+				if _, ok := data.(*base.Item); ok {
+					hasItems = true
+					break
+				}
+			}
+			req, err := pagination.next(root, httpResp, respDepth, hasItems)
+			if err != nil {
+				errs = append(errs, err)
+			} else if req != nil {
+				dataList = append(dataList, req)
+			}
+		}
+		return dataList, errs
+	}, nil
+}
+
+// 判断内容类型是否为JSON。
+func isJson(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "text/json", "text/plain", "application/javascript":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json")
+}
+
+// 解码JSON文档。整数会被解码为int64，其他数字会被解码为float64。
+func decodeJson(reader io.Reader) (interface{}, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	return normalizeJsonNumbers(root), nil
+}
+
+// 把json.Number转换为int64或float64。
+func normalizeJsonNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		for i, e := range value {
+			value[i] = normalizeJsonNumbers(e)
+		}
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = normalizeJsonNumbers(e)
+		}
+	}
+	return v
+}
+
+// JSON文档。
+type jsonDocument struct {
+	root  interface{}          // 文档的根节点。
+	paths map[string]*jsonPath // 已编译的表达式。
+}
+
+// 获得已编译的表达式。表达式无效时返回nil。
+func (jd *jsonDocument) compile(selector string) *jsonPath {
+	path, ok := jd.paths[selector]
+	if !ok {
+		path, _ = compileJsonPath(selector)
+		jd.paths[selector] = path
+	}
+	return path
+}
+
+func (jd *jsonDocument) scopes(selector string) []ruleNode {
+	if selector == "" {
+		return []ruleNode{&jsonNode{doc: jd, value: jd.root}}
+	}
+	nodes := make([]ruleNode, 0)
+	path := jd.compile(selector)
+	if path == nil {
+		return nodes
+	}
+	for _, v := range path.eval(jd.root) {
+		if array, ok := v.([]interface{}); ok {
+			for _, e := range array {
+				nodes = append(nodes, &jsonNode{doc: jd, value: e})
+			}
+			continue
+		}
+		nodes = append(nodes, &jsonNode{doc: jd, value: v})
+	}
+	return nodes
+}
+
+// JSON文档中的节点。
+type jsonNode struct {
+	doc   *jsonDocument // 节点所属的文档。
+	value interface{}   // 节点的值。
+}
+
+func (jn *jsonNode) values(selector string, attr string) []interface{} {
+	values := []interface{}{jn.value}
+	if selector != "" {
+		path := jn.doc.compile(selector)
+		if path == nil {
+			return nil
+		}
+		values = path.eval(jn.value)
+	}
+	if attr == "" {
+		return values
+	}
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if object, ok := v.(map[string]interface{}); ok {
+			if e, ok := object[attr]; ok {
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
+
+// 根据参数生成JSON解析函数。参数items和follow与CSS提取器的相同，
+// 参数pagination包含nextUrl、cursor、cursorParam、pageParam、firstPage、maxPages和respectDepth。
+func newJsonParserFromParams(params base.Params) (ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	ruleSet, err := ruleSetFromParams(params)
+	argsErr.Add("", err)
+	var pagination *Pagination
+	if params.Has("pagination") {
+		p, err := params.Params("pagination")
+		if err != nil {
+			argsErr.Add("", err)
+		} else {
+			pagination, err = paginationFromParams(p)
+			argsErr.Add("pagination", err)
+		}
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewJsonParser(ruleSet, pagination)
+}
+
+// 根据参数生成翻页规则。
+func paginationFromParams(params base.Params) (*Pagination, error) {
+	pagination := &Pagination{}
+	argsErr := base.NewArgsError()
+	var err error
+	if pagination.NextUrl, err = params.String("nextUrl", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.Cursor, err = params.String("cursor", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.CursorParam, err = params.String("cursorParam", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.PageParam, err = params.String("pageParam", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.FirstPage, err = params.Int("firstPage", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.MaxPages, err = params.Int("maxPages", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if pagination.RespectDepth, err = params.Bool("respectDepth", false); err != nil {
+		argsErr.Add("", err)
+	}
+	return pagination, argsErr.ErrorOrNil()
+}
@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const jsonTestPage = `{
+  "data": {"items": [
+    {"id": 1, "name": "Tea", "price": "1,200.5", "tags": ["green", "hot"], "owner": {"login": "ann"}},
+    {"id": 2, "name": "Coffee", "price": "3", "tags": [], "owner": {"login": "bob"}}
+  ]},
+  "paging": {"next": "/api/items?after=abc", "cursor": "abc"}
+}`
+
+func TestJsonPath(t *testing.T) {
+	root, err := decodeJson(strings.NewReader(jsonTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string][]interface{}{
+		"$.data.items[0].name":        {"Tea"},
+		"data.items[-1].id":           {int64(2)},
+		"$.data.items[*].owner.login": {"ann", "bob"},
+		"$..login":                    {"ann", "bob"},
+		"$['paging']['cursor']":       {"abc"},
+		"$.data.items[0:1].id":        {int64(1)},
+		"$.missing":                   {},
+	}
+	for expr, expected := range cases {
+		path, err := compileJsonPath(expr)
+		if err != nil {
+			t.Errorf("Can not compile %s: %s", expr, err)
+			continue
+		}
+		if result := path.eval(root); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Unexpected result %v of %s, expected %v!", result, expr, expected)
+		}
+	}
+	if _, err := compileJsonPath("$.data[abc"); err == nil {
+		t.Errorf("Expected an error for the invalid JSONPath!")
+	}
+}
+
+func TestJsonParser(t *testing.T) {
+	parser, err := NewParser("json", base.Params{
+		"items": []interface{}{map[string]interface{}{
+			"type":  "product",
+			"scope": "$.data.items",
+			"fields": map[string]interface{}{
+				"id":    "id",
+				"name":  "name",
+				"price": map[string]interface{}{"selector": "price", "type": "float"},
+				"tags":  map[string]interface{}{"selector": "tags[*]", "list": true},
+				"owner": map[string]interface{}{"selector": "owner", "attr": "login"},
+			},
+		}},
+		"pagination": map[string]interface{}{"cursor": "$.paging.cursor", "cursorParam": "after", "maxPages": 3},
+	})
+	if err != nil {
+		t.Fatalf("Can not create the JSON parser: %s", err)
+	}
+	meta := base.Metadata{base.META_PAGE: 1, base.META_PARSERS: []string{"json-test"}}
+	httpResp := newTestHttpResponse("http://example.com/api/items?limit=2", "application/json", jsonTestPage, meta)
+	dataList, errs := parser(httpResp, 4)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(dataList) != 3 {
+		t.Fatalf("Unexpected data list %v!", dataList)
+	}
+	expected := base.Item{
+		base.ITEM_TYPE_KEY: "product", "id": int64(1), "name": "Tea", "price": 1200.5,
+		"tags": []interface{}{"green", "hot"}, "owner": "ann",
+	}
+	if item := *dataList[0].(*base.Item); !reflect.DeepEqual(item, expected) {
+		t.Errorf("Unexpected item %v!\nexpected: %v", item, expected)
+	}
+	req := dataList[2].(*base.Request)
+	if url := req.HttpReq().URL.String(); url != "http://example.com/api/items?after=abc&limit=2" {
+		t.Errorf("Unexpected next page url %s!", url)
+	}
+	if req.Depth() != 5 || req.Meta().Int(base.META_PAGE) != 2 ||
+		!req.Meta().Bool(base.META_IGNORE_DEPTH) || !reflect.DeepEqual(req.Parsers(), []string{"json-test"}) {
+		t.Errorf("Unexpected next page request (depth=%d, meta=%v)!", req.Depth(), req.Meta())
+	}
+
+	meta[base.META_PAGE] = 2
+	dataList, _ = parser(newTestHttpResponse("http://example.com/api/items", "application/json", jsonTestPage, nil), 0)
+	if len(dataList) != 3 {
+		t.Errorf("Expected a next page request for the first page, but got %v!", dataList)
+	}
+	httpResp = newTestHttpResponse("http://example.com/api/items", "application/json", jsonTestPage, meta)
+	if dataList, _ = parser(httpResp, 0); len(dataList) != 2 {
+		t.Errorf("Expected no next page request after the max pages, but got %v!", dataList)
+	}
+}
+
+func TestJsonPageNumberPagination(t *testing.T) {
+	parser, err := NewJsonParser(
+		RuleSet{Items: []ItemRule{{Type: "product", Scope: "$.data.items", Fields: []FieldRule{{Name: "id", Selector: "id"}}}}},
+		&Pagination{PageParam: "page", RespectDepth: true})
+	if err != nil {
+		t.Fatalf("Can not create the JSON parser: %s", err)
+	}
+	dataList, _ := parser(newTestHttpResponse("http://example.com/api?page=7", "application/json", jsonTestPage, nil), 0)
+	req := dataList[len(dataList)-1].(*base.Request)
+	if req.HttpReq().URL.Query().Get("page") != "8" || req.Meta().Bool(base.META_IGNORE_DEPTH) {
+		t.Errorf("Unexpected next page request %s (meta=%v)!", req.HttpReq().URL, req.Meta())
+	}
+	dataList, _ = parser(newTestHttpResponse("http://example.com/api?page=8", "application/json", `{"data": {"items": []}}`, nil), 0)
+	if len(dataList) != 0 {
+		t.Errorf("Expected no next page request for an empty page, but got %v!", dataList)
+	}
+
+	if _, err := NewJsonParser(RuleSet{}, &Pagination{PageParam: "page", Cursor: "$.c"}); err == nil {
+		t.Errorf("Expected an error for the ambiguous pagination!")
+	}
+}
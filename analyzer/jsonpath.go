@@ -0,0 +1,235 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath表达式。支持的语法包括：根节点"$"、子节点".name"和"['name']"、
+// 下标"[0]"和"[-1]"、切片"[1:3]"、通配符".*"和"[*]"以及递归下降"..name"。
+// 不以"$"开头的表达式相对于当前节点，例如"data.items[0].title"。
+type jsonPath struct {
+	expr  string         // 原始的表达式。
+	steps []jsonPathStep // 步骤的列表。
+}
+
+// JSONPath步骤的种类。
+type jsonPathStepKind int
+
+const (
+	jsonStepKey      jsonPathStepKind = iota // 对象的字段。
+	jsonStepIndex                            // 数组的元素。
+	jsonStepSlice                            // 数组的切片。
+	jsonStepWildcard                         // 全部子节点。
+)
+
+// JSONPath步骤。
+type jsonPathStep struct {
+	kind      jsonPathStepKind // 步骤的种类。
+	recursive bool             // 是否为递归下降。
+	key       string           // 字段名。
+	index     int              // 下标。
+	start     *int             // 切片的起点。
+	end       *int             // 切片的终点。
+}
+
+// 编译JSONPath表达式。
+func compileJsonPath(expr string) (*jsonPath, error) {
+	path := &jsonPath{expr: expr}
+	rest := strings.TrimSpace(expr)
+	if strings.HasPrefix(rest, "$") || strings.HasPrefix(rest, "@") {
+		rest = rest[1:]
+	} else if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	for rest != "" {
+		var step jsonPathStep
+		var err error
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step, rest, err = parseJsonPathDot(rest[2:])
+			step.recursive = true
+		case rest[0] == '.':
+			step, rest, err = parseJsonPathDot(rest[1:])
+		case rest[0] == '[':
+			step, rest, err = parseJsonPathBracket(rest[1:])
+		default:
+			err = errors.New(fmt.Sprintf("unexpected %q", rest))
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid JSONPath '%s': %s", expr, err))
+		}
+		path.steps = append(path.steps, step)
+	}
+	return path, nil
+}
+
+// 解析"."之后的步骤。
+func parseJsonPathDot(s string) (jsonPathStep, string, error) {
+	if strings.HasPrefix(s, "[") {
+		return parseJsonPathBracket(s[1:])
+	}
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	if name == "" {
+		return jsonPathStep{}, s, errors.New("empty field name")
+	}
+	if name == "*" {
+		return jsonPathStep{kind: jsonStepWildcard}, s[end:], nil
+	}
+	return jsonPathStep{kind: jsonStepKey, key: name}, s[end:], nil
+}
+
+// 解析"["之后的步骤。
+func parseJsonPathBracket(s string) (jsonPathStep, string, error) {
+	if strings.HasPrefix(s, "'") || strings.HasPrefix(s, "\"") {
+		quote := s[:1]
+		end := strings.Index(s[1:], quote+"]")
+		if end < 0 {
+			return jsonPathStep{}, s, errors.New("unclosed quoted field name")
+		}
+		return jsonPathStep{kind: jsonStepKey, key: s[1 : end+1]}, s[end+3:], nil
+	}
+	end := strings.Index(s, "]")
+	if end < 0 {
+		return jsonPathStep{}, s, errors.New("missing ']'")
+	}
+	inner, rest := strings.TrimSpace(s[:end]), s[end+1:]
+	if inner == "*" {
+		return jsonPathStep{kind: jsonStepWildcard}, rest, nil
+	}
+	if colon := strings.Index(inner, ":"); colon >= 0 {
+		step := jsonPathStep{kind: jsonStepSlice}
+		for i, part := range []string{inner[:colon], inner[colon+1:]} {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return step, s, errors.New(fmt.Sprintf("invalid slice %q", inner))
+			}
+			if i == 0 {
+				step.start = &n
+			} else {
+				step.end = &n
+			}
+		}
+		return step, rest, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return jsonPathStep{}, s, errors.New(fmt.Sprintf("invalid index %q", inner))
+	}
+	return jsonPathStep{kind: jsonStepIndex, index: n}, rest, nil
+}
+
+// 在给定的节点上求值，返回全部匹配的值。
+func (path *jsonPath) eval(root interface{}) []interface{} {
+	nodes := []interface{}{root}
+	for _, step := range path.steps {
+		next := make([]interface{}, 0)
+		for _, node := range nodes {
+			if step.recursive {
+				for _, d := range jsonDescendants(node) {
+					next = step.apply(d, next)
+				}
+			} else {
+				next = step.apply(node, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// 对单个节点执行步骤，并把结果追加到列表中。
+func (step *jsonPathStep) apply(node interface{}, result []interface{}) []interface{} {
+	switch step.kind {
+	case jsonStepKey:
+		if object, ok := node.(map[string]interface{}); ok {
+			if v, ok := object[step.key]; ok {
+				result = append(result, v)
+			}
+		}
+	case jsonStepIndex:
+		if array, ok := node.([]interface{}); ok {
+			i := step.index
+			if i < 0 {
+				i += len(array)
+			}
+			if i >= 0 && i < len(array) {
+				result = append(result, array[i])
+			}
+		}
+	case jsonStepSlice:
+		if array, ok := node.([]interface{}); ok {
+			start, end := 0, len(array)
+			if step.start != nil {
+				start = clampJsonIndex(*step.start, len(array))
+			}
+			if step.end != nil {
+				end = clampJsonIndex(*step.end, len(array))
+			}
+			if start < end {
+				result = append(result, array[start:end]...)
+			}
+		}
+	case jsonStepWildcard:
+		switch v := node.(type) {
+		case []interface{}:
+			result = append(result, v...)
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				result = append(result, v[key])
+			}
+		}
+	}
+	return result
+}
+
+// 把可能为负的下标转换到数组的范围内。
+func clampJsonIndex(i int, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// 获得节点本身及其全部后代节点。对象的字段按字段名排序。
+func jsonDescendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	switch v := node.(type) {
+	case []interface{}:
+		for _, e := range v {
+			result = append(result, jsonDescendants(e)...)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			result = append(result, jsonDescendants(v[key])...)
+		}
+	}
+	return result
+}
+
+// 获得对象的字段名，按字典序排列。
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
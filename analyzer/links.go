@@ -0,0 +1,300 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"sys/fetch/base"
+)
+
+// 注册内置的链接提取器。
+func init() {
+	RegisterFactory("links", newLinkExtractorFromParams)
+}
+
+// 各标签中默认被读取的属性。
+var defaultLinkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"iframe": "src",
+	"frame":  "src",
+	"img":    "src",
+	"script": "src",
+}
+
+// 默认被提取的标签。
+var defaultLinkTags = []string{"a", "area", "link", "iframe", "frame"}
+
+// 链接提取规则。零值表示从默认的标签中提取全部链接。
+type LinkRules struct {
+	Tags           []string         // 要提取的标签。为空时使用a、area、link、iframe和frame。
+	Attrs          []string         // 要读取的属性。为空时使用各标签的默认属性(href或src)。
+	Images         bool             // 是否同时提取img标签。
+	Scripts        bool             // 是否同时提取script标签。
+	Allow          []*regexp.Regexp // 链接需匹配的正则表达式。为空时不限制。
+	Deny           []*regexp.Regexp // 链接不能匹配的正则表达式。
+	FollowNofollow bool             // 是否提取带有rel="nofollow"的链接。
+	Parsers        []string         // 被提取的请求所指定的解析函数的名称。
+}
+
+// 检查链接提取规则的有效性。
+func (rules *LinkRules) Check() error {
+	argsErr := base.NewArgsError()
+	for i, tag := range rules.Tags {
+		if tag == "" {
+			argsErr.Add(fmt.Sprintf("tags[%d]", i), errors.New("The tag can not be empty!"))
+			continue
+		}
+		if _, ok := defaultLinkAttrs[strings.ToLower(tag)]; !ok && len(rules.Attrs) == 0 {
+			argsErr.Add(fmt.Sprintf("tags[%d]", i), errors.New(fmt.Sprintf(
+				"The tag '%s' has no default link attribute, please specify the attrs!", tag)))
+		}
+	}
+	for i, attr := range rules.Attrs {
+		if attr == "" {
+			argsErr.Add(fmt.Sprintf("attrs[%d]", i), errors.New("The attribute can not be empty!"))
+		}
+	}
+	//名称在分析时才从具名的解析函数实例或注册表中获得，这里只能检查它是否为空
+	for i, name := range rules.Parsers {
+		if name == "" {
+			argsErr.Add(fmt.Sprintf("parsers[%d]", i),
+				errors.New("The response parser name can not be empty!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 获得要提取的标签。
+func (rules *LinkRules) tags() []string {
+	tags := rules.Tags
+	if len(tags) == 0 {
+		tags = defaultLinkTags
+	}
+	result := make([]string, 0, len(tags)+2)
+	for _, tag := range tags {
+		result = append(result, strings.ToLower(tag))
+	}
+	if rules.Images {
+		result = append(result, "img")
+	}
+	if rules.Scripts {
+		result = append(result, "script")
+	}
+	return result
+}
+
+// 获得给定标签中要读取的属性。
+func (rules *LinkRules) attrs(tag string) []string {
+	if len(rules.Attrs) > 0 {
+		return rules.Attrs
+	}
+	return []string{defaultLinkAttrs[tag]}
+}
+
+// 判断链接是否符合允许和禁止规则。
+func (rules *LinkRules) accept(link string) bool {
+	for _, deny := range rules.Deny {
+		if deny.MatchString(link) {
+			return false
+		}
+	}
+	if len(rules.Allow) == 0 {
+		return true
+	}
+	for _, allow := range rules.Allow {
+		if allow.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// 创建链接提取器。它是一个响应解析函数，会为HTML页面中每个符合规则的链接生成一个请求。
+// 相对链接会依据页面的<base href>或页面的URL被解析，同一页面中重复的链接只生成一个请求。
+// 链接的文本和所在的标签会被记录在请求的元数据中。
+func NewLinkExtractor(rules LinkRules) (ParseResponse, error) {
+	if err := rules.Check(); err != nil {
+		return nil, err
+	}
+	tags := rules.tags()
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		if mediaType := mediaTypeOf(httpResp); mediaType != "" && !isHtml(mediaType) {
+			return nil, nil
+		}
+		doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		baseUrl := documentBaseUrl(doc, httpResp.Request.URL)
+		dataList := make([]base.Data, 0)
+		errs := make([]error, 0)
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			attrs := rules.attrs(tag)
+			doc.Find(tag).Each(func(index int, sel *goquery.Selection) {
+				if !rules.FollowNofollow && hasRel(sel, "nofollow") {
+					return
+				}
+				for _, attr := range attrs {
+					value, exists := sel.Attr(attr)
+					if !exists {
+						continue
+					}
+					link, ok := resolveLink(baseUrl, value)
+					if !ok || seen[link] || !rules.accept(link) {
+						continue
+					}
+					seen[link] = true
+					httpReq, err := http.NewRequest("GET", link, nil)
+					if err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					req := base.NewRequest(httpReq, respDepth+1)
+					req.SetMeta(base.META_LINK_TAG, tag)
+					if text := linkText(tag, sel); text != "" {
+						req.SetMeta(base.META_ANCHOR_TEXT, text)
+					}
+					if len(rules.Parsers) > 0 {
+						req.SetParsers(rules.Parsers...)
+					}
+					dataList = append(dataList, req)
+				}
+			})
+		}
+		return dataList, errs
+	}, nil
+}
+
+// 判断内容类型是否为HTML。
+func isHtml(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// 获得文档中的相对链接所依据的URL。
+func documentBaseUrl(doc *goquery.Document, pageUrl *url.URL) *url.URL {
+	href, exists := doc.Find("base[href]").First().Attr("href")
+	if !exists {
+		return pageUrl
+	}
+	baseUrl, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return pageUrl
+	}
+	return pageUrl.ResolveReference(baseUrl)
+}
+
+// 解析链接。不是HTTP(S)链接或只指向页面内部位置的链接会被忽略。
+func resolveLink(baseUrl *url.URL, value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "#") {
+		return "", false
+	}
+	linkUrl, err := url.Parse(value)
+	if err != nil {
+		return "", false
+	}
+	linkUrl = baseUrl.ResolveReference(linkUrl)
+	if linkUrl.Scheme != "http" && linkUrl.Scheme != "https" {
+		return "", false
+	}
+	linkUrl.Fragment = ""
+	return linkUrl.String(), true
+}
+
+// 判断标签的rel属性是否包含给定的值。
+func hasRel(sel *goquery.Selection, value string) bool {
+	rel, exists := sel.Attr("rel")
+	if !exists {
+		return false
+	}
+	for _, field := range strings.Fields(strings.ToLower(rel)) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 获得链接的文本。
+func linkText(tag string, sel *goquery.Selection) string {
+	switch tag {
+	case "a":
+		return strings.Join(strings.Fields(sel.Text()), " ")
+	case "area", "img":
+		alt, _ := sel.Attr("alt")
+		return strings.TrimSpace(alt)
+	}
+	return ""
+}
+
+// 根据参数生成链接提取器。参数与LinkRules的字段对应：tags、attrs、images、scripts、
+// allow、deny、followNofollow和parsers，其中allow和deny是正则表达式的列表。
+func newLinkExtractorFromParams(params base.Params) (ParseResponse, error) {
+	rules := LinkRules{}
+	argsErr := base.NewArgsError()
+	var err error
+	if rules.Tags, err = params.Strings("tags"); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Attrs, err = params.Strings("attrs"); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Images, err = params.Bool("images", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Scripts, err = params.Bool("scripts", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.FollowNofollow, err = params.Bool("followNofollow", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Parsers, err = params.Strings("parsers"); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Allow, err = regexpsParam(params, "allow"); err != nil {
+		argsErr.Add("", err)
+	}
+	if rules.Deny, err = regexpsParam(params, "deny"); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewLinkExtractor(rules)
+}
+
+// 获得正则表达式列表参数。
+func regexpsParam(params base.Params, key string) ([]*regexp.Regexp, error) {
+	patterns, err := params.Strings(key)
+	if err != nil {
+		return nil, err
+	}
+	argsErr := base.NewArgsError()
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("%s[%d]", key, i), err)
+			continue
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, argsErr.ErrorOrNil()
+}
@@ -0,0 +1,57 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const linksTestPage = `<html><head>
+<base href="http://example.com/docs/">
+<link rel="stylesheet" href="style.css">
+</head><body>
+<a href="intro.html">Intro</a>
+<a href="intro.html#part2">Intro again</a>
+<a href="/private/x" >Private</a>
+<a href="ads.html" rel="sponsored nofollow">Ads</a>
+<a href="mailto:me@example.com">Mail</a>
+<a href="#top">Top</a>
+<img src="logo.png" alt="Logo">
+</body></html>`
+
+func TestLinkExtractor(t *testing.T) {
+	parser, err := NewParser("links", base.Params{
+		"images": true,
+		"deny":   []interface{}{"/private/"},
+	})
+	if err != nil {
+		t.Fatalf("Can not create the link extractor: %s", err)
+	}
+	resp := newTestResponse("http://example.com/index.html", "text/html", linksTestPage, nil)
+	dataList, errs := parser(resp.HttpResp(), 2)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	links := make([]string, 0)
+	for _, data := range dataList {
+		req := data.(*base.Request)
+		if req.Depth() != 3 {
+			t.Errorf("Unexpected depth %d of the request %s!", req.Depth(), req.HttpReq().URL)
+		}
+		links = append(links, req.HttpReq().URL.String()+"|"+req.Meta().String(base.META_ANCHOR_TEXT))
+	}
+	expected := []string{
+		"http://example.com/docs/intro.html|Intro",
+		"http://example.com/docs/style.css|",
+		"http://example.com/docs/logo.png|Logo",
+	}
+	if strings.Join(links, " ") != strings.Join(expected, " ") {
+		t.Errorf("Unexpected links:\n%s\nexpected:\n%s",
+			strings.Join(links, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if _, err := NewParser("links", base.Params{"tags": "div"}); err == nil {
+		t.Errorf("Expected an error for a tag without a default attribute!")
+	}
+}
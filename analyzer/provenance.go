@@ -0,0 +1,39 @@
+package analyzer
+
+import (
+	"net/http"
+	"sys/fetch/base"
+)
+
+// 包装响应解析函数，使其生成的每个条目都自动带有来源信息。
+// 来源信息被放在条目的base.ITEM_META_KEY字段中，包括响应的元数据
+// (例如引用页面、链接文本和种子标识)以及响应的URL和深度。已有该字段的条目不会被修改。
+func WithProvenance(parser ParseResponse) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		dataList, errs := parser(httpResp, respDepth)
+		provenance := base.MetaOf(httpResp.Request).Clone()
+		if provenance == nil {
+			provenance = make(base.Metadata)
+		}
+		provenance["url"] = httpResp.Request.URL.String()
+		provenance["depth"] = respDepth
+		for _, data := range dataList {
+			var item base.Item
+			switch d := data.(type) {
+			case *base.Item:
+				if d == nil {
+					continue
+				}
+				item = *d
+			case base.Item:
+				item = d
+			default:
+				continue
+			}
+			if _, ok := item[base.ITEM_META_KEY]; !ok && item != nil {
+				item[base.ITEM_META_KEY] = provenance.Clone()
+			}
+		}
+		return dataList, errs
+	}
+}
@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"sys/fetch/base"
+	dl "sys/fetch/downloader"
+)
+
+// 生成给定深度的测试用的响应。与下载器生成的响应一样，其中的HTTP请求同样携带元数据。
+func newTestResponseAt(url string, depth uint32, meta base.Metadata) base.Response {
+	return *base.NewResponseWithMeta(newTestHttpResponse(url, "text/html", "hello", meta), depth, meta)
+}
+
+func newTestRequest(url string, meta base.Metadata) *base.Request {
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	return base.NewRequestWithMeta(httpReq, 0, meta)
+}
+
+func TestInheritMeta(t *testing.T) {
+	resp := newTestResponseAt("http://example.com/list", 1,
+		base.Metadata{base.META_SEED_ID: "http://example.com/", base.META_ANCHOR_TEXT: "List"})
+
+	req := newTestRequest("http://example.com/a", nil)
+	inheritMeta(req, resp)
+	expected := base.Metadata{
+		base.META_REFERRER: "http://example.com/list",
+		base.META_SEED_ID:  "http://example.com/",
+	}
+	if !reflect.DeepEqual(req.Meta(), expected) {
+		t.Errorf("Expected the metadata %v, but got %v!", expected, req.Meta())
+	}
+
+	//新请求中已有的值不会被覆盖
+	req = newTestRequest("http://example.com/b", base.Metadata{
+		base.META_REFERRER: "http://example.com/other",
+		base.META_SEED_ID:  "http://example.com/sitemap.xml",
+	})
+	inheritMeta(req, resp)
+	if req.Meta().String(base.META_REFERRER) != "http://example.com/other" ||
+		req.Meta().String(base.META_SEED_ID) != "http://example.com/sitemap.xml" {
+		t.Errorf("The existing metadata should be kept, but got %v!", req.Meta())
+	}
+
+	//响应没有种子标识时也不会设置它
+	req = newTestRequest("http://example.com/c", nil)
+	inheritMeta(req, newTestResponseAt("http://example.com/", 0, nil))
+	if _, ok := req.Meta().Get(base.META_SEED_ID); ok {
+		t.Errorf("Unexpected seed id in %v!", req.Meta())
+	}
+}
+
+func TestAppendDataList(t *testing.T) {
+	resp := newTestResponseAt("http://example.com/list", 2,
+		base.Metadata{base.META_SEED_ID: "http://example.com/"})
+	item := base.Item{"title": "a"}
+	link := newTestRequest("http://example.com/a", nil)
+	sitemap := newTestRequest("http://example.com/sitemap.xml", base.Metadata{base.META_SEED: true})
+	var dataList []base.Data
+	for _, data := range []base.Data{&item, nil, link, sitemap} {
+		dataList = appendDataList(dataList, data, resp)
+	}
+	if len(dataList) != 3 {
+		t.Fatalf("Expected 3 data, but got %d!", len(dataList))
+	}
+	if dataList[0] != &item {
+		t.Errorf("The item should be passed through, but got %v!", dataList[0])
+	}
+	linkReq := dataList[1].(*base.Request)
+	if linkReq.Depth() != 3 || linkReq.Meta().String(base.META_REFERRER) != "http://example.com/list" ||
+		linkReq.Meta().String(base.META_SEED_ID) != "http://example.com/" {
+		t.Errorf("Unexpected request (depth=%d, meta=%v)!", linkReq.Depth(), linkReq.Meta())
+	}
+	//被当作种子请求的请求的深度总是0，它的元数据也会被保留
+	seedReq := dataList[2].(*base.Request)
+	if seedReq.Depth() != 0 || !seedReq.Meta().Bool(base.META_SEED) ||
+		seedReq.Meta().String(base.META_REFERRER) != "http://example.com/list" {
+		t.Errorf("Unexpected seed request (depth=%d, meta=%v)!", seedReq.Depth(), seedReq.Meta())
+	}
+}
+
+func TestWithProvenance(t *testing.T) {
+	meta := base.Metadata{base.META_REFERRER: "http://example.com/", base.META_ANCHOR_TEXT: "Tea"}
+	httpResp := newTestHttpResponse("http://example.com/tea", "text/html", "hello", meta)
+	owned := base.Item{base.ITEM_META_KEY: "kept"}
+	parser := WithProvenance(func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		first, second := base.Item{"n": 1}, base.Item{"n": 2}
+		return []base.Data{&first, second, owned, (*base.Item)(nil), newTestRequest("http://example.com/a", nil)}, nil
+	})
+	dataList, errs := parser(httpResp, 1)
+	if len(errs) != 0 || len(dataList) != 5 {
+		t.Fatalf("Unexpected result %v, %v!", dataList, errs)
+	}
+	expected := base.Metadata{
+		base.META_REFERRER:    "http://example.com/",
+		base.META_ANCHOR_TEXT: "Tea",
+		"url":                 "http://example.com/tea",
+		"depth":               uint32(1),
+	}
+	first := *dataList[0].(*base.Item)
+	second := dataList[1].(base.Item)
+	for _, item := range []base.Item{first, second} {
+		if !reflect.DeepEqual(item[base.ITEM_META_KEY], expected) {
+			t.Errorf("Expected the provenance %v, but got %v!", expected, item[base.ITEM_META_KEY])
+		}
+	}
+	//每个条目得到的是来源信息的副本，响应的元数据也不会被修改
+	first[base.ITEM_META_KEY].(base.Metadata)["url"] = "changed"
+	if second[base.ITEM_META_KEY].(base.Metadata)["url"] != "http://example.com/tea" {
+		t.Errorf("The provenance should not be shared between items!")
+	}
+	if _, ok := meta["url"]; ok {
+		t.Errorf("The metadata of the response should not be modified, but got %v!", meta)
+	}
+	if owned[base.ITEM_META_KEY] != "kept" {
+		t.Errorf("The existing provenance should be kept, but got %v!", owned[base.ITEM_META_KEY])
+	}
+}
+
+// 元数据从请求经由下载器得到的响应传递给解析函数、它生成的条目和新的请求。
+func TestMetaPropagation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>hello</body></html>"))
+	}))
+	defer server.Close()
+
+	httpReq, _ := http.NewRequest("GET", server.URL+"/list", nil)
+	req := base.NewRequestWithMeta(httpReq, 1, base.Metadata{
+		base.META_SEED_ID:     server.URL + "/",
+		base.META_ANCHOR_TEXT: "List",
+	})
+	resp, err := dl.NewPageDownloader(&http.Client{}).Download(*req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsedMeta base.Metadata
+	parser := WithProvenance(func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		parsedMeta = base.MetaOf(httpResp.Request)
+		item := base.Item{"title": "List"}
+		linkReq, _ := http.NewRequest("GET", server.URL+"/a", nil)
+		return []base.Data{&item, base.NewRequest(linkReq, 0)}, nil
+	})
+	dataList, errs := NewAnalyzer().Analyze([]ParseResponse{parser}, *resp)
+	if len(errs) != 0 || len(dataList) != 2 {
+		t.Fatalf("Unexpected result %v, %v!", dataList, errs)
+	}
+	if parsedMeta.String(base.META_ANCHOR_TEXT) != "List" {
+		t.Errorf("The parser should see the request metadata, but got %v!", parsedMeta)
+	}
+	provenance := (*dataList[0].(*base.Item))[base.ITEM_META_KEY].(base.Metadata)
+	if provenance.String(base.META_SEED_ID) != server.URL+"/" || provenance["url"] != server.URL+"/list" ||
+		provenance["depth"] != uint32(1) {
+		t.Errorf("Unexpected provenance %v!", provenance)
+	}
+	newReq := dataList[1].(*base.Request)
+	if newReq.Depth() != 2 || newReq.Meta().String(base.META_SEED_ID) != server.URL+"/" ||
+		newReq.Meta().String(base.META_REFERRER) != server.URL+"/list" {
+		t.Errorf("Unexpected new request (depth=%d, meta=%v)!", newReq.Depth(), newReq.Meta())
+	}
+	//下载器复制了请求的元数据，请求本身不会被修改
+	if _, ok := req.Meta().Get(base.META_REFERRER); ok {
+		t.Errorf("The original request should not be modified, but got %v!", req.Meta())
+	}
+}
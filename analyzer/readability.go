@@ -0,0 +1,404 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"sys/fetch/base"
+)
+
+// 正文条目的默认类型。
+const ARTICLE_ITEM_TYPE = "article"
+
+// 注册内置的正文提取器。
+func init() {
+	RegisterFactory("readability", newReadabilityExtractorFromParams)
+}
+
+// 正文提取器的选项。
+type ReadabilityOptions struct {
+	ItemType      string // 条目的类型。为空时使用ARTICLE_ITEM_TYPE。
+	MinTextLength int    // 正文的最少字符数，正文更短时不生成条目。为0时不限制。
+}
+
+// 检查正文提取器的选项的有效性。
+func (options *ReadabilityOptions) Check() error {
+	argsErr := base.NewArgsError()
+	if options.MinTextLength < 0 {
+		argsErr.Add("minTextLength", errors.New("The min text length can not be negative!"))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 用于判断元素的class和id的正则表达式。
+var (
+	//不太可能是正文的元素，除非它同时匹配readabilityMaybePattern
+	readabilityUnlikelyPattern = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|menu|modal|nav|popup|promo|related|remark|replies|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|widget|\bads?\b|advert`)
+	readabilityMaybePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|entry|post|story`)
+	readabilityPositivePattern = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	readabilityNegativePattern = regexp.MustCompile(`(?i)-ad-|hidden|\bhid\b|banner|combx|comment|com-|contact|foot|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|\bads?\b|advert`)
+	readabilityHiddenPattern   = regexp.MustCompile(`(?i)display\s*:\s*none|visibility\s*:\s*hidden`)
+	readabilityParagraphBreak  = regexp.MustCompile(`\n\s*\n`)
+)
+
+// 会被直接去掉的元素。
+const readabilityRemovedSelector = "script,style,noscript,iframe,form,nav,footer,aside,button,input,select,textarea,svg," +
+	"[hidden],[aria-hidden=true],[role=navigation],[role=banner],[role=contentinfo],[role=complementary],[role=dialog]"
+
+// 块级元素。提取文本时它们的前后会被分段。
+var readabilityBlockTags = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "tr": true, "ul": true,
+}
+
+// 创建正文提取器。它去掉页面中的导航、页脚、广告等元素，依据文本密度和链接密度为各个块评分，
+// 选出正文所在的块，并生成一个包括title、byline、published、text、html和image字段的条目。
+// published能被解析时是time.Time，否则是原始的字符串；text中的段落以空行分隔。
+func NewReadabilityExtractor(options ReadabilityOptions) (ParseResponse, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	itemType := options.ItemType
+	if itemType == "" {
+		itemType = ARTICLE_ITEM_TYPE
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		if mediaType := mediaTypeOf(httpResp); mediaType != "" && !isHtml(mediaType) {
+			return nil, nil
+		}
+		doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		baseUrl := documentBaseUrl(doc, httpResp.Request.URL)
+		item := base.Item{
+			base.ITEM_TYPE_KEY: itemType,
+			"title":            articleTitle(doc),
+			"byline":           articleByline(doc),
+			"image":            metaContent(doc, `meta[property="og:image"]`, `meta[name="twitter:image"]`),
+		}
+		if published := articlePublished(doc); published != "" {
+			if t, err := base.CoerceValue(published, base.TYPE_DATE, "", nil); err == nil {
+				item["published"] = t
+			} else {
+				item["published"] = published
+			}
+		}
+
+		cleanArticleDocument(doc)
+		content := articleContent(doc)
+		text := articleText(content)
+		if utf8.RuneCountInString(text) < options.MinTextLength {
+			return nil, nil
+		}
+		content.Find("[href],[src]").Each(func(index int, sel *goquery.Selection) {
+			for _, attr := range []string{"href", "src"} {
+				if value, ok := sel.Attr(attr); ok {
+					if u, err := url.Parse(strings.TrimSpace(value)); err == nil {
+						sel.SetAttr(attr, baseUrl.ResolveReference(u).String())
+					}
+				}
+			}
+		})
+		var buffer bytes.Buffer
+		for _, node := range content.Nodes {
+			if err := html.Render(&buffer, node); err != nil {
+				return nil, []error{err}
+			}
+		}
+		item["text"] = text
+		item["html"] = buffer.String()
+		if item["image"] == "" {
+			item["image"], _ = content.Find("img[src]").First().Attr("src")
+		} else if u, err := url.Parse(item["image"].(string)); err == nil {
+			item["image"] = baseUrl.ResolveReference(u).String()
+		}
+		return []base.Data{&item}, nil
+	}, nil
+}
+
+// 获得第一个存在的meta标签的内容。
+func metaContent(doc *goquery.Document, selectors ...string) string {
+	for _, selector := range selectors {
+		if content, ok := doc.Find(selector).First().Attr("content"); ok && strings.TrimSpace(content) != "" {
+			return strings.TrimSpace(content)
+		}
+	}
+	return ""
+}
+
+// 获得文章的标题。依次使用og:title、唯一的h1和去掉了网站名称的<title>。
+func articleTitle(doc *goquery.Document) string {
+	if title := metaContent(doc, `meta[property="og:title"]`); title != "" {
+		return title
+	}
+	if h1 := doc.Find("h1"); h1.Length() == 1 {
+		if title := collapseSpace(h1.Text()); title != "" {
+			return title
+		}
+	}
+	title := collapseSpace(doc.Find("title").First().Text())
+	for _, separator := range []string{" | ", " - ", " – ", " — ", " :: ", " » "} {
+		if i := strings.LastIndex(title, separator); i > 0 {
+			return strings.TrimSpace(title[:i])
+		}
+	}
+	return title
+}
+
+// 获得文章的作者。
+func articleByline(doc *goquery.Document) string {
+	if author := metaContent(doc, `meta[name="author"]`, `meta[property="article:author"]`); author != "" &&
+		!strings.HasPrefix(author, "http") {
+		return author
+	}
+	for _, selector := range []string{`[itemprop~="author"] [itemprop="name"]`, `[itemprop~="author"]`,
+		`[rel="author"]`, ".byline", ".author"} {
+		if byline := collapseSpace(doc.Find(selector).First().Text()); byline != "" && len(byline) < 100 {
+			return byline
+		}
+	}
+	return ""
+}
+
+// 获得文章的发布时间的原始字符串。
+func articlePublished(doc *goquery.Document) string {
+	if published := metaContent(doc, `meta[property="article:published_time"]`, `meta[itemprop="datePublished"]`,
+		`meta[name="pubdate"]`, `meta[name="publishdate"]`, `meta[name="date"]`, `meta[name="DC.date.issued"]`); published != "" {
+		return published
+	}
+	for _, attr := range []string{"datetime", "content"} {
+		if published, ok := doc.Find(`[itemprop="datePublished"][` + attr + `]`).First().Attr(attr); ok {
+			return strings.TrimSpace(published)
+		}
+	}
+	published, _ := doc.Find("time[datetime]").First().Attr("datetime")
+	return strings.TrimSpace(published)
+}
+
+// 去掉页面中不可能是正文的元素。
+func cleanArticleDocument(doc *goquery.Document) {
+	doc.Find(readabilityRemovedSelector).Remove()
+	doc.Find("header").Each(func(index int, sel *goquery.Selection) {
+		if sel.Closest("article").Length() == 0 {
+			sel.Remove()
+		}
+	})
+	doc.Find("[style]").Each(func(index int, sel *goquery.Selection) {
+		if style, _ := sel.Attr("style"); readabilityHiddenPattern.MatchString(style) {
+			sel.Remove()
+		}
+	})
+	doc.Find("body *").Each(func(index int, sel *goquery.Selection) {
+		switch goquery.NodeName(sel) {
+		case "article", "main", "a":
+			return
+		}
+		match := classAndId(sel)
+		if readabilityUnlikelyPattern.MatchString(match) && !readabilityMaybePattern.MatchString(match) {
+			sel.Remove()
+		}
+	})
+}
+
+// 获得元素的class和id。
+func classAndId(sel *goquery.Selection) string {
+	class, _ := sel.Attr("class")
+	id, _ := sel.Attr("id")
+	return class + " " + id
+}
+
+// 依据class和id获得元素的权重。
+func classWeight(sel *goquery.Selection) float64 {
+	weight := 0.0
+	for _, attr := range []string{"class", "id"} {
+		value, _ := sel.Attr(attr)
+		if value == "" {
+			continue
+		}
+		if readabilityNegativePattern.MatchString(value) {
+			weight -= 25
+		}
+		if readabilityPositivePattern.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// 获得候选元素的初始分数。
+func initialScore(sel *goquery.Selection) float64 {
+	score := classWeight(sel)
+	switch goquery.NodeName(sel) {
+	case "div", "article", "main", "section":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// 获得元素中的链接文本占全部文本的比例。
+func linkDensity(sel *goquery.Selection) float64 {
+	textLength := utf8.RuneCountInString(collapseSpace(sel.Text()))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	sel.Find("a").Each(func(index int, a *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(collapseSpace(a.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// 选出正文所在的元素。得分最高的候选元素和得分足够高的兄弟元素都被认为是正文。
+// 没有候选元素时使用整个<body>。
+func articleContent(doc *goquery.Document) *goquery.Selection {
+	scores := make(map[*html.Node]float64)
+	candidates := make([]*goquery.Selection, 0)
+	paragraphs := doc.Find("p,pre,td,blockquote").AddSelection(doc.Find("div").FilterFunction(
+		func(index int, sel *goquery.Selection) bool {
+			return sel.Find("p,div,table,ul,ol,pre,blockquote,section,article").Length() == 0
+		}))
+	paragraphs.Each(func(index int, sel *goquery.Selection) {
+		text := collapseSpace(sel.Text())
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + math.Min(float64(length/100), 3)
+		ancestor := sel.Parent()
+		for level := 0; level < 3 && ancestor.Length() > 0 && goquery.NodeName(ancestor) != "html"; level++ {
+			node := ancestor.Get(0)
+			if _, ok := scores[node]; !ok {
+				scores[node] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			divider := 1.0
+			if level > 0 {
+				divider = float64(level * 2)
+			}
+			scores[node] += score / divider
+			ancestor = ancestor.Parent()
+		}
+	})
+	var top *goquery.Selection
+	topScore := 0.0
+	for _, candidate := range candidates {
+		node := candidate.Get(0)
+		scores[node] *= 1 - linkDensity(candidate)
+		if top == nil || scores[node] > topScore {
+			top, topScore = candidate, scores[node]
+		}
+	}
+	if top == nil {
+		return doc.Find("body")
+	}
+	threshold := math.Max(10, topScore*0.2)
+	content := top
+	top.Siblings().Each(func(index int, sibling *goquery.Selection) {
+		include := false
+		if score, ok := scores[sibling.Get(0)]; ok && score >= threshold {
+			include = true
+		} else if goquery.NodeName(sibling) == "p" {
+			text := collapseSpace(sibling.Text())
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(sibling)
+			include = (length > 80 && density < 0.25) ||
+				(length > 0 && density == 0 && strings.HasSuffix(text, "."))
+		}
+		if include {
+			content = content.AddSelection(sibling)
+		}
+	})
+	return content
+}
+
+// 提取元素中的文本。块级元素之间以空行分隔，<br>被转换为换行。
+func articleText(content *goquery.Selection) string {
+	var builder strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			builder.WriteString(node.Data)
+			return
+		case html.ElementNode:
+			if node.Data == "br" {
+				builder.WriteString("\n")
+				return
+			}
+		}
+		block := node.Type == html.ElementNode && readabilityBlockTags[node.Data]
+		if block {
+			builder.WriteString("\n\n")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			builder.WriteString("\n\n")
+		}
+	}
+	for _, node := range content.Nodes {
+		walk(node)
+	}
+	paragraphs := make([]string, 0)
+	for _, block := range readabilityParagraphBreak.Split(builder.String(), -1) {
+		lines := make([]string, 0)
+		for _, line := range strings.Split(block, "\n") {
+			if line = collapseSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// 合并字符串中的空白。
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// 根据参数生成正文提取器。参数包括type和minTextLength。
+func newReadabilityExtractorFromParams(params base.Params) (ParseResponse, error) {
+	options := ReadabilityOptions{}
+	argsErr := base.NewArgsError()
+	var err error
+	if options.ItemType, err = params.String("type", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.MinTextLength, err = params.Int("minTextLength", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewReadabilityExtractor(options)
+}
@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+const readabilityTestPage = `<html><head>
+<title>Brewing green tea | Example Blog</title>
+<meta name="author" content="Ann Lee">
+<meta property="article:published_time" content="2024-03-05T08:30:00Z">
+</head><body>
+<header><a href="/">Home</a> <a href="/about">About</a></header>
+<nav><ul><li><a href="/a">A</a></li><li><a href="/b">B</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is great, really, trust us, it is.</p></div>
+<div id="main-content" class="post">
+  <h1>Brewing green tea</h1>
+  <p>Green tea should be brewed with water that is well below boiling, around eighty degrees, for two minutes.</p>
+  <p>Use about two grams of leaves per cup, and do not squeeze the leaves, or the tea will turn bitter.<br>Enjoy it plain.</p>
+  <img src="/img/tea.jpg">
+  <p>Many varieties, such as sencha, gyokuro and matcha, need their own temperatures, times and amounts.</p>
+  <div class="ad-banner ads"><p>Buy cheap tea now, limited offer, click here, click here, click here!</p></div>
+  <script>var tracking = true;</script>
+</div>
+<div class="comments"><p>Nice post, thanks for sharing it with all of us, really appreciated it.</p></div>
+<footer><p>Copyright 2024, Example Blog, all rights reserved, forever and ever.</p></footer>
+</body></html>`
+
+func TestReadabilityExtractor(t *testing.T) {
+	parser, err := NewParser("readability", base.Params{"minTextLength": 100})
+	if err != nil {
+		t.Fatalf("Can not create the readability extractor: %s", err)
+	}
+	dataList, errs := parser(newTestHttpResponse("http://example.com/posts/tea", "text/html", readabilityTestPage, nil), 0)
+	if len(errs) > 0 || len(dataList) != 1 {
+		t.Fatalf("Unexpected data list %v (errors=%v)!", dataList, errs)
+	}
+	item := *dataList[0].(*base.Item)
+	if item[base.ITEM_TYPE_KEY] != ARTICLE_ITEM_TYPE || item["title"] != "Brewing green tea" ||
+		item["byline"] != "Ann Lee" || item["image"] != "http://example.com/img/tea.jpg" {
+		t.Errorf("Unexpected item %v!", item)
+	}
+	if published, ok := item["published"].(time.Time); !ok || !published.Equal(time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected published time %v!", item["published"])
+	}
+	text := item["text"].(string)
+	if !strings.HasPrefix(text, "Brewing green tea\n\nGreen tea should be brewed") ||
+		!strings.Contains(text, "turn bitter.\nEnjoy it plain.\n\nMany varieties") {
+		t.Errorf("Unexpected text %q!", text)
+	}
+	for _, boilerplate := range []string{"Home", "Subscribe", "Buy cheap", "tracking", "Nice post", "Copyright"} {
+		if strings.Contains(text, boilerplate) || strings.Contains(item["html"].(string), boilerplate) {
+			t.Errorf("The boilerplate %q is not removed from %q!", boilerplate, text)
+		}
+	}
+	if !strings.Contains(item["html"].(string), `<img src="http://example.com/img/tea.jpg"/>`) {
+		t.Errorf("Unexpected html %s!", item["html"])
+	}
+
+	dataList, _ = parser(newTestHttpResponse("http://example.com/", "text/html",
+		"<html><body><p>Too short.</p></body></html>", nil), 0)
+	if len(dataList) != 0 {
+		t.Errorf("Expected no item for a short page, but got %v!", dataList)
+	}
+	if _, err := NewReadabilityExtractor(ReadabilityOptions{MinTextLength: -1}); err == nil {
+		t.Errorf("Expected an error for the negative min text length!")
+	}
+}
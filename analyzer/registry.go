@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sys/fetch/base"
+)
+
+// 生成响应解析函数的工厂函数类型。参数params来自配置，可能为空。
+type ParserFactory func(params base.Params) (ParseResponse, error)
+
+// 响应解析函数的注册表。配置文件通过名称引用其中的解析函数。
+var parserRegistry = struct {
+	sync.RWMutex
+	factories map[string]ParserFactory
+}{factories: make(map[string]ParserFactory)}
+
+// 以给定的名称注册响应解析函数。名称为空、函数为nil或名称重复时会引发panic。
+func Register(name string, parser ParseResponse) {
+	if parser == nil {
+		panic(errors.New(fmt.Sprintf("The response parser '%s' is invalid!", name)))
+	}
+	RegisterFactory(name, func(params base.Params) (ParseResponse, error) {
+		if len(params) > 0 {
+			return nil, errors.New(fmt.Sprintf(
+				"The response parser '%s' does not accept any params!", name))
+		}
+		return parser, nil
+	})
+}
+
+// 以给定的名称注册生成响应解析函数的工厂函数。
+// 名称为空、函数为nil或名称重复时会引发panic。
+func RegisterFactory(name string, factory ParserFactory) {
+	if name == "" {
+		panic(errors.New("The response parser name can not be empty!"))
+	}
+	if factory == nil {
+		panic(errors.New(fmt.Sprintf("The response parser factory '%s' is invalid!", name)))
+	}
+	parserRegistry.Lock()
+	defer parserRegistry.Unlock()
+	if _, ok := parserRegistry.factories[name]; ok {
+		panic(errors.New(fmt.Sprintf("The response parser '%s' is already registered!", name)))
+	}
+	parserRegistry.factories[name] = factory
+}
+
+// 判断给定名称的响应解析函数是否已被注册。
+func HasParser(name string) bool {
+	parserRegistry.RLock()
+	defer parserRegistry.RUnlock()
+	_, ok := parserRegistry.factories[name]
+	return ok
+}
+
+// 根据名称和参数生成已注册的响应解析函数。
+func NewParser(name string, params base.Params) (ParseResponse, error) {
+	parserRegistry.RLock()
+	factory, ok := parserRegistry.factories[name]
+	parserRegistry.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown response parser '%s'! (available: %s)",
+			name, strings.Join(ParserNames(), ", ")))
+	}
+	parser, err := factory(params)
+	if err != nil {
+		return nil, err
+	}
+	if parser == nil {
+		return nil, errors.New(fmt.Sprintf("The response parser factory '%s' returns nil!", name))
+	}
+	return parser, nil
+}
+
+// 根据名称获得已注册的不带参数的响应解析函数。
+func GetParser(name string) (ParseResponse, error) {
+	return NewParser(name, nil)
+}
+
+// 具名的响应解析函数实例的集合。请求通过名称(见base.META_PARSERS)引用其中的实例，
+// 因此带参数的或经过配置的解析函数只需生成一次。与注册表中的名称相同时以其中的实例为准。
+type NamedParsers map[string]ParseResponse
+
+// 根据名称获得响应解析函数。不在集合中的名称会从注册表中获得不带参数的响应解析函数。
+func (np NamedParsers) Get(name string) (ParseResponse, error) {
+	if parser, ok := np[name]; ok && parser != nil {
+		return parser, nil
+	}
+	return GetParser(name)
+}
+
+// 检查集合的有效性。名称不能为空，实例不能为nil。
+func (np NamedParsers) Check() error {
+	argsErr := base.NewArgsError()
+	for name, parser := range np {
+		if name == "" {
+			argsErr.Add("", errors.New("The response parser name can not be empty!"))
+		} else if parser == nil {
+			argsErr.Add(name, errors.New("The response parser is invalid!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 获得所有已注册的响应解析函数的名称，按字典序排列。
+func ParserNames() []string {
+	parserRegistry.RLock()
+	defer parserRegistry.RUnlock()
+	names := make([]string, 0, len(parserRegistry.factories))
+	for name := range parserRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sys/fetch/base"
+)
+
+// 注册内置的路由器。
+func init() {
+	RegisterFactory("router", newRouterFromParams)
+}
+
+// 路由器的接口类型。它根据响应的URL或内容类型把响应分派给不同的解析函数，
+// 使列表页和详情页等不同页面可以分别由各自的解析函数处理。
+// 路由器的Parse方法本身就是一个响应解析函数。
+type Router interface {
+	// 添加路由。参数urlPattern是URL需匹配的正则表达式，
+	// 参数mediaType是响应需具有的内容类型，例如"text/html"或"application/*"。
+	// 两者为空时分别表示不限制。路由按添加的顺序匹配，第一个匹配的路由生效。
+	Route(urlPattern string, mediaType string, parser ParseResponse) error
+	// 设置没有路由匹配时使用的解析函数。为nil时不匹配的响应会被忽略。
+	SetDefault(parser ParseResponse)
+	// 解析响应。
+	Parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error)
+}
+
+// 路由。
+type route struct {
+	urlRegexp *regexp.Regexp // URL需匹配的正则表达式。
+	mediaType string         // 响应需具有的内容类型。
+	parser    ParseResponse  // 解析函数。
+}
+
+// 判断路由是否与响应匹配。
+func (r *route) match(httpResp *http.Response) bool {
+	if r.urlRegexp != nil && !r.urlRegexp.MatchString(httpResp.Request.URL.String()) {
+		return false
+	}
+	return r.mediaType == "" || matchMediaType(r.mediaType, mediaTypeOf(httpResp))
+}
+
+// 路由器的实现类型。
+type myRouter struct {
+	routes       []*route      // 路由的列表。
+	defaultParse ParseResponse // 默认的解析函数。
+	rwmutex      sync.RWMutex  // 读写锁。
+}
+
+// 创建路由器。
+func NewRouter() Router {
+	return &myRouter{routes: make([]*route, 0)}
+}
+
+func (router *myRouter) Route(urlPattern string, mediaType string, parser ParseResponse) error {
+	if parser == nil {
+		return errors.New("The response parser of the route is invalid!")
+	}
+	r := &route{mediaType: strings.ToLower(strings.TrimSpace(mediaType)), parser: parser}
+	if urlPattern != "" {
+		urlRegexp, err := regexp.Compile(urlPattern)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid url pattern '%s': %s", urlPattern, err))
+		}
+		r.urlRegexp = urlRegexp
+	}
+	router.rwmutex.Lock()
+	defer router.rwmutex.Unlock()
+	router.routes = append(router.routes, r)
+	return nil
+}
+
+func (router *myRouter) SetDefault(parser ParseResponse) {
+	router.rwmutex.Lock()
+	defer router.rwmutex.Unlock()
+	router.defaultParse = parser
+}
+
+func (router *myRouter) Parse(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	parser := router.parserFor(httpResp)
+	if parser == nil {
+		if httpResp.Body != nil {
+			httpResp.Body.Close()
+		}
+		return nil, nil
+	}
+	return parser(httpResp, respDepth)
+}
+
+// 获得与响应匹配的解析函数。
+func (router *myRouter) parserFor(httpResp *http.Response) ParseResponse {
+	router.rwmutex.RLock()
+	defer router.rwmutex.RUnlock()
+	for _, r := range router.routes {
+		if r.match(httpResp) {
+			return r.parser
+		}
+	}
+	return router.defaultParse
+}
+
+// 获得响应的内容类型，不包括参数部分。
+func mediaTypeOf(httpResp *http.Response) string {
+	contentType := httpResp.Header.Get("Content-Type")
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mediaType
+}
+
+// 判断内容类型是否与模式匹配。模式中的子类型可以是"*"。
+func matchMediaType(pattern string, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+// 根据参数生成路由器。参数routes是路由的列表，其中每个路由包含
+// url(URL需匹配的正则表达式)、contentType(内容类型)、parser(已注册的解析函数的名称)
+// 和params(解析函数的参数)。参数default是没有路由匹配时使用的解析函数的名称。
+func newRouterFromParams(params base.Params) (ParseResponse, error) {
+	routeParamsList, err := params.ParamsList("routes")
+	if err != nil {
+		return nil, err
+	}
+	router := NewRouter()
+	argsErr := base.NewArgsError()
+	for i, routeParams := range routeParamsList {
+		field := fmt.Sprintf("routes[%d]", i)
+		parser, err := parserFromParams(routeParams)
+		if err != nil {
+			argsErr.Add(field, err)
+			continue
+		}
+		urlPattern, err := routeParams.String("url", "")
+		if err != nil {
+			argsErr.Add(field, err)
+			continue
+		}
+		mediaType, err := routeParams.String("contentType", "")
+		if err != nil {
+			argsErr.Add(field, err)
+			continue
+		}
+		if err := router.Route(urlPattern, mediaType, parser); err != nil {
+			argsErr.Add(field, err)
+		}
+	}
+	defaultName, err := params.String("default", "")
+	if err != nil {
+		argsErr.Add("default", err)
+	} else if defaultName != "" {
+		parser, err := GetParser(defaultName)
+		if err != nil {
+			argsErr.Add("default", err)
+		} else {
+			router.SetDefault(parser)
+		}
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return router.Parse, nil
+}
+
+// 根据路由的参数生成解析函数。
+func parserFromParams(routeParams base.Params) (ParseResponse, error) {
+	name, err := routeParams.String("parser", "")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, &base.FieldError{Field: "parser", Err: errors.New("The parser name is required!")}
+	}
+	parserParams, err := routeParams.Params("params")
+	if err != nil {
+		return nil, err
+	}
+	parser, err := NewParser(name, parserParams)
+	if err != nil {
+		return nil, &base.FieldError{Field: "parser", Err: err}
+	}
+	return parser, nil
+}
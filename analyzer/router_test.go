@@ -0,0 +1,173 @@
+package analyzer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+// 生成把响应体作为条目返回的解析函数。
+func genEchoParser(name string) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		body, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		item := base.Item{"parser": name, "body": string(body)}
+		return []base.Data{&item}, nil
+	}
+}
+
+func newTestResponse(url string, contentType string, body string, meta base.Metadata) base.Response {
+	return *base.NewResponseWithMeta(newTestHttpResponse(url, contentType, body, meta), 0, meta)
+}
+
+// 生成测试用的HTTP响应。元数据会被放入请求的上下文中。
+func newTestHttpResponse(url string, contentType string, body string, meta base.Metadata) *http.Response {
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	if meta != nil {
+		httpReq = httpReq.WithContext(base.ContextWithMeta(context.Background(), meta))
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    httpReq,
+	}
+}
+
+// 获得生成了条目的解析函数的名称。错误和不完整的响应体也会被记录在结果中。
+func parsedBy(dataList []base.Data, errs []error) []string {
+	names := make([]string, 0)
+	for _, data := range dataList {
+		item := *data.(*base.Item)
+		name := item["parser"].(string)
+		if item["body"] != "hello" {
+			name += "(body=" + item["body"].(string) + ")"
+		}
+		names = append(names, name)
+	}
+	for _, err := range errs {
+		names = append(names, "error: "+err.Error())
+	}
+	return names
+}
+
+func TestAnalyzeNamedParsers(t *testing.T) {
+	Register("router-test-list", genEchoParser("list"))
+	Register("router-test-detail", genEchoParser("detail"))
+	analyzer := NewAnalyzer()
+	defaults := []ParseResponse{genEchoParser("default1"), genEchoParser("default2")}
+
+	resp := newTestResponse("http://example.com/", "text/html", "hello", nil)
+	names := parsedBy(analyzer.Analyze(defaults, resp))
+	if strings.Join(names, ",") != "default1,default2" {
+		t.Errorf("Unexpected parsers %v for a request without parser names!", names)
+	}
+
+	meta := base.Metadata{base.META_PARSERS: []string{"router-test-detail"}}
+	resp = newTestResponse("http://example.com/item/1", "text/html", "hello", meta)
+	names = parsedBy(analyzer.Analyze(defaults, resp))
+	if strings.Join(names, ",") != "detail" {
+		t.Errorf("Unexpected parsers %v for a request naming its parser!", names)
+	}
+
+	meta = base.Metadata{base.META_PARSERS: []string{"router-test-missing"}}
+	resp = newTestResponse("http://example.com/", "text/html", "hello", meta)
+	if _, errs := analyzer.Analyze(defaults, resp); len(errs) != 1 {
+		t.Errorf("Expected an error for an unknown parser name, but got %v!", errs)
+	}
+}
+
+// 工厂函数"router-test-configured"被调用的次数。
+var configuredParserBuilds int
+
+func init() {
+	Register("router-test-builtin", genEchoParser("builtin"))
+	Register("router-test-plain", genEchoParser("plain"))
+	RegisterFactory("router-test-configured", func(params base.Params) (ParseResponse, error) {
+		configuredParserBuilds++
+		label, err := params.String("label", "")
+		if err != nil {
+			return nil, err
+		}
+		return genEchoParser("configured:" + label), nil
+	})
+}
+
+// 请求指定的名称先在具名的实例中查找，然后才在注册表中查找。实例只被生成一次。
+func TestAnalyzeNamedParserInstances(t *testing.T) {
+	configuredParserBuilds = 0
+	configured, err := NewParser("router-test-configured", base.Params{"label": "news"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	named := NamedParsers{"news": configured, "router-test-builtin": genEchoParser("override")}
+	if err := named.Check(); err != nil {
+		t.Fatal(err)
+	}
+	analyzer := NewAnalyzer(WithNamedParsers(named))
+	defaults := []ParseResponse{genEchoParser("default")}
+	for _, url := range []string{"http://example.com/1", "http://example.com/2"} {
+		meta := base.Metadata{base.META_PARSERS: []string{"news", "router-test-builtin"}}
+		names := parsedBy(analyzer.Analyze(defaults, newTestResponse(url, "text/html", "hello", meta)))
+		if strings.Join(names, ",") != "configured:news,override" {
+			t.Errorf("Unexpected parsers %v for the named instances!", names)
+		}
+	}
+	if configuredParserBuilds != 1 {
+		t.Errorf("The configured parser should be built once, but it's built %d times!", configuredParserBuilds)
+	}
+
+	//不带参数的内置解析函数仍可从注册表中获得
+	meta := base.Metadata{base.META_PARSERS: []string{"router-test-plain"}}
+	names := parsedBy(analyzer.Analyze(defaults, newTestResponse("http://example.com/", "text/html", "hello", meta)))
+	if strings.Join(names, ",") != "plain" {
+		t.Errorf("Unexpected parsers %v for a registered parser!", names)
+	}
+	if err := (NamedParsers{"": configured, "nil": nil}).Check(); err == nil ||
+		len(err.(*base.ArgsError).Errors()) != 2 {
+		t.Errorf("Expected errors for the empty name and the nil parser, but got %v!", err)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	parser, err := NewParser("router", base.Params{
+		"routes": []interface{}{
+			map[string]interface{}{"url": "/item/\\d+$", "parser": "router-test-detail"},
+			map[string]interface{}{"contentType": "application/*", "parser": "router-test-list"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Can not create the router: %s", err)
+	}
+	analyzer := NewAnalyzer()
+	cases := []struct {
+		url         string
+		contentType string
+		expected    string
+	}{
+		{"http://example.com/item/12", "text/html; charset=utf-8", "detail"},
+		{"http://example.com/list", "application/json", "list"},
+		{"http://example.com/list", "text/html", ""},
+	}
+	for _, c := range cases {
+		resp := newTestResponse(c.url, c.contentType, "hello", nil)
+		names := parsedBy(analyzer.Analyze([]ParseResponse{parser}, resp))
+		if strings.Join(names, ",") != c.expected {
+			t.Errorf("Unexpected parsers %v for %s (%s)!", names, c.url, c.contentType)
+		}
+	}
+
+	_, err = NewParser("router", base.Params{
+		"routes": []interface{}{map[string]interface{}{"url": "(", "parser": "a-tags"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "routes[0]") {
+		t.Errorf("Expected an error for routes[0], but got %v!", err)
+	}
+}
@@ -0,0 +1,434 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sys/fetch/base"
+)
+
+// 规则集。它描述了如何从页面中提取条目以及需要跟随的链接。
+// 规则中选择器的语法取决于使用它的提取器，例如CSS选择器或XPath表达式。
+type RuleSet struct {
+	Items  []ItemRule   // 条目提取规则的列表。
+	Follow []FollowRule // 链接跟随规则的列表。
+}
+
+// 链接跟随规则。它描述了如何从页面中提取需要继续爬取的链接。
+type FollowRule struct {
+	Selector string         // 链接的选择器。
+	Attr     string         // 要读取的属性。对于CSS提取器，为空时读取href属性。
+	Url      *regexp.Regexp // 页面的URL需匹配的正则表达式。为nil时适用于全部页面。
+	Parsers  []string       // 生成的请求所指定的解析函数的名称。
+}
+
+// 条目提取规则。它描述了如何从页面中提取某一类型的条目。
+// 规则中选择器的语法取决于使用它的提取器，例如CSS选择器或XPath表达式。
+type ItemRule struct {
+	Type   string         // 条目的类型，会被记录在条目的base.ITEM_TYPE_KEY字段中。
+	Url    *regexp.Regexp // 页面的URL需匹配的正则表达式。为nil时适用于全部页面。
+	Scope  string         // 条目的范围的选择器。每个匹配的元素生成一个条目，为空时每个页面生成一个条目。
+	Fields []FieldRule    // 字段提取规则的列表。
+}
+
+// 字段提取规则。
+type FieldRule struct {
+	Name     string         // 字段名。
+	Selector string         // 相对于条目范围的选择器。为空时使用条目范围本身。
+	Attr     string         // 要读取的属性。为空时读取元素的文本。
+	Regex    *regexp.Regexp // 对值进行过滤的正则表达式。有分组时取第一个分组，不匹配的值会被忽略。
+	Type     base.ValueType // 值的类型。
+	Layout   string         // 类型为base.TYPE_DATE时所用的时间格式，为空时尝试常见的格式。
+	List     bool           // 是否提取全部匹配的值。为false时只提取第一个值。
+	Required bool           // 是否为必需的字段。缺少必需字段的条目会被丢弃。
+}
+
+// 检查条目提取规则的有效性。
+func (rule *ItemRule) Check() error {
+	argsErr := base.NewArgsError()
+	if rule.Type == "" {
+		argsErr.Add("type", errors.New("The item type can not be empty!"))
+	}
+	if len(rule.Fields) == 0 {
+		argsErr.Add("fields", errors.New("The item rule has no fields!"))
+	}
+	names := make(map[string]bool)
+	for i, field := range rule.Fields {
+		fieldPath := fmt.Sprintf("fields[%d]", i)
+		if field.Name == "" {
+			argsErr.Add(fieldPath, errors.New("The field name can not be empty!"))
+		} else if names[field.Name] {
+			argsErr.Add(fieldPath, errors.New(fmt.Sprintf("Duplicate field name '%s'!", field.Name)))
+		}
+		names[field.Name] = true
+		if !field.Type.Valid() {
+			argsErr.Add(fieldPath, errors.New(fmt.Sprintf("Unsupported value type '%s'!", field.Type)))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 检查链接跟随规则的有效性。
+func (rule *FollowRule) Check() error {
+	argsErr := base.NewArgsError()
+	if rule.Selector == "" {
+		argsErr.Add("selector", errors.New("The selector can not be empty!"))
+	}
+	//名称在分析时才从具名的解析函数实例或注册表中获得，这里只能检查它是否为空
+	for i, name := range rule.Parsers {
+		if name == "" {
+			argsErr.Add(fmt.Sprintf("parsers[%d]", i),
+				errors.New("The response parser name can not be empty!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 检查规则集的有效性。
+func (ruleSet *RuleSet) Check() error {
+	if len(ruleSet.Items) == 0 && len(ruleSet.Follow) == 0 {
+		return errors.New("The rule set has neither item rules nor follow rules!")
+	}
+	argsErr := base.NewArgsError()
+	for i := range ruleSet.Items {
+		argsErr.Add(fmt.Sprintf("items[%d]", i), ruleSet.Items[i].Check())
+	}
+	for i := range ruleSet.Follow {
+		argsErr.Add(fmt.Sprintf("follow[%d]", i), ruleSet.Follow[i].Check())
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 检查规则集中的全部选择器。参数check被用来检查单个选择器。
+func (ruleSet *RuleSet) checkSelectors(check func(selector string) error) error {
+	argsErr := base.NewArgsError()
+	add := func(field string, selector string) {
+		if selector == "" {
+			return
+		}
+		argsErr.Add(field, check(selector))
+	}
+	for i, rule := range ruleSet.Items {
+		add(fmt.Sprintf("items[%d].scope", i), rule.Scope)
+		for j, field := range rule.Fields {
+			add(fmt.Sprintf("items[%d].fields[%d].selector", i, j), field.Selector)
+		}
+	}
+	for i, rule := range ruleSet.Follow {
+		add(fmt.Sprintf("follow[%d].selector", i), rule.Selector)
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 根据从页面中提取出的原始值生成字段的值。没有可用的值时第二个结果为false。
+// 不是字符串的原始值(例如JSON中的数字)在不需要过滤和转换时会被原样保留。
+func (field *FieldRule) value(raws []interface{}, baseUrl *url.URL) (interface{}, bool, error) {
+	values := make([]interface{}, 0, len(raws))
+	for _, raw := range raws {
+		if raw == nil {
+			continue
+		}
+		if _, ok := raw.(string); !ok && field.Regex == nil && field.Type == "" {
+			values = append(values, raw)
+		} else {
+			s, ok := field.filter(valueString(raw))
+			if !ok {
+				continue
+			}
+			v, err := base.CoerceValue(s, field.Type, field.Layout, baseUrl)
+			if err != nil {
+				return nil, false, &base.FieldError{Field: field.Name, Err: err}
+			}
+			values = append(values, v)
+		}
+		if !field.List {
+			break
+		}
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	if field.List {
+		return values, true, nil
+	}
+	return values[0], true, nil
+}
+
+// 获得原始值的字符串形式。
+func valueString(raw interface{}) string {
+	switch v := raw.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Sprint(raw)
+	}
+	return string(data)
+}
+
+// 用正则表达式过滤原始值。
+func (field *FieldRule) filter(raw string) (string, bool) {
+	if field.Regex == nil {
+		return raw, true
+	}
+	match := field.Regex.FindStringSubmatch(raw)
+	if match == nil {
+		return "", false
+	}
+	if len(match) > 1 {
+		return match[1], true
+	}
+	return match[0], true
+}
+
+// 可被条目提取规则使用的文档。不同的提取器以不同的选择器语法实现它。
+type ruleDocument interface {
+	// 获得与选择器匹配的条目范围。选择器为空时返回整个文档。
+	scopes(selector string) []ruleNode
+}
+
+// 可被条目提取规则使用的文档节点。
+type ruleNode interface {
+	// 获得与选择器匹配的元素的文本或属性值。选择器为空时使用节点本身。
+	values(selector string, attr string) []interface{}
+}
+
+// 根据规则集从文档中提取条目和需要跟随的请求。
+// 参数pageUrl是页面的URL，参数baseUrl是解析相对URL时所依据的URL。
+func extractByRules(doc ruleDocument, ruleSet *RuleSet,
+	pageUrl *url.URL, baseUrl *url.URL, respDepth uint32) ([]base.Data, []error) {
+	dataList := make([]base.Data, 0)
+	errs := make([]error, 0)
+	for i := range ruleSet.Items {
+		rule := &ruleSet.Items[i]
+		if rule.Url != nil && !rule.Url.MatchString(pageUrl.String()) {
+			continue
+		}
+		for _, node := range doc.scopes(rule.Scope) {
+			item, err := rule.extract(node, pageUrl, baseUrl)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if item != nil {
+				dataList = append(dataList, &item)
+			}
+		}
+	}
+	root := doc.scopes("")[0]
+	seen := make(map[string]bool)
+	for i := range ruleSet.Follow {
+		rule := &ruleSet.Follow[i]
+		if rule.Url != nil && !rule.Url.MatchString(pageUrl.String()) {
+			continue
+		}
+		for _, value := range root.values(rule.Selector, rule.Attr) {
+			link, ok := resolveLink(baseUrl, valueString(value))
+			if !ok || seen[link] {
+				continue
+			}
+			seen[link] = true
+			httpReq, err := http.NewRequest("GET", link, nil)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			req := base.NewRequest(httpReq, respDepth+1)
+			if len(rule.Parsers) > 0 {
+				req.SetParsers(rule.Parsers...)
+			}
+			dataList = append(dataList, req)
+		}
+	}
+	return dataList, errs
+}
+
+// 从条目范围中提取一个条目。缺少必需字段时不返回条目。
+func (rule *ItemRule) extract(node ruleNode, pageUrl *url.URL, baseUrl *url.URL) (base.Item, error) {
+	item := base.Item{base.ITEM_TYPE_KEY: rule.Type}
+	argsErr := base.NewArgsError()
+	missing := make([]string, 0)
+	for i := range rule.Fields {
+		field := &rule.Fields[i]
+		v, ok, err := field.value(node.values(field.Selector, field.Attr), baseUrl)
+		argsErr.Add("", err)
+		if !ok {
+			if field.Required {
+				missing = append(missing, field.Name)
+			}
+			continue
+		}
+		item[field.Name] = v
+	}
+	if len(missing) > 0 {
+		argsErr.Add("", errors.New(fmt.Sprintf("Missing required fields %s!",
+			strings.Join(missing, ", "))))
+		item = nil
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return item, errors.New(fmt.Sprintf(
+			"Can not extract the item '%s' (url=%s): %s", rule.Type, pageUrl, err))
+	}
+	return item, nil
+}
+
+// 根据参数生成规则集。参数items是条目提取规则的列表，其中每个规则包含
+// type、url(正则表达式)、scope和fields。fields是字段名到字段规则的字典，
+// 字段规则可以是一个选择器，也可以包含selector、attr、regex、type、layout、list和required。
+// 参数follow是链接跟随规则的列表，其中每个规则包含selector、attr、url和parsers。
+func ruleSetFromParams(params base.Params) (RuleSet, error) {
+	ruleSet := RuleSet{}
+	argsErr := base.NewArgsError()
+	itemParamsList, err := params.ParamsList("items")
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	for i, itemParams := range itemParamsList {
+		rule, err := itemRuleFromParams(itemParams)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("items[%d]", i), err)
+			continue
+		}
+		ruleSet.Items = append(ruleSet.Items, rule)
+	}
+	followParamsList, err := params.ParamsList("follow")
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	for i, followParams := range followParamsList {
+		rule, err := followRuleFromParams(followParams)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("follow[%d]", i), err)
+			continue
+		}
+		ruleSet.Follow = append(ruleSet.Follow, rule)
+	}
+	return ruleSet, argsErr.ErrorOrNil()
+}
+
+// 根据参数生成链接跟随规则。
+func followRuleFromParams(params base.Params) (FollowRule, error) {
+	rule := FollowRule{}
+	argsErr := base.NewArgsError()
+	var err error
+	if rule.Selector, err = params.String("selector", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if rule.Attr, err = params.String("attr", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if rule.Url, err = regexpParam(params, "url"); err != nil {
+		argsErr.Add("", err)
+	}
+	if rule.Parsers, err = params.Strings("parsers"); err != nil {
+		argsErr.Add("", err)
+	}
+	return rule, argsErr.ErrorOrNil()
+}
+
+// 根据参数生成条目提取规则。
+func itemRuleFromParams(params base.Params) (ItemRule, error) {
+	rule := ItemRule{}
+	argsErr := base.NewArgsError()
+	var err error
+	if rule.Type, err = params.String("type", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if rule.Scope, err = params.String("scope", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if rule.Url, err = regexpParam(params, "url"); err != nil {
+		argsErr.Add("", err)
+	}
+	fieldsParams, err := params.Params("fields")
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	names := make([]string, 0, len(fieldsParams))
+	for name := range fieldsParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, err := fieldRuleFromParam(name, fieldsParams[name])
+		if err != nil {
+			argsErr.Add("fields."+name, err)
+			continue
+		}
+		rule.Fields = append(rule.Fields, field)
+	}
+	return rule, argsErr.ErrorOrNil()
+}
+
+// 根据参数生成字段提取规则。
+func fieldRuleFromParam(name string, param interface{}) (FieldRule, error) {
+	field := FieldRule{Name: name}
+	if selector, ok := param.(string); ok {
+		field.Selector = selector
+		return field, nil
+	}
+	var params base.Params
+	switch p := param.(type) {
+	case base.Params:
+		params = p
+	case map[string]interface{}:
+		params = base.Params(p)
+	default:
+		return field, errors.New(fmt.Sprintf(
+			"The field rule should be a selector or a map, but it's %T!", param))
+	}
+	argsErr := base.NewArgsError()
+	var err error
+	if field.Selector, err = params.String("selector", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if field.Attr, err = params.String("attr", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if field.Regex, err = regexpParam(params, "regex"); err != nil {
+		argsErr.Add("", err)
+	}
+	var vt string
+	if vt, err = params.String("type", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	field.Type = base.ValueType(vt)
+	if field.Layout, err = params.String("layout", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if field.List, err = params.Bool("list", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if field.Required, err = params.Bool("required", false); err != nil {
+		argsErr.Add("", err)
+	}
+	return field, argsErr.ErrorOrNil()
+}
+
+// 获得正则表达式参数。参数不存在时返回nil。
+func regexpParam(params base.Params, key string) (*regexp.Regexp, error) {
+	pattern, err := params.String(key, "")
+	if err != nil || pattern == "" {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &base.FieldError{Field: key, Err: err}
+	}
+	return re, nil
+}
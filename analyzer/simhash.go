@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 计算指纹时每个特征包含的词的数量。
+const simHashShingleSize = 3
+
+// 近似重复页面的索引的接口类型。分析器在解析响应之前查询它，近似重复的页面不会被解析。
+type NearDuplicateIndex interface {
+	// 查找与给定指纹近似的页面。找到时返回该页面的URL和true，
+	// 否则把指纹和URL加入索引并返回false。同一URL的页面不会被当作重复。
+	CheckAndAdd(fingerprint uint64, url string) (string, bool)
+	// 获得索引中的指纹的数量。
+	Len() int
+}
+
+// 创建基于SimHash的近似重复页面的索引。两个指纹的海明距离不超过maxDistance时，
+// 它们对应的页面被认为是近似重复的。maxDistance的取值范围是[0, 31]。
+func NewSimHashIndex(maxDistance int) (NearDuplicateIndex, error) {
+	if maxDistance < 0 || maxDistance > 31 {
+		return nil, errors.New(fmt.Sprintf(
+			"Invalid max Hamming distance %d! It should be between 0 and 31.", maxDistance))
+	}
+	//把64位指纹分为maxDistance+1段，海明距离不超过maxDistance的两个指纹至少有一段相同
+	bands := maxDistance + 1
+	index := &mySimHashIndex{
+		maxDistance: maxDistance,
+		masks:       make([]uint64, bands),
+		shifts:      make([]uint, bands),
+		tables:      make([]map[uint64][]int, bands),
+	}
+	start := 0
+	for i := 0; i < bands; i++ {
+		width := 64 / bands
+		if i < 64%bands {
+			width++
+		}
+		index.shifts[i] = uint(start)
+		index.masks[i] = ^uint64(0)
+		if width < 64 {
+			index.masks[i] = uint64(1)<<uint(width) - 1
+		}
+		index.tables[i] = make(map[uint64][]int)
+		start += width
+	}
+	return index, nil
+}
+
+// 近似重复页面的索引的实现类型。
+type mySimHashIndex struct {
+	maxDistance  int                // 最大的海明距离。
+	masks        []uint64           // 每段的掩码。
+	shifts       []uint             // 每段的起始位。
+	tables       []map[uint64][]int // 每段的值到指纹序号的映射。
+	fingerprints []uint64           // 已加入的指纹。
+	urls         []string           // 已加入的指纹所对应的URL。
+	mutex        sync.Mutex         // 互斥锁。
+}
+
+func (index *mySimHashIndex) CheckAndAdd(fingerprint uint64, url string) (string, bool) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	for i, table := range index.tables {
+		key := (fingerprint >> index.shifts[i]) & index.masks[i]
+		for _, n := range table[key] {
+			if bits.OnesCount64(index.fingerprints[n]^fingerprint) > index.maxDistance {
+				continue
+			}
+			if index.urls[n] == url {
+				return "", false
+			}
+			return index.urls[n], true
+		}
+	}
+	n := len(index.fingerprints)
+	index.fingerprints = append(index.fingerprints, fingerprint)
+	index.urls = append(index.urls, url)
+	for i, table := range index.tables {
+		key := (fingerprint >> index.shifts[i]) & index.masks[i]
+		table[key] = append(table[key], n)
+	}
+	return "", false
+}
+
+func (index *mySimHashIndex) Len() int {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	return len(index.fingerprints)
+}
+
+// 计算文本的SimHash指纹。特征是连续的simHashShingleSize个词，
+// 中日韩文字中的每个字被当作一个词。文本中没有词时返回false。
+func SimHash(text string) (uint64, bool) {
+	words := simHashWords(text)
+	if len(words) == 0 {
+		return 0, false
+	}
+	var weights [64]int
+	hasher := fnv.New64a()
+	for i := 0; i == 0 || i+simHashShingleSize <= len(words); i++ {
+		end := i + simHashShingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		hasher.Reset()
+		hasher.Write([]byte(strings.Join(words[i:end], " ")))
+		h := hasher.Sum64()
+		for b := 0; b < 64; b++ {
+			if h&(uint64(1)<<uint(b)) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+	var fingerprint uint64
+	for b := 0; b < 64; b++ {
+		if weights[b] > 0 {
+			fingerprint |= uint64(1) << uint(b)
+		}
+	}
+	return fingerprint, true
+}
+
+// 把文本切分为小写的词。
+func simHashWords(text string) []string {
+	words := make([]string, 0)
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			words = append(words, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// 计算HTML页面中可见的文本的指纹。其他内容(如站点地图、订阅源和JSON接口)不计算指纹，此时返回false。
+func responseFingerprint(httpResp *http.Response, body []byte) (uint64, bool) {
+	mediaType := mediaTypeOf(httpResp)
+	if !isHtml(mediaType) && !(mediaType == "" && looksLikeHtml(body)) {
+		return 0, false
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+	doc.Find("script,style,noscript,template").Remove()
+	return SimHash(doc.Find("body").Text())
+}
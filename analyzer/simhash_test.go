@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const simHashTestArticle = `Green tea should be brewed with water that is well below boiling, around eighty
+degrees, for about two minutes. Use two grams of leaves per cup and do not squeeze the leaves, or the
+tea will turn bitter. Many varieties such as sencha, gyokuro and matcha need their own temperatures,
+times and amounts, so it is worth reading the label of every package before brewing it.`
+
+func TestSimHash(t *testing.T) {
+	a, _ := SimHash(simHashTestArticle)
+	b, _ := SimHash(strings.Replace(simHashTestArticle, "two minutes", "three minutes", 1) + " Print view.")
+	c, _ := SimHash("The quarterly report shows revenue growth in all regions, driven by new subscriptions.")
+	if d := bits.OnesCount64(a ^ b); d > 10 {
+		t.Errorf("Unexpected distance %d between near-duplicate texts!", d)
+	}
+	if d := bits.OnesCount64(a ^ c); d <= 10 {
+		t.Errorf("Unexpected distance %d between different texts!", d)
+	}
+	if _, ok := SimHash(" ,. "); ok {
+		t.Errorf("Expected no fingerprint for a text without words!")
+	}
+	if x, _ := SimHash("绿茶应该用八十度左右的水冲泡两分钟"); x == 0 {
+		t.Errorf("Expected a fingerprint for a CJK text!")
+	}
+}
+
+func TestSimHashIndex(t *testing.T) {
+	index, err := NewSimHashIndex(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, dup := index.CheckAndAdd(0xF0F0F0F0F0F0F0F0, "http://example.com/a"); dup {
+		t.Errorf("The first page should not be a duplicate!")
+	}
+	//距离为3，四段中只有一段相同
+	near := uint64(0xF0F0F0F0F0F0F0F0) ^ (1 | 1<<30 | 1<<60)
+	if dupOf, dup := index.CheckAndAdd(near, "http://example.com/a?print=1"); !dup || dupOf != "http://example.com/a" {
+		t.Errorf("Expected a duplicate of a, but got %q (%v)!", dupOf, dup)
+	}
+	if _, dup := index.CheckAndAdd(near^(1<<10|1<<40), "http://example.com/b"); dup {
+		t.Errorf("A page with distance 5 should not be a duplicate!")
+	}
+	if _, dup := index.CheckAndAdd(0xF0F0F0F0F0F0F0F0, "http://example.com/a"); dup {
+		t.Errorf("The same url should not be a duplicate of itself!")
+	}
+	if index.Len() != 2 {
+		t.Errorf("Unexpected index length %d!", index.Len())
+	}
+	if _, err := NewSimHashIndex(32); err == nil {
+		t.Errorf("Expected an error for the too large distance!")
+	}
+}
+
+func TestAnalyzeNearDuplicates(t *testing.T) {
+	index, _ := NewSimHashIndex(3)
+	analyzer := NewAnalyzer(WithNearDuplicateIndex(index))
+	parsers := []ParseResponse{genItemAndLinkParser()}
+	page := "<html><body><p>" + simHashTestArticle + "</p><script>var session = 1;</script></body></html>"
+	resp := newTestResponse("http://example.com/tea", "text/html", page, base.Metadata{})
+	if items, reqs := countData(analyzer.Analyze(parsers, resp)); items != 1 || reqs != 1 {
+		t.Errorf("The first page should be parsed!")
+	}
+	page = strings.Replace(page, "var session = 1", "var session = 2", 1)
+	resp = newTestResponse("http://example.com/tea?sid=2", "text/html", page, base.Metadata{})
+	if items, reqs := countData(analyzer.Analyze(parsers, resp)); items != 0 || reqs != 0 {
+		t.Errorf("The near-duplicate page should be skipped!")
+	}
+	if dupOf := resp.Meta().String(base.META_DUPLICATE_OF); dupOf != "http://example.com/tea" {
+		t.Errorf("Unexpected duplicate of %q!", dupOf)
+	}
+	resp = newTestResponse("http://example.com/tea.json", "application/json", `{"a": 1}`, base.Metadata{})
+	if items, _ := countData(analyzer.Analyze(parsers, resp)); items != 1 {
+		t.Errorf("A response that is not HTML should not be fingerprinted!")
+	}
+}
@@ -0,0 +1,237 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"sys/fetch/base"
+)
+
+// 站点地图解析函数和robots.txt中站点地图的解析函数的注册名称。
+const (
+	SITEMAP_PARSER        = "sitemap"
+	ROBOTS_SITEMAP_PARSER = "robots-sitemaps"
+)
+
+// 注册内置的站点地图解析函数。
+func init() {
+	Register(SITEMAP_PARSER, ParseSitemap)
+	Register(ROBOTS_SITEMAP_PARSER, ParseRobotsSitemaps)
+}
+
+// 站点地图的解析函数。它支持站点地图索引、urlset、gzip压缩的站点地图和每行一个URL的文本站点地图。
+// 站点地图索引中的每个站点地图会生成一个由本函数解析的请求；
+// urlset中的每个<loc>会生成一个种子请求，lastmod、changefreq和priority被记录在请求的元数据中。
+// 站点地图不存在(404)时不会产生错误。
+func ParseSitemap(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if httpResp.StatusCode != 200 {
+		err := errors.New(
+			fmt.Sprintf("Unsupported status code %d. (url=%s)",
+				httpResp.StatusCode, httpResp.Request.URL))
+		return nil, []error{err}
+	}
+	body, err := readSitemapBody(httpResp.Body)
+	if err != nil {
+		return nil, []error{errors.New(fmt.Sprintf(
+			"Can not read the sitemap (url=%s): %s", httpResp.Request.URL, err))}
+	}
+	entries, err := parseSitemapEntries(body)
+	if err != nil {
+		return nil, []error{errors.New(fmt.Sprintf(
+			"Can not parse the sitemap (url=%s): %s", httpResp.Request.URL, err))}
+	}
+	pageUrl := httpResp.Request.URL
+	dataList := make([]base.Data, 0, len(entries))
+	errs := make([]error, 0)
+	for _, entry := range entries {
+		req, err := entry.request(pageUrl)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if req != nil {
+			dataList = append(dataList, req)
+		}
+	}
+	return dataList, errs
+}
+
+// robots.txt的解析函数。它为其中每个"Sitemap:"行生成一个由站点地图解析函数解析的请求。
+// robots.txt不存在(404)时不会产生错误。
+func ParseRobotsSitemaps(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if httpResp.StatusCode != 200 {
+		err := errors.New(
+			fmt.Sprintf("Unsupported status code %d. (url=%s)",
+				httpResp.StatusCode, httpResp.Request.URL))
+		return nil, []error{err}
+	}
+	dataList := make([]base.Data, 0)
+	errs := make([]error, 0)
+	scanner := bufio.NewScanner(httpResp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		colon := strings.Index(line, ":")
+		if colon < 0 || !strings.EqualFold(strings.TrimSpace(line[:colon]), "sitemap") {
+			continue
+		}
+		entry := &sitemapEntry{loc: strings.TrimSpace(line[colon+1:]), index: true}
+		req, err := entry.request(httpResp.Request.URL)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if req != nil {
+			dataList = append(dataList, req)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return dataList, errs
+}
+
+// 读取站点地图。gzip压缩的内容会被解压。
+func readSitemapBody(reader io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 2 || body[0] != 0x1f || body[1] != 0x8b {
+		return body, nil
+	}
+	gzReader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gzReader.Close()
+	return io.ReadAll(gzReader)
+}
+
+// 站点地图中的条目。
+type sitemapEntry struct {
+	loc        string // URL。
+	index      bool   // 是否为站点地图索引中的站点地图。
+	lastmod    string // 最后修改时间。
+	changefreq string // 更新频率。
+	priority   string // 优先级。
+}
+
+// 根据条目生成请求。URL为空时返回nil。
+// 优先级无效时仍会返回请求，只是其中没有优先级，同时返回的错误不是致命的。
+func (entry *sitemapEntry) request(pageUrl *url.URL) (*base.Request, error) {
+	if entry.loc == "" {
+		return nil, nil
+	}
+	locUrl, err := url.Parse(entry.loc)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid sitemap url '%s': %s", entry.loc, err))
+	}
+	locUrl = pageUrl.ResolveReference(locUrl)
+	httpReq, err := http.NewRequest("GET", locUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req := base.NewRequest(httpReq, 0)
+	req.SetMeta(base.META_SEED, true)
+	if entry.index {
+		req.SetParsers(SITEMAP_PARSER)
+		return req, nil
+	}
+	req.SetMeta(base.META_SEED_ID, locUrl.String())
+	req.SetMeta(base.META_SITEMAP, pageUrl.String())
+	if entry.lastmod != "" {
+		req.SetMeta(base.META_LASTMOD, entry.lastmod)
+	}
+	if entry.changefreq != "" {
+		req.SetMeta(base.META_CHANGEFREQ, entry.changefreq)
+	}
+	if entry.priority != "" {
+		priority, err := strconv.ParseFloat(entry.priority, 64)
+		if err != nil {
+			return req, errors.New(fmt.Sprintf(
+				"Invalid sitemap priority '%s' of %s!", entry.priority, locUrl))
+		}
+		req.SetMeta(base.META_PRIORITY, priority)
+	}
+	return req, nil
+}
+
+// 解析站点地图中的条目。不是XML的内容会被当作每行一个URL的文本站点地图。
+func parseSitemapEntries(body []byte) ([]*sitemapEntry, error) {
+	trimmed := bytes.TrimSpace(body)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		entries := make([]*sitemapEntry, 0)
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				entries = append(entries, &sitemapEntry{loc: line})
+			}
+		}
+		return entries, nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	entries := make([]*sitemapEntry, 0)
+	var current *sitemapEntry
+	var field string
+	depth, entryDepth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case current == nil && t.Name.Local == "url":
+				current, entryDepth = &sitemapEntry{}, depth
+			case current == nil && t.Name.Local == "sitemap":
+				current, entryDepth = &sitemapEntry{index: true}, depth
+			case current != nil && depth == entryDepth+1:
+				//只读取条目的直接子元素，忽略图片等扩展中的<loc>
+				field = t.Name.Local
+			}
+		case xml.CharData:
+			if current == nil {
+				continue
+			}
+			value := strings.TrimSpace(string(t))
+			switch field {
+			case "loc":
+				current.loc += value
+			case "lastmod":
+				current.lastmod += value
+			case "changefreq":
+				current.changefreq += value
+			case "priority":
+				current.priority += value
+			}
+		case xml.EndElement:
+			field = ""
+			if current != nil && depth == entryDepth {
+				entries = append(entries, current)
+				current = nil
+			}
+			depth--
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const sitemapTestIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://example.com/sitemap-1.xml.gz</loc><lastmod>2024-01-01</lastmod></sitemap>
+</sitemapindex>`
+
+const sitemapTestUrlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>http://example.com/a</loc>
+    <lastmod>2024-02-03</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>0.8</priority>
+    <image:image><image:loc>http://example.com/a.png</image:loc></image:image>
+  </url>
+  <url><loc>/b</loc></url>
+</urlset>`
+
+// 解析站点地图并返回请求的URL和元数据。
+func parseTestSitemap(t *testing.T, parser ParseResponse, url string, body string) ([]string, []base.Metadata) {
+	dataList, errs := parser(newTestHttpResponse(url, "", body, nil), 3)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	urls := make([]string, 0)
+	metas := make([]base.Metadata, 0)
+	for _, data := range dataList {
+		req := data.(*base.Request)
+		if req.Depth() != 0 || !req.Meta().Bool(base.META_SEED) {
+			t.Errorf("The request %s is not a seed request!", req.HttpReq().URL)
+		}
+		urls = append(urls, req.HttpReq().URL.String())
+		metas = append(metas, req.Meta())
+	}
+	return urls, metas
+}
+
+func TestParseSitemap(t *testing.T) {
+	urls, metas := parseTestSitemap(t, ParseSitemap, "http://example.com/sitemap.xml", sitemapTestIndex)
+	if !reflect.DeepEqual(urls, []string{"http://example.com/sitemap-1.xml.gz"}) ||
+		!reflect.DeepEqual(metas[0].Strings(base.META_PARSERS), []string{SITEMAP_PARSER}) {
+		t.Errorf("Unexpected requests %v %v for the sitemap index!", urls, metas)
+	}
+
+	var buffer bytes.Buffer
+	gzWriter := gzip.NewWriter(&buffer)
+	gzWriter.Write([]byte(sitemapTestUrlset))
+	gzWriter.Close()
+	urls, metas = parseTestSitemap(t, ParseSitemap, "http://example.com/sitemap-1.xml.gz", buffer.String())
+	if !reflect.DeepEqual(urls, []string{"http://example.com/a", "http://example.com/b"}) {
+		t.Fatalf("Unexpected urls %v for the urlset!", urls)
+	}
+	meta := metas[0]
+	if meta.String(base.META_LASTMOD) != "2024-02-03" || meta.String(base.META_CHANGEFREQ) != "daily" ||
+		meta[base.META_PRIORITY] != 0.8 || meta.String(base.META_SITEMAP) != "http://example.com/sitemap-1.xml.gz" {
+		t.Errorf("Unexpected metadata %v!", meta)
+	}
+	if metas[1].Strings(base.META_PARSERS) != nil {
+		t.Errorf("The page request should use the default parsers, but it names %v!",
+			metas[1].Strings(base.META_PARSERS))
+	}
+
+	urls, _ = parseTestSitemap(t, ParseSitemap, "http://example.com/sitemap.txt",
+		"http://example.com/x\n\nhttp://example.com/y\n")
+	if !reflect.DeepEqual(urls, []string{"http://example.com/x", "http://example.com/y"}) {
+		t.Errorf("Unexpected urls %v for the text sitemap!", urls)
+	}
+
+	urls, _ = parseTestSitemap(t, ParseRobotsSitemaps, "http://example.com/robots.txt",
+		"User-agent: *\nDisallow: /private\nSitemap: http://example.com/sitemap_index.xml\n")
+	if !reflect.DeepEqual(urls, []string{"http://example.com/sitemap_index.xml"}) {
+		t.Errorf("Unexpected urls %v for the robots.txt!", urls)
+	}
+}
+
+// 优先级无效时仍会生成请求，只是没有优先级，同时报告一个错误。
+func TestParseSitemapInvalidPriority(t *testing.T) {
+	body := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://example.com/a</loc><priority>high</priority></url>
+  <url><loc>http://example.com/b</loc><priority>0.5</priority></url>
+</urlset>`
+	dataList, errs := ParseSitemap(newTestHttpResponse("http://example.com/sitemap.xml", "", body, nil), 0)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "'high'") {
+		t.Errorf("Expected an error for the invalid priority, but got %v!", errs)
+	}
+	if len(dataList) != 2 {
+		t.Fatalf("Expected 2 requests, but got %d!", len(dataList))
+	}
+	req := dataList[0].(*base.Request)
+	if req.HttpReq().URL.String() != "http://example.com/a" {
+		t.Errorf("Unexpected url %s!", req.HttpReq().URL)
+	}
+	if _, ok := req.Meta().Get(base.META_PRIORITY); ok {
+		t.Errorf("The invalid priority should be omitted, but got %v!", req.Meta())
+	}
+	if dataList[1].(*base.Request).Meta()[base.META_PRIORITY] != 0.5 {
+		t.Errorf("Unexpected metadata %v!", dataList[1].(*base.Request).Meta())
+	}
+}
@@ -0,0 +1,373 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"sys/fetch/base"
+)
+
+// 结构化数据的来源。
+const (
+	STRUCTURED_JSON_LD   = "json-ld"
+	STRUCTURED_MICRODATA = "microdata"
+	STRUCTURED_RDFA      = "rdfa"
+	STRUCTURED_OPENGRAPH = "opengraph"
+	STRUCTURED_TWITTER   = "twitter"
+)
+
+// 条目中记录结构化数据来源的键。
+const STRUCTURED_SOURCE_KEY = "_source"
+
+// 全部结构化数据的来源。
+var structuredSources = []string{
+	STRUCTURED_JSON_LD, STRUCTURED_MICRODATA, STRUCTURED_RDFA, STRUCTURED_OPENGRAPH, STRUCTURED_TWITTER,
+}
+
+// 注册内置的结构化数据解析函数。
+func init() {
+	RegisterFactory("structured-data", newStructuredDataParserFromParams)
+}
+
+// 创建结构化数据解析函数。它从HTML页面中提取JSON-LD、微数据(microdata)、RDFa Lite、
+// OpenGraph和Twitter卡片，每个结构化对象生成一个条目。
+// 条目的类型是对象的@type(schema.org的类型会被简化为短名称，如Product)，
+// OpenGraph的类型是og:type，Twitter卡片的类型是twitter:card。
+// 条目中的STRUCTURED_SOURCE_KEY字段记录数据的来源。参数sources为空时提取全部来源。
+func NewStructuredDataParser(sources ...string) (ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	enabled := make(map[string]bool)
+	for i, source := range sources {
+		valid := false
+		for _, s := range structuredSources {
+			if s == source {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			argsErr.Add(fmt.Sprintf("sources[%d]", i), errors.New(fmt.Sprintf(
+				"Unknown structured data source '%s'!", source)))
+		}
+		enabled[source] = true
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		for _, s := range structuredSources {
+			enabled[s] = true
+		}
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		if mediaType := mediaTypeOf(httpResp); mediaType != "" && !isHtml(mediaType) {
+			return nil, nil
+		}
+		doc, err := goquery.NewDocumentFromReader(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		baseUrl := documentBaseUrl(doc, httpResp.Request.URL)
+		items := make([]base.Item, 0)
+		errs := make([]error, 0)
+		if enabled[STRUCTURED_JSON_LD] {
+			jsonLdItems, jsonLdErrs := extractJsonLd(doc, httpResp.Request.URL)
+			items = append(items, jsonLdItems...)
+			errs = append(errs, jsonLdErrs...)
+		}
+		if enabled[STRUCTURED_MICRODATA] {
+			items = append(items, extractMicrodata(doc, baseUrl)...)
+		}
+		if enabled[STRUCTURED_RDFA] {
+			items = append(items, extractRdfa(doc, baseUrl)...)
+		}
+		if enabled[STRUCTURED_OPENGRAPH] {
+			if item := extractMetaProperties(doc, STRUCTURED_OPENGRAPH, "og:type", "website",
+				"og:", "article:", "book:", "profile:", "product:", "music:", "video:", "fb:"); item != nil {
+				items = append(items, item)
+			}
+		}
+		if enabled[STRUCTURED_TWITTER] {
+			if item := extractMetaProperties(doc, STRUCTURED_TWITTER, "twitter:card", "summary",
+				"twitter:"); item != nil {
+				items = append(items, item)
+			}
+		}
+		dataList := make([]base.Data, 0, len(items))
+		for i := range items {
+			dataList = append(dataList, &items[i])
+		}
+		return dataList, errs
+	}, nil
+}
+
+// 简化结构化数据的类型。schema.org的类型会被简化为短名称，带有前缀的类型(如schema:Product)会去掉前缀。
+func structuredType(t string) string {
+	t = strings.TrimSpace(t)
+	for _, prefix := range []string{"http://schema.org/", "https://schema.org/", "schema:"} {
+		if strings.HasPrefix(t, prefix) {
+			return t[len(prefix):]
+		}
+	}
+	return t
+}
+
+// 根据结构化对象生成条目。对象本身的字段被保留，类型被记录在base.ITEM_TYPE_KEY中。
+func structuredItem(object map[string]interface{}, source string) base.Item {
+	item := base.Item{STRUCTURED_SOURCE_KEY: source}
+	for k, v := range object {
+		item[k] = v
+	}
+	switch t := object["@type"].(type) {
+	case string:
+		item[base.ITEM_TYPE_KEY] = structuredType(t)
+	case []interface{}:
+		if len(t) > 0 {
+			item[base.ITEM_TYPE_KEY] = structuredType(valueString(t[0]))
+		}
+	}
+	return item
+}
+
+// 提取页面中的JSON-LD。数组和@graph中的每个对象各生成一个条目。
+func extractJsonLd(doc *goquery.Document, pageUrl *url.URL) ([]base.Item, []error) {
+	items := make([]base.Item, 0)
+	errs := make([]error, 0)
+	doc.Find(`script[type="application/ld+json"]`).Each(func(index int, sel *goquery.Selection) {
+		text := strings.TrimSpace(sel.Text())
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "<!--"), "-->")
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		root, err := decodeJson(strings.NewReader(text))
+		if err != nil {
+			errs = append(errs, errors.New(fmt.Sprintf(
+				"Can not parse the JSON-LD block %d (url=%s): %s", index, pageUrl, err)))
+			return
+		}
+		for _, object := range jsonLdObjects(root, nil) {
+			items = append(items, structuredItem(object, STRUCTURED_JSON_LD))
+		}
+	})
+	return items, errs
+}
+
+// 展开JSON-LD中的顶层对象。@graph中的对象会继承外层的@context。
+func jsonLdObjects(v interface{}, context interface{}) []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0)
+	switch value := v.(type) {
+	case []interface{}:
+		for _, e := range value {
+			objects = append(objects, jsonLdObjects(e, context)...)
+		}
+	case map[string]interface{}:
+		if c, ok := value["@context"]; ok {
+			context = c
+		}
+		if graph, ok := value["@graph"]; ok {
+			return append(objects, jsonLdObjects(graph, context)...)
+		}
+		if _, ok := value["@context"]; !ok && context != nil {
+			value["@context"] = context
+		}
+		objects = append(objects, value)
+	}
+	return objects
+}
+
+// 提取页面中的微数据。每个顶层的itemscope各生成一个条目。
+func extractMicrodata(doc *goquery.Document, baseUrl *url.URL) []base.Item {
+	items := make([]base.Item, 0)
+	doc.Find("[itemscope]").Not("[itemprop]").Each(func(index int, sel *goquery.Selection) {
+		items = append(items, structuredItem(microdataObject(sel, baseUrl), STRUCTURED_MICRODATA))
+	})
+	return items
+}
+
+// 生成微数据中的对象。属性归属于最近的itemscope祖先。
+func microdataObject(scope *goquery.Selection, baseUrl *url.URL) map[string]interface{} {
+	object := make(map[string]interface{})
+	if itemType, ok := scope.Attr("itemtype"); ok && strings.TrimSpace(itemType) != "" {
+		object["@type"] = strings.Fields(itemType)[0]
+	}
+	if itemId, ok := scope.Attr("itemid"); ok && strings.TrimSpace(itemId) != "" {
+		object["@id"] = strings.TrimSpace(itemId)
+	}
+	scope.Find("[itemprop]").Each(func(index int, sel *goquery.Selection) {
+		if !ownedBy(sel, scope, "[itemscope]") {
+			return
+		}
+		var value interface{}
+		if _, ok := sel.Attr("itemscope"); ok {
+			value = microdataObject(sel, baseUrl)
+		} else {
+			value = elementValue(sel, baseUrl, "")
+		}
+		props, _ := sel.Attr("itemprop")
+		for _, prop := range strings.Fields(props) {
+			addStructuredValue(object, prop, value)
+		}
+	})
+	return object
+}
+
+// 提取页面中的RDFa Lite。每个顶层的typeof各生成一个条目。
+// 没有typeof祖先的属性(如OpenGraph的meta标签)不会被提取。
+func extractRdfa(doc *goquery.Document, baseUrl *url.URL) []base.Item {
+	items := make([]base.Item, 0)
+	doc.Find("[typeof]").Not("[property]").Each(func(index int, sel *goquery.Selection) {
+		items = append(items, structuredItem(rdfaObject(sel, baseUrl), STRUCTURED_RDFA))
+	})
+	return items
+}
+
+// 生成RDFa中的对象。属性归属于最近的typeof祖先。
+func rdfaObject(scope *goquery.Selection, baseUrl *url.URL) map[string]interface{} {
+	object := make(map[string]interface{})
+	if typeOf, ok := scope.Attr("typeof"); ok && strings.TrimSpace(typeOf) != "" {
+		object["@type"] = strings.Fields(typeOf)[0]
+	}
+	if vocab := closestAttr(scope, "vocab"); vocab != "" {
+		object["@context"] = vocab
+	}
+	if resource, ok := scope.Attr("resource"); ok && strings.TrimSpace(resource) != "" {
+		object["@id"] = strings.TrimSpace(resource)
+	}
+	scope.Find("[property]").Each(func(index int, sel *goquery.Selection) {
+		if !ownedBy(sel, scope, "[typeof]") {
+			return
+		}
+		var value interface{}
+		if _, ok := sel.Attr("typeof"); ok {
+			value = rdfaObject(sel, baseUrl)
+		} else {
+			value = elementValue(sel, baseUrl, "resource")
+		}
+		props, _ := sel.Attr("property")
+		for _, prop := range strings.Fields(props) {
+			addStructuredValue(object, structuredType(prop), value)
+		}
+	})
+	return object
+}
+
+// 提取页面中以给定前缀开头的meta属性(property或name)，生成一个条目。
+// 条目的类型是typeKey属性的值，不存在时是defaultType。页面中没有这些属性时返回nil。
+func extractMetaProperties(doc *goquery.Document, source string, typeKey string,
+	defaultType string, prefixes ...string) base.Item {
+	item := base.Item{}
+	doc.Find("meta[content]").Each(func(index int, sel *goquery.Selection) {
+		key, ok := sel.Attr("property")
+		if !ok {
+			key, _ = sel.Attr("name")
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				content, _ := sel.Attr("content")
+				addStructuredValue(item, key, strings.TrimSpace(content))
+				break
+			}
+		}
+	})
+	if len(item) == 0 {
+		return nil
+	}
+	itemType := defaultType
+	if t, ok := item[typeKey].(string); ok && t != "" {
+		itemType = t
+	}
+	item[base.ITEM_TYPE_KEY] = itemType
+	item[STRUCTURED_SOURCE_KEY] = source
+	return item
+}
+
+// 判断元素最近的满足选择器的祖先是否为scope。
+func ownedBy(sel *goquery.Selection, scope *goquery.Selection, selector string) bool {
+	owner := sel.Parent().Closest(selector)
+	return owner.Length() > 0 && owner.Get(0) == scope.Get(0)
+}
+
+// 获得元素或其最近的祖先的属性值。
+func closestAttr(sel *goquery.Selection, attr string) string {
+	value, _ := sel.Closest("[" + attr + "]").Attr(attr)
+	return strings.TrimSpace(value)
+}
+
+// 获得微数据或RDFa属性的值。URL属性会被解析为绝对URL。
+// 参数resourceAttr是优先于其他属性被读取的URL属性，可以为空。
+func elementValue(sel *goquery.Selection, baseUrl *url.URL, resourceAttr string) interface{} {
+	if content, ok := sel.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	urlAttrs := []string{}
+	if resourceAttr != "" {
+		urlAttrs = append(urlAttrs, resourceAttr)
+	}
+	node := sel.Get(0)
+	if node.Type == html.ElementNode {
+		switch node.Data {
+		case "audio", "embed", "iframe", "img", "source", "track", "video":
+			urlAttrs = append(urlAttrs, "src")
+		case "a", "area", "link":
+			urlAttrs = append(urlAttrs, "href")
+		case "object":
+			urlAttrs = append(urlAttrs, "data")
+		case "data", "meter":
+			if value, ok := sel.Attr("value"); ok {
+				return strings.TrimSpace(value)
+			}
+		case "time":
+			if value, ok := sel.Attr("datetime"); ok {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	for _, attr := range urlAttrs {
+		if value, ok := sel.Attr(attr); ok {
+			value = strings.TrimSpace(value)
+			if u, err := url.Parse(value); err == nil {
+				return baseUrl.ResolveReference(u).String()
+			}
+			return value
+		}
+	}
+	return strings.Join(strings.Fields(sel.Text()), " ")
+}
+
+// 向对象中添加属性值。同一属性有多个值时，它们会被合并为列表。
+func addStructuredValue(object map[string]interface{}, key string, value interface{}) {
+	existing, ok := object[key]
+	if !ok {
+		object[key] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		object[key] = append(list, value)
+		return
+	}
+	object[key] = []interface{}{existing, value}
+}
+
+// 根据参数生成结构化数据解析函数。参数sources是要提取的来源的列表，
+// 可以包括json-ld、microdata、rdfa、opengraph和twitter，为空时提取全部来源。
+func newStructuredDataParserFromParams(params base.Params) (ParseResponse, error) {
+	sources, err := params.Strings("sources")
+	if err != nil {
+		return nil, err
+	}
+	return NewStructuredDataParser(sources...)
+}
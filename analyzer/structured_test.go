@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const structuredTestPage = `<html><head>
+<meta property="og:title" content="Green Tea">
+<meta property="og:type" content="product">
+<meta property="og:image" content="http://example.com/1.png">
+<meta property="og:image" content="http://example.com/2.png">
+<meta name="twitter:card" content="summary_large_image">
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@type": "Product", "name": "Green Tea",
+ "offers": {"@type": "Offer", "price": 12.5}}
+</script>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+  {"@type": "BreadcrumbList", "itemListElement": []},
+  {"@type": ["Article", "NewsArticle"], "headline": "Tea news"}]}
+</script>
+<script type="application/ld+json">{not json}</script>
+</head><body>
+<div itemscope itemtype="http://schema.org/Person" itemid="urn:person:1">
+  <span itemprop="name">Ann</span>
+  <a itemprop="url" href="/ann">home</a>
+  <span itemprop="skill">tea</span><span itemprop="skill">coffee</span>
+  <div itemprop="address" itemscope itemtype="http://schema.org/PostalAddress">
+    <span itemprop="addressLocality">Paris</span>
+  </div>
+  <time itemprop="birthDate" datetime="1990-01-02">2 Jan 1990</time>
+</div>
+<div vocab="http://schema.org/" typeof="Event">
+  <span property="name">Tea party</span>
+  <div property="location" typeof="Place"><span property="name">Garden</span></div>
+  <img property="image" src="party.png">
+</div>
+</body></html>`
+
+func TestStructuredDataParser(t *testing.T) {
+	parser, err := NewParser("structured-data", nil)
+	if err != nil {
+		t.Fatalf("Can not create the structured data parser: %s", err)
+	}
+	dataList, errs := parser(newTestHttpResponse("http://example.com/p/", "text/html", structuredTestPage, nil), 0)
+	if len(errs) != 1 {
+		t.Errorf("Expected an error for the invalid JSON-LD, but got %v!", errs)
+	}
+	items := make(map[string]base.Item)
+	for _, data := range dataList {
+		item := *data.(*base.Item)
+		items[item[base.ITEM_TYPE_KEY].(string)] = item
+	}
+	if len(dataList) != 7 {
+		t.Fatalf("Unexpected items %v!", items)
+	}
+
+	product := items["Product"]
+	offers, _ := product["offers"].(map[string]interface{})
+	if product[STRUCTURED_SOURCE_KEY] != STRUCTURED_JSON_LD || product["name"] != "Green Tea" || offers["price"] != 12.5 {
+		t.Errorf("Unexpected JSON-LD item %v!", product)
+	}
+	if items["Article"]["headline"] != "Tea news" || items["Article"]["@context"] != "https://schema.org" {
+		t.Errorf("Unexpected JSON-LD item %v in the graph!", items["Article"])
+	}
+	if _, ok := items["BreadcrumbList"]; !ok {
+		t.Errorf("Expected the breadcrumb list in the graph!")
+	}
+
+	expected := base.Item{
+		base.ITEM_TYPE_KEY:    "Person",
+		STRUCTURED_SOURCE_KEY: STRUCTURED_MICRODATA,
+		"@type":               "http://schema.org/Person",
+		"@id":                 "urn:person:1",
+		"name":                "Ann",
+		"url":                 "http://example.com/ann",
+		"skill":               []interface{}{"tea", "coffee"},
+		"address":             map[string]interface{}{"@type": "http://schema.org/PostalAddress", "addressLocality": "Paris"},
+		"birthDate":           "1990-01-02",
+	}
+	if !reflect.DeepEqual(items["Person"], expected) {
+		t.Errorf("Unexpected microdata item %v!\nexpected: %v", items["Person"], expected)
+	}
+
+	event := items["Event"]
+	location, _ := event["location"].(map[string]interface{})
+	if event[STRUCTURED_SOURCE_KEY] != STRUCTURED_RDFA || event["name"] != "Tea party" ||
+		location["name"] != "Garden" || event["image"] != "http://example.com/p/party.png" {
+		t.Errorf("Unexpected RDFa item %v!", event)
+	}
+
+	og := items["product"]
+	if og["og:title"] != "Green Tea" ||
+		!reflect.DeepEqual(og["og:image"], []interface{}{"http://example.com/1.png", "http://example.com/2.png"}) {
+		t.Errorf("Unexpected OpenGraph item %v!", og)
+	}
+	if items["summary_large_image"][STRUCTURED_SOURCE_KEY] != STRUCTURED_TWITTER {
+		t.Errorf("Unexpected Twitter card item %v!", items["summary_large_image"])
+	}
+
+	parser, _ = NewStructuredDataParser(STRUCTURED_OPENGRAPH)
+	dataList, _ = parser(newTestHttpResponse("http://example.com/p/", "text/html", structuredTestPage, nil), 0)
+	if len(dataList) != 1 {
+		t.Errorf("Expected only the OpenGraph item, but got %v!", dataList)
+	}
+	if _, err := NewStructuredDataParser("microformats"); err == nil {
+		t.Errorf("Expected an error for the unknown source!")
+	}
+}
@@ -0,0 +1,34 @@
+package analyzer
+
+import (
+	"net/http"
+
+	"sys/fetch/base"
+)
+
+// 把类型化的条目转换为响应解析函数返回的数据(见base.EncodeItem)。
+// 转换失败的条目会被略过，其错误为*base.ItemConversionError。
+func TypedItems[T any](values ...T) ([]base.Data, []error) {
+	dataList := make([]base.Data, 0, len(values))
+	var errs []error
+	for _, value := range values {
+		item, err := base.EncodeItem(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		dataList = append(dataList, &item)
+	}
+	return dataList, errs
+}
+
+// 把生成类型化条目的函数包装为响应解析函数。参数parse返回的dataList可以包含请求等其他数据，
+// 它们会被原样放在转换后的条目之后。
+func TypedParser[T any](
+	parse func(httpResp *http.Response, respDepth uint32) (items []T, dataList []base.Data, errs []error)) ParseResponse {
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		items, dataList, errs := parse(httpResp, respDepth)
+		itemDataList, itemErrs := TypedItems(items...)
+		return append(itemDataList, dataList...), append(errs, itemErrs...)
+	}
+}
@@ -0,0 +1,40 @@
+package analyzer
+
+import (
+	"net/http"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+type testPage struct {
+	Url   string `item:"url"`
+	Depth uint32 `item:"depth"`
+	Size  func() `item:"size,omitempty"`
+}
+
+func (p testPage) ItemType() string {
+	return "page"
+}
+
+func TestTypedParser(t *testing.T) {
+	parser := TypedParser(func(httpResp *http.Response, respDepth uint32) ([]testPage, []base.Data, []error) {
+		pages := []testPage{{Url: httpResp.Request.URL.String(), Depth: respDepth}, {Size: func() {}}}
+		next, _ := http.NewRequest("GET", "http://example.com/next", nil)
+		return pages, []base.Data{base.NewRequest(next, respDepth+1)}, nil
+	})
+	dataList, errs := parser(newTestHttpResponse("http://example.com/", "text/html", "", nil), 1)
+	if len(dataList) != 2 || len(errs) != 1 {
+		t.Fatalf("Unexpected data list %v (errors=%v)!", dataList, errs)
+	}
+	item, ok := dataList[0].(*base.Item)
+	if !ok || (*item)["_type"] != "page" || (*item)["url"] != "http://example.com/" || (*item)["depth"] != uint32(1) {
+		t.Errorf("Unexpected item %v!", dataList[0])
+	}
+	if _, ok := dataList[1].(*base.Request); !ok {
+		t.Errorf("Expected the request after the items, but got %T!", dataList[1])
+	}
+	if _, ok := errs[0].(*base.ItemConversionError); !ok {
+		t.Errorf("Expected a conversion error, but got %v!", errs[0])
+	}
+}
@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+
+	"sys/fetch/base"
+)
+
+// 注册内置的XPath条目提取器。
+func init() {
+	RegisterFactory("xpath-items", func(params base.Params) (ParseResponse, error) {
+		ruleSet, err := ruleSetFromParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return NewXPathExtractor(ruleSet)
+	})
+}
+
+// 创建以XPath表达式描述规则的条目提取器。它是一个响应解析函数，
+// 会根据规则从HTML或XML页面中提取条目和需要跟随的请求，条目的类型被记录在base.ITEM_TYPE_KEY字段中。
+// 页面的类型由响应的内容类型决定，未给出内容类型时会根据响应体判断。
+// 表达式的结果可以是节点集合，也可以是字符串、数字或布尔值。
+func NewXPathExtractor(ruleSet RuleSet) (ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	argsErr.Add("", ruleSet.Check())
+	argsErr.Add("", ruleSet.checkSelectors(checkXPath))
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != 200 {
+			err := errors.New(
+				fmt.Sprintf("Unsupported status code %d. (url=%s)",
+					httpResp.StatusCode, httpResp.Request.URL))
+			return nil, []error{err}
+		}
+		body, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, []error{err}
+		}
+		pageUrl := httpResp.Request.URL
+		doc, err := parseXPathDocument(mediaTypeOf(httpResp), body)
+		if err != nil {
+			return nil, []error{errors.New(fmt.Sprintf(
+				"Can not parse the document (url=%s): %s", pageUrl, err))}
+		}
+		if doc == nil {
+			return nil, nil
+		}
+		baseUrl := pageUrl
+		if doc.html {
+			if hrefs := doc.scopes("")[0].values("//base/@href", ""); len(hrefs) > 0 {
+				if u, err := url.Parse(valueString(hrefs[0])); err == nil {
+					baseUrl = pageUrl.ResolveReference(u)
+				}
+			}
+		}
+		return extractByRules(doc, &ruleSet, pageUrl, baseUrl, respDepth)
+	}, nil
+}
+
+// 检查XPath表达式的有效性。
+func checkXPath(selector string) error {
+	if _, err := xpath.Compile(selector); err != nil {
+		return errors.New(fmt.Sprintf("Invalid XPath expression '%s': %s", selector, err))
+	}
+	return nil
+}
+
+// 解析HTML或XML文档。内容类型既不是HTML也不是XML时返回nil。
+func parseXPathDocument(mediaType string, body []byte) (*xpathDocument, error) {
+	xml := isXml(mediaType)
+	if mediaType == "" {
+		xml = looksLikeXml(body)
+	} else if !xml && !isHtml(mediaType) {
+		return nil, nil
+	}
+	if xml {
+		root, err := xmlquery.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return newXPathDocument(xmlquery.CreateXPathNavigator(root), false), nil
+	}
+	root, err := htmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return newXPathDocument(htmlquery.CreateXPathNavigator(root), true), nil
+}
+
+// 判断内容类型是否为XML。XHTML会被当作HTML处理。
+func isXml(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" ||
+		(strings.HasSuffix(mediaType, "+xml") && mediaType != "application/xhtml+xml")
+}
+
+// 根据内容判断文档是否为XML。
+func looksLikeXml(body []byte) bool {
+	head := bytes.ToLower(bytes.TrimSpace(body))
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.HasPrefix(head, []byte("<?xml")) && !bytes.Contains(head, []byte("<html"))
+}
+
+// 基于XPath的文档。
+type xpathDocument struct {
+	root  xpath.NodeNavigator    // 文档的根节点。
+	html  bool                   // 是否为HTML文档。
+	exprs map[string]*xpath.Expr // 已编译的表达式。每个文档有自己的表达式以免并发使用。
+}
+
+// 创建基于XPath的文档。
+func newXPathDocument(root xpath.NodeNavigator, html bool) *xpathDocument {
+	return &xpathDocument{root: root, html: html, exprs: make(map[string]*xpath.Expr)}
+}
+
+// 获得已编译的表达式。表达式无效时返回nil。
+func (xd *xpathDocument) compile(selector string) *xpath.Expr {
+	expr, ok := xd.exprs[selector]
+	if !ok {
+		expr, _ = xpath.Compile(selector)
+		xd.exprs[selector] = expr
+	}
+	return expr
+}
+
+func (xd *xpathDocument) scopes(selector string) []ruleNode {
+	if selector == "" {
+		return []ruleNode{&xpathNode{doc: xd, nav: xd.root.Copy()}}
+	}
+	nodes := make([]ruleNode, 0)
+	expr := xd.compile(selector)
+	if expr == nil {
+		return nodes
+	}
+	iter := expr.Select(xd.root.Copy())
+	for iter.MoveNext() {
+		nodes = append(nodes, &xpathNode{doc: xd, nav: iter.Current().Copy()})
+	}
+	return nodes
+}
+
+// 基于XPath的文档节点。
+type xpathNode struct {
+	doc *xpathDocument      // 节点所属的文档。
+	nav xpath.NodeNavigator // 指向节点的导航器。
+}
+
+func (xn *xpathNode) values(selector string, attr string) []interface{} {
+	if selector == "" {
+		if v, ok := navigatorValue(xn.nav, attr); ok {
+			return []interface{}{v}
+		}
+		return nil
+	}
+	expr := xn.doc.compile(selector)
+	if expr == nil {
+		return nil
+	}
+	switch result := expr.Evaluate(xn.nav.Copy()).(type) {
+	case *xpath.NodeIterator:
+		values := make([]interface{}, 0)
+		for result.MoveNext() {
+			if v, ok := navigatorValue(result.Current(), attr); ok {
+				values = append(values, v)
+			}
+		}
+		return values
+	case string, float64, bool:
+		return []interface{}{result}
+	}
+	return nil
+}
+
+// 获得导航器所指节点的文本或属性值。
+func navigatorValue(nav xpath.NodeNavigator, attr string) (string, bool) {
+	if attr == "" {
+		if nav.NodeType() == xpath.AttributeNode {
+			return strings.TrimSpace(nav.Value()), true
+		}
+		return strings.Join(strings.Fields(nav.Value()), " "), true
+	}
+	attrNav := nav.Copy()
+	for attrNav.MoveToNextAttribute() {
+		if attrNav.LocalName() == attr {
+			return attrNav.Value(), true
+		}
+	}
+	return "", false
+}
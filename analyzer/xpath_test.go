@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+const xpathTestFeed = `<?xml version="1.0"?>
+<catalog>
+  <book id="b1"><title>Go</title><price>30.5</price><link>/books/1</link></book>
+  <book id="b2"><title>XML</title><price>12</price><link>/books/2</link></book>
+  <next href="/catalog?page=2"/>
+</catalog>`
+
+func TestXPathExtractor(t *testing.T) {
+	parser, err := NewParser("xpath-items", base.Params{
+		"items": []interface{}{map[string]interface{}{
+			"type":  "book",
+			"scope": "//book",
+			"fields": map[string]interface{}{
+				"id":    map[string]interface{}{"attr": "id"},
+				"title": "title",
+				"price": map[string]interface{}{"selector": "price", "type": "float"},
+				"url":   map[string]interface{}{"selector": "link", "type": "url"},
+			},
+		}, map[string]interface{}{
+			"type":   "summary",
+			"fields": map[string]interface{}{"count": map[string]interface{}{"selector": "count(//book)", "type": "int"}},
+		}},
+		"follow": []interface{}{map[string]interface{}{"selector": "//next/@href"}},
+	})
+	if err != nil {
+		t.Fatalf("Can not create the extractor: %s", err)
+	}
+	resp := newTestResponse("http://example.com/catalog", "application/xml", xpathTestFeed, nil)
+	dataList, errs := parser(resp.HttpResp(), 0)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(dataList) != 4 {
+		t.Fatalf("Unexpected data list %v!", dataList)
+	}
+	expected := base.Item{
+		base.ITEM_TYPE_KEY: "book", "id": "b1", "title": "Go", "price": 30.5,
+		"url": "http://example.com/books/1",
+	}
+	if item := *dataList[0].(*base.Item); !reflect.DeepEqual(item, expected) {
+		t.Errorf("Unexpected item %v!\nexpected: %v", item, expected)
+	}
+	if item := *dataList[2].(*base.Item); item["count"] != int64(2) {
+		t.Errorf("Unexpected summary item %v!", item)
+	}
+	req, ok := dataList[3].(*base.Request)
+	if !ok || req.HttpReq().URL.String() != "http://example.com/catalog?page=2" || req.Depth() != 1 {
+		t.Errorf("Unexpected follow-up request %v!", dataList[3])
+	}
+
+	resp = newTestResponse("http://example.com/", "text/html",
+		`<html><body><p class="a">Hello <b>world</b></p></body></html>`, nil)
+	parser, err = NewXPathExtractor(RuleSet{Items: []ItemRule{{
+		Type:   "page",
+		Fields: []FieldRule{{Name: "text", Selector: "//p[@class='a']"}},
+	}}})
+	if err != nil {
+		t.Fatalf("Can not create the extractor: %s", err)
+	}
+	dataList, _ = parser(resp.HttpResp(), 0)
+	if len(dataList) != 1 || (*dataList[0].(*base.Item))["text"] != "Hello world" {
+		t.Errorf("Unexpected data list %v for the HTML page!", dataList)
+	}
+
+	if _, err := NewXPathExtractor(RuleSet{Follow: []FollowRule{{Selector: "//a[@href"}}}); err == nil {
+		t.Errorf("Expected an error for the invalid XPath expression!")
+	}
+}
@@ -1,8 +1,10 @@
 package base
 
 import (
+	"bytes"
 	"fmt"
 	"errors"
+	"strings"
 )
 
 //参数容器的接口
@@ -45,19 +47,24 @@ func NewChannelArgs(
 
 
 func (args *ChannelArgs) Check() error {
+	argsErr := NewArgsError()
 	if args.reqChanLen == 0 {
-		return errors.New("The request channel max length (capacity) can not be 0!\n")
+		argsErr.Add("reqChanLen",
+			errors.New("The request channel max length (capacity) can not be 0!\n"))
 	}
 	if args.respChanLen == 0 {
-		return errors.New("The response channel max length (capacity) can not be 0!\n")
+		argsErr.Add("respChanLen",
+			errors.New("The response channel max length (capacity) can not be 0!\n"))
 	}
 	if args.itemChanLen == 0 {
-		return errors.New("The item channel max length (capacity) can not be 0!\n")
+		argsErr.Add("itemChanLen",
+			errors.New("The item channel max length (capacity) can not be 0!\n"))
 	}
 	if args.errorChanLen == 0 {
-		return errors.New("The error channel max length (capacity) can not be 0!\n")
+		argsErr.Add("errorChanLen",
+			errors.New("The error channel max length (capacity) can not be 0!\n"))
 	}
-	return nil
+	return argsErr.ErrorOrNil()
 }
 
 
@@ -124,13 +131,16 @@ func NewPoolBaseArgs(
 
 
 func (args *PoolBaseArgs) Check() error {
+	argsErr := NewArgsError()
 	if args.pageDownloaderPoolSize == 0 {
-		return errors.New("The page downloader pool size can not be 0!\n")
+		argsErr.Add("pageDownloaderPoolSize",
+			errors.New("The page downloader pool size can not be 0!\n"))
 	}
 	if args.analyzerPoolSize == 0 {
-		return errors.New("The analyzer pool size can not be 0!\n")
+		argsErr.Add("analyzerPoolSize",
+			errors.New("The analyzer pool size can not be 0!\n"))
 	}
-	return nil
+	return argsErr.ErrorOrNil()
 }
 
 
@@ -154,3 +164,82 @@ func (args *PoolBaseArgs) PageDownloaderPoolSize() uint32 {
 func (args *PoolBaseArgs) AnalyzerPoolSize() uint32 {
 	return args.analyzerPoolSize
 }
+
+
+// 参数字段错误。它记录了出错的字段路径，例如"channelArgs.reqChanLen"。
+type FieldError struct {
+	Field string // 字段路径。
+	Err   error  // 原始错误。
+}
+
+func (fe *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", fe.Field, strings.TrimSpace(fe.Err.Error()))
+}
+
+// 参数检查错误的汇总。
+// 它会收集全部的检查错误，而不是在遇到第一个错误时就停止检查。
+type ArgsError struct {
+	errs []error // 已收集的错误。
+}
+
+// 创建参数检查错误的汇总。
+func NewArgsError() *ArgsError {
+	return &ArgsError{errs: make([]error, 0)}
+}
+
+// 添加一个错误。参数field代表出错的字段，可以为空。
+// 若err本身也是参数检查错误的汇总，那么其中的错误会被展开并冠以该字段路径。
+func (ae *ArgsError) Add(field string, err error) {
+	if err == nil {
+		return
+	}
+	if inner, ok := err.(*ArgsError); ok {
+		for _, e := range inner.errs {
+			ae.Add(field, e)
+		}
+		return
+	}
+	if field == "" {
+		ae.errs = append(ae.errs, err)
+		return
+	}
+	if fe, ok := err.(*FieldError); ok {
+		ae.errs = append(ae.errs, &FieldError{Field: joinFieldPath(field, fe.Field), Err: fe.Err})
+		return
+	}
+	ae.errs = append(ae.errs, &FieldError{Field: field, Err: err})
+}
+
+// 连接字段路径。以"["开头的下标部分不需要分隔符。
+func joinFieldPath(parent string, child string) string {
+	if strings.HasPrefix(child, "[") {
+		return parent + child
+	}
+	return parent + "." + child
+}
+
+// 获得已收集的错误。
+func (ae *ArgsError) Errors() []error {
+	return ae.errs
+}
+
+// 若未收集到任何错误则返回nil，否则返回汇总本身。
+func (ae *ArgsError) ErrorOrNil() error {
+	if len(ae.errs) == 0 {
+		return nil
+	}
+	return ae
+}
+
+func (ae *ArgsError) Error() string {
+	if len(ae.errs) == 1 {
+		return ae.errs[0].Error()
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%d argument errors:", len(ae.errs)))
+	for _, err := range ae.errs {
+		buffer.WriteString("\n\t")
+		buffer.WriteString(strings.TrimSpace(err.Error()))
+	}
+	return buffer.String()
+}
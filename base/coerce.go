@@ -0,0 +1,117 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//值的类型。它被用来把从页面中提取出的文本转换成相应类型的值
+type ValueType string
+
+const (
+	TYPE_STRING ValueType = "string" //字符串
+	TYPE_INT    ValueType = "int"    //整数，对应int64
+	TYPE_FLOAT  ValueType = "float"  //浮点数，对应float64
+	TYPE_BOOL   ValueType = "bool"   //布尔值
+	TYPE_DATE   ValueType = "date"   //时间，对应time.Time
+	TYPE_URL    ValueType = "url"    //URL，相对URL会被解析为绝对URL后以字符串表示
+)
+
+//未给出时间格式时依次尝试的格式
+var defaultDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC850,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+}
+
+//判断值的类型是否有效。空值等同于TYPE_STRING
+func (vt ValueType) Valid() bool {
+	switch vt {
+	case "", TYPE_STRING, TYPE_INT, TYPE_FLOAT, TYPE_BOOL, TYPE_DATE, TYPE_URL:
+		return true
+	}
+	return false
+}
+
+//把文本转换为给定类型的值。
+//参数layout是TYPE_DATE所用的时间格式，为空时会依次尝试常见的格式。
+//参数baseUrl是TYPE_URL解析相对URL时所依据的URL，可以为nil
+func CoerceValue(s string, vt ValueType, layout string, baseUrl *url.URL) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch vt {
+	case "", TYPE_STRING:
+		return s, nil
+	case TYPE_INT:
+		n, err := strconv.ParseInt(trimNumber(s), 10, 64)
+		if err != nil {
+			return nil, coerceError(s, vt)
+		}
+		return n, nil
+	case TYPE_FLOAT:
+		f, err := strconv.ParseFloat(trimNumber(s), 64)
+		if err != nil {
+			return nil, coerceError(s, vt)
+		}
+		return f, nil
+	case TYPE_BOOL:
+		switch strings.ToLower(s) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0", "":
+			return false, nil
+		}
+		return nil, coerceError(s, vt)
+	case TYPE_DATE:
+		layouts := defaultDateLayouts
+		if layout != "" {
+			layouts = []string{layout}
+		}
+		for _, l := range layouts {
+			if t, err := time.Parse(l, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, coerceError(s, vt)
+	case TYPE_URL:
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, coerceError(s, vt)
+		}
+		if baseUrl != nil {
+			u = baseUrl.ResolveReference(u)
+		}
+		return u.String(), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported value type '%s'!", vt))
+}
+
+//去掉数字中的千位分隔符和空白
+func trimNumber(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '_' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+//生成类型转换错误
+func coerceError(s string, vt ValueType) error {
+	return errors.New(fmt.Sprintf("Can not convert %q to %s!", s, vt))
+}
@@ -1,16 +1,120 @@
 package base
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 //数据的接口
 type Data interface {
 	Valid() bool //数据是否有效
 }
 
+//元数据的常用键
+const (
+	META_REFERRER     = "referrer"     //引用页面的URL
+	META_ANCHOR_TEXT  = "anchor_text"  //链接的文本
+	META_SEED_ID      = "seed_id"      //请求所源自的种子请求的标识
+	META_PARSERS      = "parsers"      //应被用来解析响应的解析函数的名称的列表
+	META_LINK_TAG     = "link_tag"     //链接所在的HTML标签
+	META_IGNORE_DEPTH = "ignore_depth" //为true时请求不受爬取深度的限制，例如翻页产生的请求
+	META_PAGE         = "page"         //翻页产生的请求所对应的页序号，第一页为0
+	META_SEED         = "seed"         //为true时请求被当作种子请求，其深度总是0，例如来自站点地图的请求
+	META_SITEMAP      = "sitemap"      //请求所来自的站点地图的URL
+	META_LASTMOD      = "lastmod"      //站点地图中给出的最后修改时间
+	META_CHANGEFREQ   = "changefreq"   //站点地图中给出的更新频率
+	META_PRIORITY     = "priority"     //站点地图中给出的优先级，为0.0到1.0之间的浮点数
+	META_CANONICAL    = "canonical"    //分析器在响应中发现的页面的规范URL
+	META_DUPLICATE_OF = "duplicate_of" //与响应的内容近似重复的页面的URL，由分析器记录
+)
+
+//元数据。它记录了请求被放入队列的原因等上下文信息，会随请求传递给响应和解析函数
+type Metadata map[string]interface{}
+
+//获得元数据的值
+func (meta Metadata) Get(key string) (interface{}, bool) {
+	v, ok := meta[key]
+	return v, ok
+}
+
+//获得字符串类型的元数据的值。值不存在或不是字符串时返回空字符串
+func (meta Metadata) String(key string) string {
+	s, _ := meta[key].(string)
+	return s
+}
+
+//获得布尔类型的元数据的值。值不存在或不是布尔值时返回false
+func (meta Metadata) Bool(key string) bool {
+	b, _ := meta[key].(bool)
+	return b
+}
+
+//获得整数类型的元数据的值。值不存在或不是数字时返回0
+func (meta Metadata) Int(key string) int {
+	switch n := meta[key].(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint32:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+//获得字符串列表类型的元数据的值。值不存在或不是字符串列表时返回nil
+func (meta Metadata) Strings(key string) []string {
+	switch list := meta[key].(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, e := range list {
+			if s, ok := e.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+//复制元数据
+func (meta Metadata) Clone() Metadata {
+	if meta == nil {
+		return nil
+	}
+	clone := make(Metadata, len(meta))
+	for k, v := range meta {
+		clone[k] = v
+	}
+	return clone
+}
+
+//被用来在HTTP请求的上下文中存放元数据的键的类型
+type metaContextKey struct{}
+
+//把元数据放入上下文
+func ContextWithMeta(ctx context.Context, meta Metadata) context.Context {
+	return context.WithValue(ctx, metaContextKey{}, meta)
+}
+
+//获得HTTP请求所携带的元数据。解析函数可以通过httpResp.Request获得请求的元数据
+func MetaOf(httpReq *http.Request) Metadata {
+	if httpReq == nil {
+		return nil
+	}
+	meta, _ := httpReq.Context().Value(metaContextKey{}).(Metadata)
+	return meta
+}
+
 //请求
 type Request struct {
-	httpReq *http.Request	//http请求的指针值
-	depth 	uint32
+	httpReq *http.Request //http请求的指针值
+	depth   uint32
+	meta    Metadata //元数据
 }
 
 //创建新的请求
@@ -18,6 +122,35 @@ func NewRequest(httpReq *http.Request, depth uint32) *Request {
 	return &Request{httpReq: httpReq, depth: depth}
 }
 
+//创建带有元数据的请求
+func NewRequestWithMeta(httpReq *http.Request, depth uint32, meta Metadata) *Request {
+	return &Request{httpReq: httpReq, depth: depth, meta: meta}
+}
+
+//获取元数据
+func (req *Request) Meta() Metadata {
+	return req.meta
+}
+
+//设置元数据的值
+func (req *Request) SetMeta(key string, value interface{}) {
+	if req.meta == nil {
+		req.meta = make(Metadata)
+	}
+	req.meta[key] = value
+}
+
+//指定应被用来解析该请求的响应的解析函数的名称。
+//未指定时分析器会使用调度器配置中的全部解析函数
+func (req *Request) SetParsers(names ...string) {
+	req.SetMeta(META_PARSERS, names)
+}
+
+//获取应被用来解析该请求的响应的解析函数的名称
+func (req *Request) Parsers() []string {
+	return req.meta.Strings(META_PARSERS)
+}
+
 //获取http请求
 func (req *Request) HttpReq() *http.Request {
 	return req.httpReq
@@ -35,8 +168,9 @@ func (req *Request) Valid() bool {
 
 //响应
 type Response struct {
-	httpResp 	*http.Response
-	depth 		uint32
+	httpResp *http.Response
+	depth    uint32
+	meta     Metadata //元数据。它复制自响应所对应的请求
 }
 
 //创建新的响应
@@ -44,6 +178,29 @@ func NewResponse(httpResp *http.Response, depth uint32) *Response {
 	return &Response{httpResp: httpResp, depth: depth}
 }
 
+//创建带有元数据的响应
+func NewResponseWithMeta(httpResp *http.Response, depth uint32, meta Metadata) *Response {
+	return &Response{httpResp: httpResp, depth: depth, meta: meta}
+}
+
+//获取元数据
+func (resp *Response) Meta() Metadata {
+	return resp.meta
+}
+
+//设置元数据的值
+func (resp *Response) SetMeta(key string, value interface{}) {
+	if resp.meta == nil {
+		resp.meta = make(Metadata)
+	}
+	resp.meta[key] = value
+}
+
+//获取应被用来解析该响应的解析函数的名称
+func (resp *Response) Parsers() []string {
+	return resp.meta.Strings(META_PARSERS)
+}
+
 //获取http响应
 func (resp *Response) HttpResp() *http.Response {
 	return resp.httpResp
@@ -54,7 +211,6 @@ func (resp *Response) Depth() uint32 {
 	return resp.depth
 }
 
-
 //数据是否有效
 func (resp *Response) Valid() bool {
 	return resp.httpResp != nil && resp.httpResp.Body != nil
@@ -63,9 +219,13 @@ func (resp *Response) Valid() bool {
 //条目
 type Item map[string]interface{}
 
+//条目中存放来源信息(即响应的元数据)的键
+const ITEM_META_KEY = "_meta"
+
+//条目中存放条目类型的键
+const ITEM_TYPE_KEY = "_type"
 
 //数据是否有效
 func (item Item) Valid() bool {
 	return item != nil
 }
-
@@ -0,0 +1,176 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 组件参数的容器。配置文件中的组件参数会被解码成此类型并传给组件的工厂函数。
+type Params map[string]interface{}
+
+// 判断参数是否存在。
+func (params Params) Has(key string) bool {
+	_, ok := params[key]
+	return ok
+}
+
+// 获得字符串参数。参数不存在时返回默认值。
+func (params Params) String(key string, def string) (string, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return def, paramTypeError(key, "string", v)
+	}
+	return s, nil
+}
+
+// 获得整数参数。参数不存在时返回默认值。
+func (params Params) Int(key string, def int) (int, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case uint:
+		return int(n), nil
+	case uint32:
+		return int(n), nil
+	case uint64:
+		return int(n), nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), nil
+		}
+	}
+	return def, paramTypeError(key, "integer", v)
+}
+
+// 获得浮点数参数。参数不存在时返回默认值。
+func (params Params) Float(key string, def float64) (float64, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	}
+	return def, paramTypeError(key, "number", v)
+}
+
+// 获得布尔参数。参数不存在时返回默认值。
+func (params Params) Bool(key string, def bool) (bool, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return def, paramTypeError(key, "boolean", v)
+	}
+	return b, nil
+}
+
+// 获得时长参数。参数值应为time.ParseDuration能够识别的字符串，例如"500ms"。
+func (params Params) Duration(key string, def time.Duration) (time.Duration, error) {
+	s, err := params.String(key, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def, &FieldError{Field: key, Err: err}
+	}
+	return d, nil
+}
+
+// 获得字符串列表参数。单个字符串会被视为只有一个元素的列表。
+func (params Params) Strings(key string) ([]string, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	switch list := v.(type) {
+	case string:
+		return []string{list}, nil
+	case []string:
+		return list, nil
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for i, e := range list {
+			s, ok := e.(string)
+			if !ok {
+				return nil, paramTypeError(fmt.Sprintf("%s[%d]", key, i), "string", e)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	}
+	return nil, paramTypeError(key, "string list", v)
+}
+
+// 获得嵌套的参数。
+func (params Params) Params(key string) (Params, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return Params{}, nil
+	}
+	switch m := v.(type) {
+	case Params:
+		return m, nil
+	case map[string]interface{}:
+		return Params(m), nil
+	}
+	return nil, paramTypeError(key, "map", v)
+}
+
+// 获得嵌套参数的列表。
+func (params Params) ParamsList(key string) ([]Params, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, paramTypeError(key, "list of maps", v)
+	}
+	result := make([]Params, 0, len(list))
+	for i, e := range list {
+		switch m := e.(type) {
+		case Params:
+			result = append(result, m)
+		case map[string]interface{}:
+			result = append(result, Params(m))
+		default:
+			return nil, paramTypeError(fmt.Sprintf("%s[%d]", key, i), "map", e)
+		}
+	}
+	return result, nil
+}
+
+// 生成参数类型错误。
+func paramTypeError(key string, expected string, v interface{}) error {
+	return &FieldError{
+		Field: key,
+		Err:   errors.New(fmt.Sprintf("The value should be a %s, but it's %T!", expected, v)),
+	}
+}
@@ -0,0 +1,509 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 可以说明自身的条目类型的值的接口类型。
+// 实现了它的结构体被转换为条目时，条目的ITEM_TYPE_KEY字段会被自动设置。
+type ItemTyper interface {
+	ItemType() string
+}
+
+// 条目转换错误。
+type ItemConversionError struct {
+	From string // 源类型。
+	To   string // 目标类型。
+	Err  error  // 原始错误，通常是*ArgsError。
+}
+
+func (ice *ItemConversionError) Error() string {
+	return fmt.Sprintf("Can not convert %s to %s: %s", ice.From, ice.To, ice.Err)
+}
+
+func (ice *ItemConversionError) Unwrap() error {
+	return ice.Err
+}
+
+// 把条目转换为v所指向的值，v通常是指向结构体的指针。
+// 结构体的字段与条目的字段按标签"item"对应，没有该标签时使用标签"json"，都没有时使用字段名。
+// 标签的格式为"name,omitempty"，名称为"-"的字段被忽略，没有标签的嵌入结构体的字段被视为外层的字段。
+// 字符串会被按base.CoerceValue的规则转换为数字、布尔值和时间，URL可以来自字符串。
+// 条目中多余的字段被忽略，值为nil的字段保持零值。所有字段的错误会被汇总在*ItemConversionError中。
+func DecodeItem(item Item, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New(fmt.Sprintf("The decoding target should be a non-nil pointer, but it's %T!", v))
+	}
+	if err := decodeValue(map[string]interface{}(item), rv.Elem()); err != nil {
+		return &ItemConversionError{From: "item", To: rv.Elem().Type().String(), Err: err}
+	}
+	return nil
+}
+
+// 把条目转换为T类型的值。见DecodeItem。
+func ItemAs[T any](item Item) (T, error) {
+	var v T
+	err := DecodeItem(item, &v)
+	return v, err
+}
+
+// 把结构体(或指向结构体的指针)转换为条目。字段的名称规则见DecodeItem。
+// 嵌套的结构体和映射被转换为map[string]interface{}，切片被转换为[]interface{}，
+// 时间、时长和URL被原样保留。若v实现了ItemTyper且没有设置条目类型，条目类型会被自动设置。
+func EncodeItem(v interface{}) (Item, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("The value to encode should be a struct, but it's %T!", v))
+	}
+	fields, err := encodeStruct(rv)
+	if err != nil {
+		return nil, &ItemConversionError{From: rv.Type().String(), To: "item", Err: err}
+	}
+	item := Item(fields)
+	if typer, ok := v.(ItemTyper); ok {
+		if t, _ := item[ITEM_TYPE_KEY].(string); t == "" {
+			item[ITEM_TYPE_KEY] = typer.ItemType()
+		}
+	}
+	return item, nil
+}
+
+// 把结构体写回条目并返回新的条目。结构体的字段会替换条目中的同名字段(包括被省略的空字段)，
+// 条目中的其他字段被保留。原条目不会被修改。
+func MergeItem(item Item, v interface{}) (Item, error) {
+	encoded, err := EncodeItem(v)
+	if err != nil {
+		return nil, err
+	}
+	result := make(Item, len(item)+len(encoded))
+	for k, e := range item {
+		result[k] = e
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, field := range itemFieldsOf(t) {
+		delete(result, field.name)
+	}
+	for k, e := range encoded {
+		result[k] = e
+	}
+	return result, nil
+}
+
+// 结构体中与条目的字段对应的字段。
+type itemStructField struct {
+	name      string // 条目中的字段名。
+	index     []int  // 字段在结构体中的索引序列。
+	omitEmpty bool   // 是否省略空值。
+}
+
+// 已解析的结构体字段的缓存。
+var itemFieldsCache sync.Map
+
+// 获得结构体类型中与条目的字段对应的字段。
+func itemFieldsOf(t reflect.Type) []itemStructField {
+	if cached, ok := itemFieldsCache.Load(t); ok {
+		return cached.([]itemStructField)
+	}
+	fields := collectItemFields(t, nil)
+	itemFieldsCache.Store(t, fields)
+	return fields
+}
+
+// 收集结构体的字段。外层的字段优先于嵌入结构体中的同名字段。
+func collectItemFields(t reflect.Type, prefix []int) []itemStructField {
+	fields := make([]itemStructField, 0, t.NumField())
+	var embedded []itemStructField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, prefix...), i)
+		tag, hasTag := sf.Tag.Lookup("item")
+		if !hasTag {
+			tag, hasTag = sf.Tag.Lookup("json")
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, collectItemFields(sf.Type, index)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, itemStructField{
+			name:      name,
+			index:     index,
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+		})
+	}
+	for _, field := range embedded {
+		shadowed := false
+		for _, f := range fields {
+			if f.name == field.name {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+// 把原始值转换后存入dst。
+func decodeValue(raw interface{}, dst reflect.Value) error {
+	if raw == nil {
+		return nil
+	}
+	rawValue := reflect.ValueOf(raw)
+	if rawValue.Type().AssignableTo(dst.Type()) {
+		dst.Set(rawValue)
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		elem := reflect.New(dst.Type().Elem())
+		if err := decodeValue(raw, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	s, isString := raw.(string)
+	switch dst.Type() {
+	case timeType:
+		switch v := raw.(type) {
+		case *time.Time:
+			if v != nil {
+				dst.Set(reflect.ValueOf(*v))
+			}
+			return nil
+		case string:
+			t, err := CoerceValue(v, TYPE_DATE, "", nil)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return decodeTypeError(raw, dst)
+	case durationType:
+		if isString {
+			d, err := time.ParseDuration(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			dst.SetInt(int64(d))
+			return nil
+		}
+	case urlType:
+		switch v := raw.(type) {
+		case *url.URL:
+			if v != nil {
+				dst.Set(reflect.ValueOf(*v))
+			}
+			return nil
+		case string:
+			u, err := url.Parse(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(*u))
+			return nil
+		}
+		return decodeTypeError(raw, dst)
+	}
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := raw.(type) {
+		case *url.URL:
+			if v != nil {
+				dst.SetString(v.String())
+			}
+			return nil
+		case []byte:
+			dst.SetString(string(v))
+			return nil
+		}
+		if rawValue.Kind() == reflect.String {
+			dst.SetString(rawValue.String())
+			return nil
+		}
+	case reflect.Bool:
+		if isString {
+			b, err := CoerceValue(s, TYPE_BOOL, "", nil)
+			if err != nil {
+				return err
+			}
+			dst.SetBool(b.(bool))
+			return nil
+		}
+		if rawValue.Kind() == reflect.Bool {
+			dst.SetBool(rawValue.Bool())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := integerValue(raw)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return errors.New(fmt.Sprintf("The value %d overflows %s!", n, dst.Type()))
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := integerValue(raw)
+		if err != nil {
+			return err
+		}
+		if n < 0 || dst.OverflowUint(uint64(n)) {
+			return errors.New(fmt.Sprintf("The value %d overflows %s!", n, dst.Type()))
+		}
+		dst.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if isString {
+			f, err := CoerceValue(s, TYPE_FLOAT, "", nil)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f.(float64))
+			return nil
+		}
+		if f, ok := floatValue(rawValue); ok {
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Struct:
+		if fields, ok := mapFields(raw); ok {
+			return decodeStruct(fields, dst)
+		}
+	case reflect.Map:
+		fields, ok := mapFields(raw)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		argsErr := NewArgsError()
+		result := reflect.MakeMapWithSize(dst.Type(), len(fields))
+		for k, e := range fields {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(e, elem); err != nil {
+				argsErr.Add(k, err)
+				continue
+			}
+			result.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(result)
+		return argsErr.ErrorOrNil()
+	case reflect.Slice:
+		if rawValue.Kind() != reflect.Slice && rawValue.Kind() != reflect.Array {
+			break
+		}
+		argsErr := NewArgsError()
+		result := reflect.MakeSlice(dst.Type(), rawValue.Len(), rawValue.Len())
+		for i := 0; i < rawValue.Len(); i++ {
+			argsErr.Add(fmt.Sprintf("[%d]", i), decodeValue(rawValue.Index(i).Interface(), result.Index(i)))
+		}
+		dst.Set(result)
+		return argsErr.ErrorOrNil()
+	}
+	return decodeTypeError(raw, dst)
+}
+
+// 把映射转换后存入结构体。
+func decodeStruct(fields map[string]interface{}, dst reflect.Value) error {
+	argsErr := NewArgsError()
+	for _, field := range itemFieldsOf(dst.Type()) {
+		raw, ok := fields[field.name]
+		if !ok {
+			continue
+		}
+		argsErr.Add(field.name, decodeValue(raw, dst.FieldByIndex(field.index)))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 生成类型不符的错误。
+func decodeTypeError(raw interface{}, dst reflect.Value) error {
+	return errors.New(fmt.Sprintf("Can not convert the value of type %T to %s!", raw, dst.Type()))
+}
+
+// 获得映射的字段。
+func mapFields(raw interface{}) (map[string]interface{}, bool) {
+	switch v := raw.(type) {
+	case Item:
+		return v, true
+	case Metadata:
+		return v, true
+	case Params:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// 获得整数的值。浮点数需没有小数部分，字符串会被转换。
+func integerValue(raw interface{}) (int64, error) {
+	if s, ok := raw.(string); ok {
+		n, err := CoerceValue(s, TYPE_INT, "", nil)
+		if err != nil {
+			return 0, err
+		}
+		return n.(int64), nil
+	}
+	rv := reflect.ValueOf(raw)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, errors.New(fmt.Sprintf("The value %d is too large!", rv.Uint()))
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, errors.New(fmt.Sprintf("The value %s is not an integer!",
+				strconv.FormatFloat(f, 'f', -1, 64)))
+		}
+		return int64(f), nil
+	}
+	return 0, errors.New(fmt.Sprintf("Can not convert the value of type %T to an integer!", raw))
+}
+
+// 获得数字的浮点数值。
+func floatValue(rv reflect.Value) (float64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// 把结构体转换为映射。
+func encodeStruct(rv reflect.Value) (map[string]interface{}, error) {
+	fields := itemFieldsOf(rv.Type())
+	result := make(map[string]interface{}, len(fields))
+	argsErr := NewArgsError()
+	for _, field := range fields {
+		fv := rv.FieldByIndex(field.index)
+		if field.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		v, err := encodeValue(fv)
+		if err != nil {
+			argsErr.Add(field.name, err)
+			continue
+		}
+		result[field.name] = v
+	}
+	return result, argsErr.ErrorOrNil()
+}
+
+// 把值转换为条目中的值。
+func encodeValue(rv reflect.Value) (interface{}, error) {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type() == reflect.PtrTo(urlType) || rv.Type() == reflect.PtrTo(timeType) {
+			return rv.Interface(), nil
+		}
+		return encodeValue(rv.Elem())
+	}
+	switch rv.Type() {
+	case timeType, durationType, urlType:
+		return rv.Interface(), nil
+	case reflect.TypeOf(Item{}), reflect.TypeOf(Metadata{}), reflect.TypeOf(map[string]interface{}{}),
+		reflect.TypeOf([]interface{}{}), reflect.TypeOf([]byte{}):
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Interface(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return encodeStruct(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.New(fmt.Sprintf("Unsupported map key type %s!", rv.Type().Key()))
+		}
+		result := make(map[string]interface{}, rv.Len())
+		argsErr := NewArgsError()
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			v, err := encodeValue(iter.Value())
+			if err != nil {
+				argsErr.Add(key, err)
+				continue
+			}
+			result[key] = v
+		}
+		return result, argsErr.ErrorOrNil()
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		result := make([]interface{}, rv.Len())
+		argsErr := NewArgsError()
+		for i := range result {
+			v, err := encodeValue(rv.Index(i))
+			if err != nil {
+				argsErr.Add(fmt.Sprintf("[%d]", i), err)
+				continue
+			}
+			result[i] = v
+		}
+		return result, argsErr.ErrorOrNil()
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, errors.New(fmt.Sprintf("Unsupported value type %s!", rv.Type()))
+	}
+	return rv.Interface(), nil
+}
+
+// 判断值是否为空，用于omitempty。
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"sys/fetch/config"
+	dl "sys/fetch/downloader"
+	sched "sys/fetch/scheduler"
+	"sys/fetch/tool"
+)
+
+// 检查点文件的内容。它同时保存了爬取配置，以便恢复时无需再次给出。
+type checkpointFile struct {
+	Spec  *config.Spec      `json:"spec"`
+	State *sched.Checkpoint `json:"state"`
+}
+
+// 爬取相关的标志。
+type crawlFlags struct {
+	fs          *flag.FlagSet
+	configPath  string
+	seeds       stringList
+	depth       uint
+	domains     stringList
+	schemes     stringList
+	allow       stringList
+	deny        stringList
+	concurrency uint
+	parsers     stringList
+	processors  stringList
+	timeout     string
+	proxy       string
+	headers     stringList
+	sitemaps    bool
+	noindex     bool
+	nofollow    bool
+	canonical   bool
+	nearDups    int
+	itemWorkers uint
+	batchSize   int
+	batchWait   time.Duration
+	checkpoint  string
+	archive     string
+	deadLetters string
+	interval    time.Duration
+	maxIdle     uint
+	progress    time.Duration
+	quiet       bool
+}
+
+// 创建爬取相关的标志。
+func newCrawlFlags(name string, argsUsage string) *crawlFlags {
+	cf := &crawlFlags{fs: flag.NewFlagSet(name, flag.ExitOnError)}
+	fs := cf.fs
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: fetch %s [flags] %s\n\nFlags:\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cf.configPath, "config", "", "crawl config file (.json, .yaml, .yml or .toml); flags override it")
+	fs.Var(&cf.seeds, "seed", "seed url (repeatable)")
+	fs.UintVar(&cf.depth, "depth", 1, "max crawl depth")
+	fs.Var(&cf.domains, "domain", "allowed primary domain (repeatable, default: the seeds' domains)")
+	fs.Var(&cf.schemes, "scheme", "allowed url scheme (repeatable, default: the schemes of the seeds)")
+	fs.Var(&cf.allow, "allow", "url regexp that must match (repeatable)")
+	fs.Var(&cf.deny, "deny", "url regexp that must not match (repeatable)")
+	fs.UintVar(&cf.concurrency, "concurrency", 3, "size of the downloader and analyzer pools")
+	fs.Var(&cf.parsers, "parser", "response parser as name or name={json params} (repeatable, default: a-tags)")
+	fs.Var(&cf.processors, "processor", "item processor as name or name={json params} (repeatable)")
+	fs.StringVar(&cf.timeout, "timeout", "", "HTTP client timeout, e.g. 30s")
+	fs.StringVar(&cf.proxy, "proxy", "", "HTTP proxy url")
+	fs.Var(&cf.headers, "header", "default request header as 'Name: Value' (repeatable)")
+	fs.BoolVar(&cf.sitemaps, "sitemaps", false, "discover sitemaps from robots.txt and /sitemap.xml and crawl their urls as seeds")
+	fs.BoolVar(&cf.noindex, "noindex", false, "drop the items of pages marked noindex by meta robots or X-Robots-Tag")
+	fs.BoolVar(&cf.nofollow, "nofollow", false, "drop the requests of pages marked nofollow by meta robots or X-Robots-Tag")
+	fs.BoolVar(&cf.canonical, "canonical", false, "treat rel=canonical urls as seen and drop the items of duplicate pages")
+	fs.IntVar(&cf.nearDups, "near-duplicates", -1, "skip pages whose SimHash is within this Hamming distance of a seen page, e.g. 3 (negative: off)")
+	fs.UintVar(&cf.itemWorkers, "item-workers", 0, "number of workers processing items (default: the analyzer pool size)")
+	fs.IntVar(&cf.batchSize, "batch-size", 0, "max items per batch for batch processors (0: no batching)")
+	fs.DurationVar(&cf.batchWait, "batch-wait", 100*time.Millisecond, "max time to wait for a batch to fill")
+	fs.StringVar(&cf.checkpoint, "checkpoint", "", "write a checkpoint to this file when the crawl stops")
+	fs.StringVar(&cf.archive, "archive", "", "record every response to this archive file")
+	fs.StringVar(&cf.deadLetters, "dead-letters", "", "append failed downloads and items to this dead letter file")
+	fs.DurationVar(&cf.interval, "interval", 10*time.Millisecond, "idle check interval")
+	fs.UintVar(&cf.maxIdle, "max-idle", 1000, "stop after this many consecutive idle checks (min 1000)")
+	fs.DurationVar(&cf.progress, "progress", 2*time.Second, "interval between progress reports on stderr")
+	fs.BoolVar(&cf.quiet, "quiet", false, "only report errors on stderr")
+	return cf
+}
+
+// 生成爬取配置。配置文件中的设定会被显式给出的标志覆盖。
+func (cf *crawlFlags) spec(base *config.Spec) (*config.Spec, error) {
+	spec := base
+	if cf.configPath != "" {
+		s, err := config.DecodeFile(cf.configPath)
+		if err != nil {
+			return nil, err
+		}
+		spec = s
+	}
+	if spec == nil {
+		spec = &config.Spec{
+			Channels: config.ChannelSpec{
+				ReqChanLen: 10, RespChanLen: 10, ItemChanLen: 10, ErrorChanLen: 10,
+			},
+			Depth:   uint32(cf.depth),
+			Parsers: []config.ComponentSpec{{Name: "a-tags"}},
+		}
+		spec.Pools = config.PoolSpec{
+			PageDownloaderPoolSize: uint32(cf.concurrency),
+			AnalyzerPoolSize:       uint32(cf.concurrency),
+		}
+	}
+	var err error
+	cf.fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		err = cf.apply(spec, f.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// 把显式给出的标志应用到爬取配置上。
+func (cf *crawlFlags) apply(spec *config.Spec, name string) error {
+	switch name {
+	case "seed":
+		spec.Seeds = cf.seeds
+	case "depth":
+		spec.Depth = uint32(cf.depth)
+	case "domain":
+		spec.Scope.Domains = cf.domains
+	case "scheme":
+		spec.Scope.Schemes = cf.schemes
+	case "allow":
+		spec.Scope.Allow = cf.allow
+	case "deny":
+		spec.Scope.Deny = cf.deny
+	case "concurrency":
+		spec.Pools.PageDownloaderPoolSize = uint32(cf.concurrency)
+		spec.Pools.AnalyzerPoolSize = uint32(cf.concurrency)
+	case "parser":
+		spec.Parsers = nil
+		for _, p := range cf.parsers {
+			cs, err := parseComponent(p)
+			if err != nil {
+				return err
+			}
+			spec.Parsers = append(spec.Parsers, cs)
+		}
+	case "processor":
+		spec.Processors = nil
+		for _, p := range cf.processors {
+			cs, err := parseComponent(p)
+			if err != nil {
+				return err
+			}
+			spec.Processors = append(spec.Processors, cs)
+		}
+	case "sitemaps":
+		spec.Sitemaps = cf.sitemaps
+	case "noindex":
+		spec.Directives.Noindex = cf.noindex
+	case "nofollow":
+		spec.Directives.Nofollow = cf.nofollow
+	case "canonical":
+		spec.Directives.Canonical = cf.canonical
+	case "near-duplicates":
+		spec.NearDuplicates = nil
+		if cf.nearDups >= 0 {
+			spec.NearDuplicates = &cf.nearDups
+		}
+	case "item-workers":
+		spec.Pipeline.Workers = uint32(cf.itemWorkers)
+	case "batch-size":
+		spec.Pipeline.BatchSize = cf.batchSize
+		if spec.Pipeline.BatchWait == "" {
+			spec.Pipeline.BatchWait = cf.batchWait.String()
+		}
+	case "batch-wait":
+		spec.Pipeline.BatchWait = cf.batchWait.String()
+	case "timeout":
+		spec.Client.Timeout = cf.timeout
+	case "proxy":
+		spec.Client.Proxy = cf.proxy
+	case "header":
+		if spec.Client.Headers == nil {
+			spec.Client.Headers = make(map[string]string)
+		}
+		for _, h := range cf.headers {
+			name, value, err := parseHeader(h)
+			if err != nil {
+				return err
+			}
+			spec.Client.Headers[name] = value
+		}
+	}
+	return nil
+}
+
+// 一次爬取的运行参数。
+type crawlRun struct {
+	flags      *crawlFlags
+	spec       *config.Spec
+	checkpoint *sched.Checkpoint    // 恢复爬取所用的检查点。
+	replay     []*dl.ArchiveRecord  // 重放所用的存档记录。
+	letters    []*sched.DeadLetter  // 需要重新投递的死信。
+	options    []sched.ConfigOption // 附加的调度器配置项。
+}
+
+// 执行crawl子命令。
+func runCrawl(args []string) error {
+	cf := newCrawlFlags("crawl", "")
+	cf.fs.Parse(args)
+	if cf.fs.NArg() > 0 {
+		return errors.New(fmt.Sprintf("Unexpected arguments %v!", cf.fs.Args()))
+	}
+	spec, err := cf.spec(nil)
+	if err != nil {
+		return err
+	}
+	return (&crawlRun{flags: cf, spec: spec}).run()
+}
+
+// 执行resume子命令。
+func runResume(args []string) error {
+	cf := newCrawlFlags("resume", "<checkpoint>")
+	cf.fs.Parse(args)
+	if cf.fs.NArg() != 1 {
+		cf.fs.Usage()
+		return errors.New("A checkpoint file is required!")
+	}
+	path := cf.fs.Arg(0)
+	cpFile, err := readCheckpointFile(path)
+	if err != nil {
+		return err
+	}
+	spec, err := cf.spec(cpFile.Spec)
+	if err != nil {
+		return err
+	}
+	if cf.checkpoint == "" {
+		cf.checkpoint = path
+	}
+	return (&crawlRun{flags: cf, spec: spec, checkpoint: cpFile.State}).run()
+}
+
+// 执行replay子命令。
+func runReplay(args []string) error {
+	cf := newCrawlFlags("replay", "<archive>")
+	cf.fs.Parse(args)
+	if cf.fs.NArg() != 1 {
+		cf.fs.Usage()
+		return errors.New("An archive file is required!")
+	}
+	records, err := dl.ReadArchive(cf.fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New(fmt.Sprintf("The archive '%s' is empty!", cf.fs.Arg(0)))
+	}
+	spec, err := cf.spec(nil)
+	if err != nil {
+		return err
+	}
+	if len(spec.Seeds) == 0 {
+		spec.Seeds = []string{records[0].Url}
+	}
+	return (&crawlRun{flags: cf, spec: spec, replay: records}).run()
+}
+
+// 执行redeliver子命令。死信中的请求会被重新下载，条目会被重新处理。
+func runRedeliver(args []string) error {
+	cf := newCrawlFlags("redeliver", "<deadletters>")
+	cf.fs.Parse(args)
+	if cf.fs.NArg() != 1 {
+		cf.fs.Usage()
+		return errors.New("A dead letter file is required!")
+	}
+	path := cf.fs.Arg(0)
+	if cf.deadLetters == path {
+		return errors.New("The new dead letter file should differ from the redelivered one!")
+	}
+	letters, err := sched.ReadDeadLetters(path)
+	if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		return errors.New(fmt.Sprintf("The dead letter file '%s' is empty!", path))
+	}
+	spec, err := cf.spec(nil)
+	if err != nil {
+		return err
+	}
+	if len(spec.Seeds) == 0 {
+		for _, letter := range letters {
+			if letter.Request != nil {
+				spec.Seeds = []string{letter.Request.Url}
+				break
+			}
+		}
+	}
+	if len(spec.Seeds) == 0 {
+		return errors.New("The dead letters contain no request! Give the seeds by -seed or -config.")
+	}
+	return (&crawlRun{flags: cf, spec: spec, letters: letters}).run()
+}
+
+// 执行爬取，直到调度器空闲一段时间或收到中断信号为止。
+func (cr *crawlRun) run() error {
+	cf := cr.flags
+	schedConfig, err := cr.spec.Build()
+	if err != nil {
+		return err
+	}
+	if cr.checkpoint != nil {
+		schedConfig.Apply(sched.WithCheckpoint(cr.checkpoint))
+	}
+	if cr.replay != nil {
+		transport := dl.NewReplayTransport(cr.replay)
+		schedConfig.Apply(sched.WithHttpClientGenerator(func() *http.Client {
+			return &http.Client{Transport: transport}
+		}))
+	}
+	if cf.archive != "" {
+		recorder, err := dl.NewArchiveRecorder(cf.archive)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		genHttpClient := schedConfig.HttpClientGenerator()
+		schedConfig.Apply(sched.WithHttpClientGenerator(func() *http.Client {
+			client := genHttpClient()
+			client.Transport = recorder.Wrap(client.Transport)
+			return client
+		}))
+	}
+	if cr.letters != nil {
+		schedConfig.Apply(sched.WithDeadLetters(cr.letters...))
+	}
+	var deadLetterStore sched.DeadLetterStore
+	if cf.deadLetters != "" {
+		deadLetterStore, err = sched.NewDeadLetterFile(cf.deadLetters)
+		if err != nil {
+			return err
+		}
+		defer deadLetterStore.Close()
+		schedConfig.Apply(sched.WithDeadLetterStore(deadLetterStore))
+	}
+	schedConfig.Apply(cr.options...)
+
+	scheduler := sched.NewScheduler()
+	progress := newProgress(os.Stderr, cf.progress, cf.quiet)
+	checkCountChan := tool.Monitoring(
+		scheduler, cf.interval, cf.maxIdle, true, false, progress.record)
+	if err := scheduler.StartWithConfig(schedConfig); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	select {
+	case <-checkCountChan:
+	case <-interrupt:
+		fmt.Fprintln(os.Stderr, "Interrupted, stopping the scheduler...")
+		scheduler.Stop()
+	}
+	progress.flush()
+	if !cf.quiet {
+		for _, stats := range scheduler.ItemProcessorStats() {
+			fmt.Fprintf(os.Stderr, "Item processor %s\n", stats)
+		}
+	}
+
+	if deadLetterStore != nil && deadLetterStore.Count() > 0 {
+		fmt.Fprintf(os.Stderr, "Dead letters written to %s: %d\n", cf.deadLetters, deadLetterStore.Count())
+	}
+
+	if cf.checkpoint != "" {
+		state := scheduler.Checkpoint()
+		if err := writeCheckpointFile(cf.checkpoint, &checkpointFile{Spec: cr.spec, State: state}); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Checkpoint written to %s: %s\n", cf.checkpoint, state)
+	}
+	return nil
+}
+
+// 读取检查点文件。
+func readCheckpointFile(path string) (*checkpointFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cpFile := &checkpointFile{}
+	if err := json.Unmarshal(data, cpFile); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid checkpoint file '%s': %s", path, err))
+	}
+	if cpFile.Spec == nil || cpFile.State == nil {
+		return nil, errors.New(fmt.Sprintf("Invalid checkpoint file '%s': missing spec or state!", path))
+	}
+	return cpFile, nil
+}
+
+// 写入检查点文件。文件会先被写到临时文件中再被改名，以免中途失败时损坏原有的检查点。
+func writeCheckpointFile(path string, cpFile *checkpointFile) error {
+	data, err := json.MarshalIndent(cpFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sys/fetch/config"
+	sched "sys/fetch/scheduler"
+)
+
+func TestCheckpointFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.checkpoint")
+	cpFile := &checkpointFile{
+		Spec: &config.Spec{Depth: 2, Seeds: []string{"http://example.com/"}},
+		State: &sched.Checkpoint{
+			CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+			Pending:   []sched.CheckpointRequest{{Method: "GET", Url: "http://example.com/a", Depth: 1}},
+			Seen:      []string{"http://example.com/", "http://example.com/a"},
+		},
+	}
+	if err := writeCheckpointFile(path, cpFile); err != nil {
+		t.Fatal(err)
+	}
+	//再次写入时原有的检查点被替换，临时文件不会被留下
+	cpFile.State.Seen = append(cpFile.State.Seen, "http://example.com/b")
+	if err := writeCheckpointFile(path, cpFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("The temporary file should be renamed, but got %v!", err)
+	}
+	read, err := readCheckpointFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Spec.Depth != 2 || len(read.Spec.Seeds) != 1 || !read.State.CreatedAt.Equal(cpFile.State.CreatedAt) ||
+		len(read.State.Pending) != 1 || read.State.Pending[0].Url != "http://example.com/a" ||
+		len(read.State.Seen) != 3 {
+		t.Errorf("Unexpected checkpoint file %v %s!", read.Spec, read.State)
+	}
+	if err := read.State.Check(); err != nil {
+		t.Error(err)
+	}
+
+	//写入失败时原有的检查点保持不变
+	if err := os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCheckpointFile(path, &checkpointFile{Spec: &config.Spec{}, State: &sched.Checkpoint{}}); err == nil {
+		t.Errorf("Expected an error when the temporary file can not be written!")
+	}
+	if read, err := readCheckpointFile(path); err != nil || len(read.State.Seen) != 3 {
+		t.Errorf("The checkpoint should be kept, but got %v!", err)
+	}
+
+	invalid := filepath.Join(filepath.Dir(path), "invalid.checkpoint")
+	os.WriteFile(invalid, []byte(`{"spec": {}}`), 0644)
+	if _, err := readCheckpointFile(invalid); err == nil {
+		t.Errorf("Expected an error for the checkpoint file without state!")
+	}
+}
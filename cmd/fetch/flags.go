@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"sys/fetch/config"
+)
+
+// 可以重复出现的字符串标志。
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// 解析组件标志。格式为"name"或"name=<JSON格式的参数>"。
+func parseComponent(value string) (config.ComponentSpec, error) {
+	cs := config.ComponentSpec{}
+	name, params, hasParams := strings.Cut(value, "=")
+	cs.Name = strings.TrimSpace(name)
+	if cs.Name == "" {
+		return cs, errors.New(fmt.Sprintf("The component '%s' has no name!", value))
+	}
+	if hasParams {
+		if err := json.Unmarshal([]byte(params), &cs.Params); err != nil {
+			return cs, errors.New(
+				fmt.Sprintf("The params of component '%s' are not a JSON object: %s", cs.Name, err))
+		}
+	}
+	return cs, nil
+}
+
+// 解析请求头标志。格式为"Name: Value"。
+func parseHeader(value string) (string, string, error) {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return "", "", errors.New(fmt.Sprintf("The header '%s' should be 'Name: Value'!", value))
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(v), nil
+}
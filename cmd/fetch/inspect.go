@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	dl "sys/fetch/downloader"
+	sched "sys/fetch/scheduler"
+)
+
+// 执行inspect子命令。它会根据文件的内容判断文件的种类并打印摘要。
+func runInspect(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: fetch inspect <checkpoint|archive|dead letter|output file>")
+	}
+	path := args[0]
+	if cpFile, err := readCheckpointFile(path); err == nil {
+		return inspectCheckpoint(os.Stdout, path, cpFile)
+	}
+	if letters, err := sched.ReadDeadLetters(path); err == nil && len(letters) > 0 {
+		return inspectDeadLetters(os.Stdout, path, letters)
+	}
+	return inspectLines(os.Stdout, path)
+}
+
+// 打印检查点文件的摘要。
+func inspectCheckpoint(w io.Writer, path string, cpFile *checkpointFile) error {
+	state := cpFile.State
+	fmt.Fprintf(w, "Checkpoint: %s\n", path)
+	fmt.Fprintf(w, "  Created at: %s\n", state.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "  Seeds: %s\n", strings.Join(cpFile.Spec.Seeds, ", "))
+	fmt.Fprintf(w, "  Crawl depth: %d\n", cpFile.Spec.Depth)
+	fmt.Fprintf(w, "  Seen urls: %d\n", len(state.Seen))
+	fmt.Fprintf(w, "  Pending requests: %d\n", len(state.Pending))
+	depths := make(map[uint32]int)
+	for _, cr := range state.Pending {
+		depths[cr.Depth]++
+	}
+	for _, depth := range sortedKeys(depths) {
+		fmt.Fprintf(w, "    depth %d: %d\n", depth, depths[depth])
+	}
+	return nil
+}
+
+// 打印死信文件的摘要。
+func inspectDeadLetters(w io.Writer, path string, letters []*sched.DeadLetter) error {
+	kindCounts := make(map[string]int)
+	codeCounts := make(map[string]int)
+	for _, letter := range letters {
+		kindCounts[letter.Kind]++
+		codeCounts[letter.Code]++
+	}
+	fmt.Fprintf(w, "Dead letters: %s\n", path)
+	fmt.Fprintf(w, "  From %s to %s\n", letters[0].Time.Format("2006-01-02 15:04:05"),
+		letters[len(letters)-1].Time.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "  Requests: %d\n", kindCounts[sched.DEAD_LETTER_REQUEST])
+	fmt.Fprintf(w, "  Items: %d\n", kindCounts[sched.DEAD_LETTER_ITEM])
+	codes := make([]string, 0, len(codeCounts))
+	for code := range codeCounts {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	fmt.Fprintln(w, "  Components:")
+	for _, code := range codes {
+		fmt.Fprintf(w, "    %s: %d\n", code, codeCounts[code])
+	}
+	return nil
+}
+
+// 打印由JSON行组成的文件的摘要。存档文件会被单独识别。
+func inspectLines(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var records, archiveRecords, invalid int
+	statusCounts := make(map[int]int)
+	fieldCounts := make(map[string]int)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			records++
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(line, &fields) != nil {
+				invalid++
+			} else if isArchiveRecord(fields) {
+				record := &dl.ArchiveRecord{}
+				if json.Unmarshal(line, record) == nil {
+					archiveRecords++
+					statusCounts[record.StatusCode]++
+				}
+			} else {
+				for field := range fields {
+					fieldCounts[field]++
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if records > 0 && archiveRecords == records {
+		fmt.Fprintf(w, "Archive: %s\n", path)
+		fmt.Fprintf(w, "  Responses: %d\n", archiveRecords)
+		for _, code := range sortedKeys(statusCounts) {
+			fmt.Fprintf(w, "    status %d: %d\n", code, statusCounts[code])
+		}
+		return nil
+	}
+	fmt.Fprintf(w, "Output: %s\n", path)
+	fmt.Fprintf(w, "  Records: %d (invalid: %d)\n", records, invalid)
+	fields := make([]string, 0, len(fieldCounts))
+	for field := range fieldCounts {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fieldCounts[fields[i]] != fieldCounts[fields[j]] {
+			return fieldCounts[fields[i]] > fieldCounts[fields[j]]
+		}
+		return fields[i] < fields[j]
+	})
+	if len(fields) > 0 {
+		fmt.Fprintln(w, "  Fields:")
+	}
+	for _, field := range fields {
+		fmt.Fprintf(w, "    %s: %d\n", field, fieldCounts[field])
+	}
+	return nil
+}
+
+// 判断JSON对象是否为存档记录。
+func isArchiveRecord(fields map[string]json.RawMessage) bool {
+	for _, key := range []string{"url", "statusCode", "header", "body"} {
+		if _, ok := fields[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 获得按升序排列的键。
+func sortedKeys[K int | uint32](m map[K]int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
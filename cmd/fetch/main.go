@@ -0,0 +1,58 @@
+// fetch是爬虫的命令行工具。
+//
+// 用法：
+//
+//	fetch crawl     [flags]               开始一次爬取
+//	fetch resume    [flags] <checkpoint>  从检查点恢复爬取
+//	fetch replay    [flags] <archive>     从存档重放爬取，不访问网络
+//	fetch redeliver [flags] <deadletters> 重新投递死信中的请求和条目
+//	fetch inspect   <file>                打印检查点、存档、死信或输出文件的摘要
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var usage = `Usage: fetch <command> [flags] [args]
+
+Commands:
+  crawl     Start a crawl from seeds, flags or a config file.
+  resume    Resume a crawl from a checkpoint file.
+  replay    Replay a crawl from an archive file without touching the network.
+  redeliver Retry the failed requests and items of a dead letter file.
+  inspect   Print a summary of a checkpoint, archive, dead letter or output file.
+
+Run 'fetch <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "crawl":
+		err = runCrawl(args)
+	case "resume":
+		err = runResume(args)
+	case "replay":
+		err = runReplay(args)
+	case "redeliver":
+		err = runRedeliver(args)
+	case "inspect":
+		err = runInspect(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'!\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fetch %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
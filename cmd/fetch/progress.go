@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// 进度报告器。它接收调度监控函数记录的信息，并把它们输出到标准错误输出。
+// 普通的摘要信息会被节流，警告和错误则会被立即输出。
+type progress struct {
+	writer   io.Writer     // 输出目标。
+	interval time.Duration // 两次摘要输出之间的最小间隔。
+	quiet    bool          // 是否只输出错误。
+	last     time.Time     // 上次输出摘要的时间。
+	pending  string        // 尚未输出的最新摘要。
+	mutex    sync.Mutex    // 互斥锁。
+}
+
+// 创建进度报告器。
+func newProgress(writer io.Writer, interval time.Duration, quiet bool) *progress {
+	return &progress{writer: writer, interval: interval, quiet: quiet}
+}
+
+// 记录信息。它符合tool.Record的函数签名。
+func (p *progress) record(level byte, content string) {
+	if content == "" {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	switch level {
+	case 0:
+		if p.quiet {
+			return
+		}
+		if time.Since(p.last) < p.interval {
+			p.pending = content
+			return
+		}
+		p.write("PROGRESS", content)
+		p.last = time.Now()
+		p.pending = ""
+	case 1:
+		if !p.quiet {
+			p.write("WARN", content)
+		}
+	default:
+		p.write("ERROR", content)
+	}
+}
+
+// 输出尚未输出的最新摘要。
+func (p *progress) flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.pending != "" {
+		p.write("PROGRESS", p.pending)
+		p.pending = ""
+	}
+}
+
+func (p *progress) write(tag string, content string) {
+	fmt.Fprintf(p.writer, "[%s] %s %s\n", tag, time.Now().Format("15:04:05"), content)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	sched "sys/fetch/scheduler"
+)
+
+// 根据配置生成被用来生成HTTP客户端的函数。
+func (cs *ClientSpec) httpClientGenerator() (sched.GenHttpClient, error) {
+	var timeout time.Duration
+	if cs.Timeout != "" {
+		d, err := time.ParseDuration(cs.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = d
+	}
+	var proxyUrl *url.URL
+	if cs.Proxy != "" {
+		u, err := url.Parse(cs.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxyUrl = u
+	}
+	headers := make(http.Header)
+	for name, value := range cs.Headers {
+		headers.Set(name, value)
+	}
+	return func() *http.Client {
+		transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+		if proxyUrl != nil {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		}
+		var rt http.RoundTripper = transport
+		if len(headers) > 0 {
+			rt = &headerTransport{base: transport, headers: headers}
+		}
+		return &http.Client{Timeout: timeout, Transport: rt}
+	}, nil
+}
+
+// 为每个请求补充默认请求头的HTTP传输。请求中已有的请求头不会被覆盖。
+type headerTransport struct {
+	base    http.RoundTripper // 实际执行请求的HTTP传输。
+	headers http.Header       // 默认的请求头。
+}
+
+func (ht *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	newReq := req.Clone(req.Context())
+	for name, values := range ht.headers {
+		if newReq.Header.Get(name) == "" {
+			newReq.Header[name] = values
+		}
+	}
+	return ht.base.RoundTrip(newReq)
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	sched "sys/fetch/scheduler"
+)
+
+// 配置文件的格式。
+type Format string
+
+// 支持的配置文件格式。
+const (
+	FORMAT_JSON Format = "json"
+	FORMAT_YAML Format = "yaml"
+	FORMAT_TOML Format = "toml"
+)
+
+// 根据文件的扩展名推断配置文件的格式。
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FORMAT_JSON, nil
+	case ".yaml", ".yml":
+		return FORMAT_YAML, nil
+	case ".toml":
+		return FORMAT_TOML, nil
+	}
+	return "", errors.New(fmt.Sprintf("Unsupported config file '%s'!"+
+		" (supported extensions: .json, .yaml, .yml, .toml)", path))
+}
+
+// 读取配置文件。配置会在返回之前被检查。
+func LoadFile(path string) (*Spec, error) {
+	spec, err := DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Check(); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid config file '%s': %s", path, err))
+	}
+	return spec, nil
+}
+
+// 以给定的格式读取配置。配置会在返回之前被检查。
+func Load(reader io.Reader, format Format) (*Spec, error) {
+	spec, err := Decode(reader, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Check(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// 解码配置文件，但不检查配置。调用方可以在检查之前修改配置。
+func DecodeFile(path string) (*Spec, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	spec, err := Decode(file, format)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid config file '%s': %s", path, err))
+	}
+	return spec, nil
+}
+
+// 以给定的格式解码配置，但不检查配置。
+func Decode(reader io.Reader, format Format) (*Spec, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	switch format {
+	case FORMAT_JSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(spec)
+	case FORMAT_YAML:
+		err = yaml.UnmarshalStrict(data, spec)
+	case FORMAT_TOML:
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), spec)
+		if err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				err = errors.New(fmt.Sprintf("Unknown fields %v!", undecoded))
+			}
+		}
+	default:
+		err = errors.New(fmt.Sprintf("Unsupported config format '%s'!", format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// 读取配置文件并生成调度器的配置。生成时配置已被检查，因此不必再调用LoadFile。
+func LoadSchedulerConfig(path string) (*sched.Config, error) {
+	spec, err := DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := spec.Build()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid config file '%s': %s", path, err))
+	}
+	return config, nil
+}
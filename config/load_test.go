@@ -0,0 +1,224 @@
+package config
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+)
+
+// 工厂函数"config-test-counted-parser"被调用的次数。
+var countedParserBuilds uint32
+
+func init() {
+	anlz.Register("config-test-parser",
+		func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			return nil, nil
+		})
+	anlz.RegisterFactory("config-test-counted-parser", func(params base.Params) (anlz.ParseResponse, error) {
+		atomic.AddUint32(&countedParserBuilds, 1)
+		return func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			return nil, nil
+		}, nil
+	})
+}
+
+var validYaml = `
+channels: {reqChanLen: 10, respChanLen: 10, itemChanLen: 10, errorChanLen: 10}
+pools: {pageDownloaderPoolSize: 3, analyzerPoolSize: 3}
+depth: 2
+seeds: [http://www.sogou.com]
+scope:
+  schemes: [http, https]
+  deny: ['\.pdf$']
+client:
+  timeout: 30s
+  headers: {User-Agent: fetch}
+parsers:
+  - name: config-test-parser
+`
+
+var validToml = `
+depth = 1
+seeds = ["http://www.sogou.com"]
+
+[channels]
+reqChanLen = 10
+respChanLen = 10
+itemChanLen = 10
+errorChanLen = 10
+
+[pools]
+pageDownloaderPoolSize = 3
+analyzerPoolSize = 3
+
+[[parsers]]
+name = "config-test-parser"
+`
+
+var validJson = `{
+  "channels": {"reqChanLen": 10, "respChanLen": 10, "itemChanLen": 10, "errorChanLen": 10},
+  "pools": {"pageDownloaderPoolSize": 3, "analyzerPoolSize": 3},
+  "seeds": ["http://www.sogou.com"],
+  "parsers": [{"name": "config-test-parser"}]
+}`
+
+func TestLoad(t *testing.T) {
+	sources := map[Format]string{
+		FORMAT_YAML: validYaml,
+		FORMAT_TOML: validToml,
+		FORMAT_JSON: validJson,
+	}
+	for format, source := range sources {
+		spec, err := Load(strings.NewReader(source), format)
+		if err != nil {
+			t.Errorf("Unexpected error when loading %s config: %s", format, err)
+			continue
+		}
+		config, err := spec.Build()
+		if err != nil {
+			t.Errorf("Unexpected error when building %s config: %s", format, err)
+			continue
+		}
+		if len(config.Seeds()) != 1 || len(config.RespParsers()) != 1 {
+			t.Errorf("The %s config is built incorrectly: %s", format, config)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	source := `
+channels: {reqChanLen: 10, respChanLen: 0, itemChanLen: 10, errorChanLen: 10}
+pools: {pageDownloaderPoolSize: 3, analyzerPoolSize: 3}
+seeds: [http://www.sogou.com, sogou]
+scope: {allow: ['(']}
+client: {timeout: 3x}
+parsers: [{name: config-test-parser}, {name: unknown-parser}]
+`
+	_, err := Load(strings.NewReader(source), FORMAT_YAML)
+	if err == nil {
+		t.Fatal("The invalid config should be rejected!")
+	}
+	argsErr, ok := err.(*base.ArgsError)
+	if !ok {
+		t.Fatalf("The error should be a *base.ArgsError, but it's %T!", err)
+	}
+	expectedFields := []string{
+		"channels.respChanLen",
+		"seeds[1]",
+		"scope.allow[0]",
+		"client.timeout",
+		"parsers[1]",
+	}
+	if len(argsErr.Errors()) != len(expectedFields) {
+		t.Fatalf("The error number should be %d, but it's %d! (%s)",
+			len(expectedFields), len(argsErr.Errors()), err)
+	}
+	for i, e := range argsErr.Errors() {
+		fe, ok := e.(*base.FieldError)
+		if !ok || fe.Field != expectedFields[i] {
+			t.Errorf("The field of error %d should be '%s', but it's '%s'!",
+				i, expectedFields[i], e)
+		}
+	}
+}
+
+func TestLoadParams(t *testing.T) {
+	source := `
+channels: {reqChanLen: 10, respChanLen: 10, itemChanLen: 10, errorChanLen: 10}
+pools: {pageDownloaderPoolSize: 3, analyzerPoolSize: 3}
+seeds: [http://www.sogou.com]
+parsers: [{name: a-tags}]
+processors:
+  - name: set-fields
+    params:
+      fields: {source: sogou, nested: {level: 1}}
+  - name: drop-fields
+    params: {fields: 1}
+`
+	_, err := Load(strings.NewReader(source), FORMAT_YAML)
+	if err == nil {
+		t.Fatal("The invalid params should be rejected!")
+	}
+	fe, ok := err.(*base.ArgsError).Errors()[0].(*base.FieldError)
+	if !ok || fe.Field != "processors[1].fields" {
+		t.Errorf("The error should be reported at 'processors[1].fields', but it's '%s'!", err)
+	}
+}
+
+// 读取配置文件并生成调度器的配置时，每个组件只被生成一次。
+func TestLoadSchedulerConfigBuildsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.yaml")
+	source := `
+channels: {reqChanLen: 10, respChanLen: 10, itemChanLen: 10, errorChanLen: 10}
+pools: {pageDownloaderPoolSize: 3, analyzerPoolSize: 3}
+seeds: [http://www.sogou.com]
+parsers: [{name: config-test-counted-parser}]
+`
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreUint32(&countedParserBuilds, 0)
+	config, err := LoadSchedulerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.RespParsers()) != 1 {
+		t.Errorf("Unexpected parsers in %s!", config)
+	}
+	if n := atomic.LoadUint32(&countedParserBuilds); n != 1 {
+		t.Errorf("The parser should be built once, but it's built %d times!", n)
+	}
+
+	//组件的错误与其他字段的错误一并被报告
+	spec, _ := Decode(strings.NewReader(source+"depth: 1\nclient: {timeout: 3x}\n"), FORMAT_YAML)
+	spec.Parsers = append(spec.Parsers, ComponentSpec{Name: "unknown-parser"})
+	_, err = spec.Build()
+	argsErr, ok := err.(*base.ArgsError)
+	if !ok || len(argsErr.Errors()) != 2 || !strings.Contains(err.Error(), "client.timeout") ||
+		!strings.Contains(err.Error(), "parsers[1]") {
+		t.Errorf("Expected the errors of client.timeout and parsers[1], but got %v!", err)
+	}
+}
+
+// 请求可以通过名称引用配置中的解析函数实例。
+func TestLoadNamedParsers(t *testing.T) {
+	source := `
+channels: {reqChanLen: 10, respChanLen: 10, itemChanLen: 10, errorChanLen: 10}
+pools: {pageDownloaderPoolSize: 3, analyzerPoolSize: 3}
+seeds: [http://www.sogou.com]
+parsers:
+  - name: links
+    params: {parsers: [product]}
+namedParsers:
+  product: {name: config-test-counted-parser, provenance: true}
+`
+	spec, err := Load(strings.NewReader(source), FORMAT_YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.RespParsers()) != 1 || len(config.NamedParsers()) != 1 || config.NamedParsers()["product"] == nil {
+		t.Errorf("The named parsers are built incorrectly: %s", config)
+	}
+
+	source += "  broken: {name: unknown-parser}\n  '': {name: config-test-parser}\n"
+	_, err = Load(strings.NewReader(source), FORMAT_YAML)
+	argsErr, ok := err.(*base.ArgsError)
+	if !ok || len(argsErr.Errors()) != 2 {
+		t.Fatalf("Expected 2 errors, but got %v!", err)
+	}
+	for i, field := range []string{"namedParsers", "namedParsers.broken"} {
+		if fe, ok := argsErr.Errors()[i].(*base.FieldError); !ok || fe.Field != field {
+			t.Errorf("The field of error %d should be '%s', but it's '%s'!", i, field, argsErr.Errors()[i])
+		}
+	}
+}
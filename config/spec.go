@@ -0,0 +1,398 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+	ipl "sys/fetch/itempipeline"
+	sched "sys/fetch/scheduler"
+)
+
+// 爬取配置文件的内容。它以声明的方式描述了一次爬取。
+// NearDuplicates是近似重复的页面的指纹间的最大海明距离，未给出时不检测近似重复的页面。
+// NamedParsers是请求可以通过名称(例如链接规则的parsers参数)引用的响应解析函数，
+// 它们只在请求指定其名称时才被使用，与已注册的解析函数同名时以它们为准。
+type Spec struct {
+	Channels       ChannelSpec              `json:"channels" yaml:"channels" toml:"channels"`
+	Pools          PoolSpec                 `json:"pools" yaml:"pools" toml:"pools"`
+	Depth          uint32                   `json:"depth" yaml:"depth" toml:"depth"`
+	Seeds          []string                 `json:"seeds" yaml:"seeds" toml:"seeds"`
+	Scope          ScopeSpec                `json:"scope" yaml:"scope" toml:"scope"`
+	Client         ClientSpec               `json:"client" yaml:"client" toml:"client"`
+	Parsers        []ComponentSpec          `json:"parsers" yaml:"parsers" toml:"parsers"`
+	NamedParsers   map[string]ComponentSpec `json:"namedParsers,omitempty" yaml:"namedParsers" toml:"namedParsers"`
+	Processors     []ComponentSpec          `json:"processors" yaml:"processors" toml:"processors"`
+	Sitemaps       bool                     `json:"sitemaps,omitempty" yaml:"sitemaps" toml:"sitemaps"`
+	Directives     DirectivesSpec           `json:"directives" yaml:"directives" toml:"directives"`
+	NearDuplicates *int                     `json:"nearDuplicates,omitempty" yaml:"nearDuplicates" toml:"nearDuplicates"`
+	Pipeline       PipelineSpec             `json:"pipeline" yaml:"pipeline" toml:"pipeline"`
+}
+
+// 通道参数的配置。
+type ChannelSpec struct {
+	ReqChanLen   uint `json:"reqChanLen" yaml:"reqChanLen" toml:"reqChanLen"`
+	RespChanLen  uint `json:"respChanLen" yaml:"respChanLen" toml:"respChanLen"`
+	ItemChanLen  uint `json:"itemChanLen" yaml:"itemChanLen" toml:"itemChanLen"`
+	ErrorChanLen uint `json:"errorChanLen" yaml:"errorChanLen" toml:"errorChanLen"`
+}
+
+// 池基本参数的配置。
+type PoolSpec struct {
+	PageDownloaderPoolSize uint32 `json:"pageDownloaderPoolSize" yaml:"pageDownloaderPoolSize" toml:"pageDownloaderPoolSize"`
+	AnalyzerPoolSize       uint32 `json:"analyzerPoolSize" yaml:"analyzerPoolSize" toml:"analyzerPoolSize"`
+}
+
+// 爬取范围的配置。其中的模式均为正则表达式。
+type ScopeSpec struct {
+	Domains []string `json:"domains" yaml:"domains" toml:"domains"`
+	Schemes []string `json:"schemes" yaml:"schemes" toml:"schemes"`
+	Allow   []string `json:"allow" yaml:"allow" toml:"allow"`
+	Deny    []string `json:"deny" yaml:"deny" toml:"deny"`
+}
+
+// HTTP客户端的配置。超时时间采用time.ParseDuration能够识别的格式，例如"30s"。
+type ClientSpec struct {
+	Timeout string            `json:"timeout" yaml:"timeout" toml:"timeout"`
+	Proxy   string            `json:"proxy" yaml:"proxy" toml:"proxy"`
+	Headers map[string]string `json:"headers" yaml:"headers" toml:"headers"`
+}
+
+// 页面指令的配置。它决定分析器是否遵守页面中的noindex、nofollow和rel=canonical。
+type DirectivesSpec struct {
+	Noindex   bool `json:"noindex,omitempty" yaml:"noindex" toml:"noindex"`
+	Nofollow  bool `json:"nofollow,omitempty" yaml:"nofollow" toml:"nofollow"`
+	Canonical bool `json:"canonical,omitempty" yaml:"canonical" toml:"canonical"`
+}
+
+// 条目处理管道的配置。Workers是处理条目的工作协程的数量，为0时与分析器池的大小相同。
+// BatchSize大于0时为批量条目处理器启用批处理，BatchWait是批次的最长等待时间，例如"100ms"。
+type PipelineSpec struct {
+	Workers   uint32 `json:"workers,omitempty" yaml:"workers" toml:"workers"`
+	BatchSize int    `json:"batchSize,omitempty" yaml:"batchSize" toml:"batchSize"`
+	BatchWait string `json:"batchWait,omitempty" yaml:"batchWait" toml:"batchWait"`
+}
+
+// 组件的配置。组件通过名称在注册表中查找，参数会被传给组件的工厂函数。
+// Provenance只对响应解析函数有效，它表示是否为解析出的条目附加来源信息。
+type ComponentSpec struct {
+	Name       string                 `json:"name" yaml:"name" toml:"name"`
+	Params     map[string]interface{} `json:"params" yaml:"params" toml:"params"`
+	Provenance bool                   `json:"provenance,omitempty" yaml:"provenance" toml:"provenance"`
+}
+
+// 获得组件的参数。YAML解码出的嵌套字典会被转换成以字符串为键的字典。
+func (cs *ComponentSpec) params() base.Params {
+	params := make(base.Params, len(cs.Params))
+	for k, v := range cs.Params {
+		params[k] = normalizeValue(v)
+	}
+	return params
+}
+
+// 把解码出的值中的字典统一转换成以字符串为键的字典。
+func normalizeValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[fmt.Sprint(k)] = normalizeValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[k] = normalizeValue(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, e := range value {
+			list[i] = normalizeValue(e)
+		}
+		return list
+	case []map[string]interface{}:
+		list := make([]interface{}, len(value))
+		for i, e := range value {
+			list[i] = normalizeValue(e)
+		}
+		return list
+	}
+	return v
+}
+
+// 根据组件的配置生成响应解析函数的序列。
+func buildParsers(specs []ComponentSpec) ([]anlz.ParseResponse, error) {
+	argsErr := base.NewArgsError()
+	parsers := make([]anlz.ParseResponse, 0, len(specs))
+	for i, cs := range specs {
+		parser, err := anlz.NewParser(cs.Name, cs.params())
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("[%d]", i), err)
+			continue
+		}
+		if cs.Provenance {
+			parser = anlz.WithProvenance(parser)
+		}
+		parsers = append(parsers, parser)
+	}
+	return parsers, argsErr.ErrorOrNil()
+}
+
+// 根据组件的配置生成具名的响应解析函数实例。
+func buildNamedParsers(specs map[string]ComponentSpec) (anlz.NamedParsers, error) {
+	argsErr := base.NewArgsError()
+	parsers := make(anlz.NamedParsers, len(specs))
+	//按名称的顺序生成，错误的顺序因此是确定的
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cs := specs[name]
+		if strings.TrimSpace(name) == "" {
+			argsErr.Add("", errors.New("The response parser name can not be empty!"))
+			continue
+		}
+		parser, err := anlz.NewParser(cs.Name, cs.params())
+		if err != nil {
+			argsErr.Add(name, err)
+			continue
+		}
+		if cs.Provenance {
+			parser = anlz.WithProvenance(parser)
+		}
+		parsers[name] = parser
+	}
+	return parsers, argsErr.ErrorOrNil()
+}
+
+// 根据组件的配置生成条目处理器的序列。
+func buildProcessors(specs []ComponentSpec) ([]ipl.ItemProcessor, error) {
+	argsErr := base.NewArgsError()
+	processors := make([]ipl.ItemProcessor, 0, len(specs))
+	for i, cs := range specs {
+		processor, err := ipl.NewProcessor(cs.Name, cs.params())
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("[%d]", i), err)
+			continue
+		}
+		processors = append(processors, ipl.Named(cs.Name, processor))
+	}
+	return processors, argsErr.ErrorOrNil()
+}
+
+// 获得通道参数的容器。
+func (spec *Spec) channelArgs() base.ChannelArgs {
+	return base.NewChannelArgs(
+		spec.Channels.ReqChanLen,
+		spec.Channels.RespChanLen,
+		spec.Channels.ItemChanLen,
+		spec.Channels.ErrorChanLen)
+}
+
+// 获得池基本参数的容器。
+func (spec *Spec) poolBaseArgs() base.PoolBaseArgs {
+	return base.NewPoolBaseArgs(
+		spec.Pools.PageDownloaderPoolSize,
+		spec.Pools.AnalyzerPoolSize)
+}
+
+// 检查配置的有效性。错误信息中带有与配置文件对应的字段路径，例如"scope.allow[1]"。
+// 检查即尝试生成调度器的配置，因此组件的工厂函数也会被调用。需要调度器的配置时应直接调用Build。
+func (spec *Spec) Check() error {
+	_, err := spec.Build()
+	return err
+}
+
+func (spec *Spec) String() string {
+	return fmt.Sprintf("{ channels: %+v, pools: %+v, depth: %d, seeds: %v,"+
+		" scope: %+v, client: %+v, parsers: %v, namedParsers: %v, processors: %v, sitemaps: %v, directives: %+v,"+
+		" nearDuplicates: %s, pipeline: %+v }",
+		spec.Channels, spec.Pools, spec.Depth, spec.Seeds,
+		spec.Scope, spec.Client, spec.Parsers, spec.NamedParsers, spec.Processors, spec.Sitemaps, spec.Directives,
+		func() string {
+			if spec.NearDuplicates == nil {
+				return "off"
+			}
+			return fmt.Sprint(*spec.NearDuplicates)
+		}(), spec.Pipeline)
+}
+
+// 根据配置生成调度器的配置。配置无效时返回的错误与Check的相同，
+// 所有字段(包括生成组件时)的错误都被汇集在同一个*base.ArgsError中。每个组件只会被生成一次。
+func (spec *Spec) Build() (*sched.Config, error) {
+	argsErr := base.NewArgsError()
+	channelArgs := spec.channelArgs()
+	argsErr.Add("channels", channelArgs.Check())
+	poolBaseArgs := spec.poolBaseArgs()
+	argsErr.Add("pools", poolBaseArgs.Check())
+	if len(spec.Seeds) == 0 {
+		argsErr.Add("seeds", errors.New("The seed list is empty!"))
+	}
+	seeds := make([]*http.Request, 0, len(spec.Seeds))
+	for i, seed := range spec.Seeds {
+		httpReq, err := parseSeed(seed)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("seeds[%d]", i), err)
+			continue
+		}
+		seeds = append(seeds, httpReq)
+	}
+	scope, err := spec.scope()
+	argsErr.Add("scope", err)
+	var genHttpClient sched.GenHttpClient
+	if err := spec.Client.Check(); err != nil {
+		argsErr.Add("client", err)
+	} else if genHttpClient, err = spec.Client.httpClientGenerator(); err != nil {
+		argsErr.Add("client", err)
+	}
+	if len(spec.Parsers) == 0 {
+		argsErr.Add("parsers", errors.New("The parser list is empty!"))
+	}
+	parsers, err := buildParsers(spec.Parsers)
+	argsErr.Add("parsers", err)
+	namedParsers, err := buildNamedParsers(spec.NamedParsers)
+	argsErr.Add("namedParsers", err)
+	processors, err := buildProcessors(spec.Processors)
+	argsErr.Add("processors", err)
+	argsErr.Add("pipeline", spec.Pipeline.Check())
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	config := sched.NewConfig(
+		sched.WithChannelArgs(channelArgs),
+		sched.WithPoolBaseArgs(poolBaseArgs),
+		sched.WithCrawlDepth(spec.Depth),
+		sched.WithHttpClientGenerator(genHttpClient),
+		sched.WithScope(scope),
+		sched.WithSeeds(seeds...),
+		sched.WithRespParsers(parsers...),
+		sched.WithNamedParsers(namedParsers),
+		sched.WithItemProcessors(processors...),
+		sched.WithSitemapDiscovery(spec.Sitemaps),
+		sched.WithDirectives(anlz.Directives{
+			Noindex:   spec.Directives.Noindex,
+			Nofollow:  spec.Directives.Nofollow,
+			Canonical: spec.Directives.Canonical,
+		}))
+	if spec.NearDuplicates != nil {
+		config.Apply(sched.WithNearDuplicates(*spec.NearDuplicates))
+	}
+	if spec.Pipeline.Workers > 0 {
+		config.Apply(sched.WithItemWorkers(spec.Pipeline.Workers))
+	}
+	if spec.Pipeline.BatchSize > 0 {
+		wait, _ := spec.Pipeline.batchWait()
+		config.Apply(sched.WithItemBatching(spec.Pipeline.BatchSize, wait))
+	}
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 生成爬取范围的规则。
+func (spec *Spec) scope() (*sched.Scope, error) {
+	argsErr := base.NewArgsError()
+	scope := &sched.Scope{
+		Domains: spec.Scope.Domains,
+		Schemes: spec.Scope.Schemes,
+	}
+	for i, pattern := range spec.Scope.Allow {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("allow[%d]", i), err)
+			continue
+		}
+		scope.Allow = append(scope.Allow, re)
+	}
+	for i, pattern := range spec.Scope.Deny {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			argsErr.Add(fmt.Sprintf("deny[%d]", i), err)
+			continue
+		}
+		scope.Deny = append(scope.Deny, re)
+	}
+	argsErr.Add("", scope.Check())
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// 获得批次的最长等待时间。
+func (ps *PipelineSpec) batchWait() (time.Duration, error) {
+	if ps.BatchWait == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(ps.BatchWait)
+}
+
+// 检查条目处理管道的配置的有效性。
+func (ps *PipelineSpec) Check() error {
+	argsErr := base.NewArgsError()
+	wait, err := ps.batchWait()
+	if err != nil {
+		argsErr.Add("batchWait", err)
+	} else if ps.BatchSize != 0 || wait != 0 {
+		argsErr.Add("", ipl.CheckBatching(ps.BatchSize, wait))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 检查HTTP客户端的配置的有效性。
+func (cs *ClientSpec) Check() error {
+	argsErr := base.NewArgsError()
+	if cs.Timeout != "" {
+		if timeout, err := time.ParseDuration(cs.Timeout); err != nil {
+			argsErr.Add("timeout", err)
+		} else if timeout < 0 {
+			argsErr.Add("timeout", errors.New("The timeout can not be negative!"))
+		}
+	}
+	if cs.Proxy != "" {
+		if proxyUrl, err := url.Parse(cs.Proxy); err != nil {
+			argsErr.Add("proxy", err)
+		} else if !proxyUrl.IsAbs() {
+			argsErr.Add("proxy", errors.New(
+				fmt.Sprintf("The proxy url '%s' is not absolute!", cs.Proxy)))
+		}
+	}
+	for name := range cs.Headers {
+		if strings.TrimSpace(name) == "" {
+			argsErr.Add("headers", errors.New("The header name can not be empty!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+func (cs *ClientSpec) String() string {
+	return fmt.Sprintf("{ timeout: %s, proxy: %s, headers: %v }",
+		cs.Timeout, cs.Proxy, cs.Headers)
+}
+
+// 解析种子URL。
+func parseSeed(seed string) (*http.Request, error) {
+	seed = strings.TrimSpace(seed)
+	if seed == "" {
+		return nil, errors.New("The seed url is empty!")
+	}
+	seedUrl, err := url.Parse(seed)
+	if err != nil {
+		return nil, err
+	}
+	if !seedUrl.IsAbs() || seedUrl.Host == "" {
+		return nil, errors.New(fmt.Sprintf("The seed url '%s' is not absolute!", seed))
+	}
+	return http.NewRequest("GET", seedUrl.String(), nil)
+}
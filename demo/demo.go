@@ -153,28 +153,26 @@ func main() {
 		record)
 
 	//准备启动参数
-	channelArgs := base.NewChannelArgs(10, 10, 10, 10)
-	poolBaseArgs := base.NewPoolBaseArgs(3, 3)
-	crawlDepth := uint32(1)
-	httpClientGenerator := genHttpClient
-	respParsers := getResponseParsers()
-	itemProcessors := getItemProcessors()
 	startUrl := "http://www.sogou.com"
 	firstHttpReq, err := http.NewRequest("GET", startUrl, nil)
 	if err != nil {
 		logger.Errorln(err)
 		return
 	}
+	config := sched.NewConfig(
+		sched.WithChannelArgs(base.NewChannelArgs(10, 10, 10, 10)),
+		sched.WithPoolBaseArgs(base.NewPoolBaseArgs(3, 3)),
+		sched.WithCrawlDepth(1),
+		sched.WithHttpClientGenerator(genHttpClient),
+		sched.WithRespParsers(getResponseParsers()...),
+		sched.WithItemProcessors(pipeline.Processors(getItemProcessors()...)...),
+		sched.WithSeeds(firstHttpReq))
 
 	//开启调度器
-	scheduler.Start(
-		channelArgs,
-		poolBaseArgs,
-		crawlDepth,
-		httpClientGenerator,
-		respParsers,
-		itemProcessors,
-		firstHttpReq)
+	if err := scheduler.StartWithConfig(config); err != nil {
+		logger.Errorln(err)
+		return
+	}
 
 	//等待监控结束
 	<-checkCountChan
@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 存档中的一条记录。存档文件中每行是一条JSON格式的记录。
+type ArchiveRecord struct {
+	Time       time.Time   `json:"time"`       // 下载的时间。
+	Method     string      `json:"method"`     // HTTP方法。
+	Url        string      `json:"url"`        // 请求的URL。
+	StatusCode int         `json:"statusCode"` // 响应的状态码。
+	Header     http.Header `json:"header"`     // 响应头。
+	Body       []byte      `json:"body"`       // 响应体。
+}
+
+// 存档记录器。它会把经过的每个HTTP响应写入存档文件。
+type ArchiveRecorder struct {
+	file   *os.File      // 存档文件。
+	writer *bufio.Writer // 带缓冲的写入器。
+	mutex  sync.Mutex    // 针对写入操作的互斥锁。
+	count  uint64        // 已写入的记录的数量。
+}
+
+// 创建存档记录器。存档文件会被以追加的方式打开。
+func NewArchiveRecorder(path string) (*ArchiveRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveRecorder{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// 包装HTTP传输。经过被包装的HTTP传输的响应都会被写入存档。
+// 参数base为nil时使用http.DefaultTransport。
+func (ar *ArchiveRecorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &recordingTransport{base: base, recorder: ar}
+}
+
+// 写入一条记录。
+func (ar *ArchiveRecorder) record(record *ArchiveRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	if ar.file == nil {
+		return errors.New("The archive recorder is closed!")
+	}
+	if _, err := ar.writer.Write(data); err != nil {
+		return err
+	}
+	if err := ar.writer.WriteByte('\n'); err != nil {
+		return err
+	}
+	ar.count++
+	return nil
+}
+
+// 获得已写入的记录的数量。
+func (ar *ArchiveRecorder) Count() uint64 {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	return ar.count
+}
+
+// 刷新缓冲并关闭存档文件。
+func (ar *ArchiveRecorder) Close() error {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	if ar.file == nil {
+		return nil
+	}
+	err := ar.writer.Flush()
+	if closeErr := ar.file.Close(); err == nil {
+		err = closeErr
+	}
+	ar.file = nil
+	return err
+}
+
+// 记录响应的HTTP传输。
+type recordingTransport struct {
+	base     http.RoundTripper // 实际执行请求的HTTP传输。
+	recorder *ArchiveRecorder  // 存档记录器。
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	record := &ArchiveRecord{
+		Time:       time.Now(),
+		Method:     req.Method,
+		Url:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if err := rt.recorder.record(record); err != nil {
+		logger.Errorf("Can not write the archive record! (url=%s): %s\n", req.URL, err)
+	}
+	return resp, nil
+}
+
+// 读取存档文件中的全部记录。
+func ReadArchive(path string) ([]*ArchiveRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := make([]*ArchiveRecord, 0)
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			record := &ArchiveRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return nil, errors.New(
+					fmt.Sprintf("Invalid archive record at %s:%d: %s", path, line, err))
+			}
+			records = append(records, record)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// 创建重放存档的HTTP传输。它不会访问网络，而是从存档中查找与请求对应的响应。
+// 同一URL有多条记录时以最后一条为准。存档中没有的URL会得到404响应。
+func NewReplayTransport(records []*ArchiveRecord) http.RoundTripper {
+	recordMap := make(map[string]*ArchiveRecord, len(records))
+	for _, record := range records {
+		recordMap[replayKey(record.Method, record.Url)] = record
+	}
+	return &replayTransport{records: recordMap}
+}
+
+// 重放存档的HTTP传输。
+type replayTransport struct {
+	records map[string]*ArchiveRecord // 以HTTP方法和URL为键的记录的字典。
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	record, ok := rt.records[replayKey(req.Method, req.URL.String())]
+	if !ok {
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", record.StatusCode, http.StatusText(record.StatusCode)),
+		StatusCode:    record.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        record.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(record.Body)),
+		ContentLength: int64(len(record.Body)),
+		Request:       req,
+	}, nil
+}
+
+// 生成重放记录的键。
+func replayKey(method string, url string) string {
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + url
+}
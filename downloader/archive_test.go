@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+// 记录下载的响应，然后在不访问网络的情况下重放它们。
+func TestArchiveRecordAndReplay(t *testing.T) {
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(r.URL.Path + " " + version))
+	}))
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	recorder, err := NewArchiveRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloader := NewPageDownloader(&http.Client{Transport: recorder.Wrap(nil)})
+	download := func(dl PageDownloader, u string) (int, string, string) {
+		httpReq, _ := http.NewRequest("GET", u, nil)
+		resp, err := dl.Download(*base.NewRequest(httpReq, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp := resp.HttpResp()
+		defer httpResp.Body.Close()
+		body, _ := io.ReadAll(httpResp.Body)
+		return httpResp.StatusCode, httpResp.Header.Get("Content-Type"), string(body)
+	}
+	download(downloader, server.URL+"/a")
+	download(downloader, server.URL+"/missing")
+	version = "v2"
+	//被记录的响应体依然能被解析函数读取
+	if _, _, body := download(downloader, server.URL+"/a"); body != "/a v2" {
+		t.Errorf("Unexpected body %q!", body)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Count() != 3 {
+		t.Errorf("Expected 3 records, but got %d!", recorder.Count())
+	}
+	server.Close()
+
+	records, err := ReadArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Method != "GET" || records[0].Url != server.URL+"/a" {
+		t.Fatalf("Unexpected records %v!", records)
+	}
+	replayer := NewPageDownloader(&http.Client{Transport: NewReplayTransport(records)})
+	testCases := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/a", 200, "text/html", "/a v2"}, //同一URL有多条记录时以最后一条为准
+		{"/missing", 404, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"/b", 404, "", ""}, //存档中没有的URL
+	}
+	for _, tc := range testCases {
+		status, contentType, body := download(replayer, server.URL+tc.path)
+		if status != tc.status || contentType != tc.contentType || body != tc.body {
+			t.Errorf("Unexpected replay of %s: %d, %q, %q!", tc.path, status, contentType, body)
+		}
+	}
+}
@@ -60,9 +60,16 @@ func (dl *myPageDownloader) Id() uint32 {
 func (dl *myPageDownloader) Download(req base.Request) (*base.Response, error) {
 	httpReq := req.HttpReq()
 	logger.Infof("Do the request (url=%s)... \n", httpReq.URL)
+	//元数据总是非nil的，分析器在其中记录的内容(如规范URL)因此能被调度器和解析函数看到
+	meta := req.Meta().Clone()
+	if meta == nil {
+		meta = make(base.Metadata)
+	}
+	//使解析函数能够通过HTTP响应中的请求获得元数据
+	httpReq = httpReq.WithContext(base.ContextWithMeta(httpReq.Context(), meta))
 	httpResp, err := dl.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
-	return base.NewResponse(httpResp, req.Depth()), nil
+	return base.NewResponseWithMeta(httpResp, req.Depth(), meta), nil
 }
@@ -0,0 +1,158 @@
+package itempipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 批量条目处理器的接口类型。条目处理管道启用了批处理时，会把多个条目合在一起交给它，
+// 例如一次写入多行的数据库输出端。未启用批处理时，它像普通的条目处理器一样被逐个调用。
+type BatchProcessor interface {
+	ItemProcessor
+	// 批量处理条目。结果和错误的序列应与items一一对应，结果为nil表示沿用原来的条目。
+	// 两个序列本身都可以为nil，分别表示沿用所有条目和没有错误。
+	ProcessBatch(items []base.Item) (results []base.Item, errs []error)
+}
+
+// 条目处理管道的选项的函数类型。
+type PipelineOption func(ip *myItemPipeline)
+
+// 启用批处理。实现了BatchProcessor的条目处理器每次最多接收size个条目，
+// 不足size个时最多等待wait。发送条目的一方会等到其所在的批次被处理完毕，
+// 所以只有在并发发送的数量不少于size时才能凑满一个批次。
+func WithBatching(size int, wait time.Duration) PipelineOption {
+	return func(ip *myItemPipeline) {
+		ip.batchSize = size
+		ip.batchWait = wait
+	}
+}
+
+// 检查批处理的参数的有效性。
+func CheckBatching(size int, wait time.Duration) error {
+	argsErr := base.NewArgsError()
+	if size < 1 {
+		argsErr.Add("batchSize", errors.New(fmt.Sprintf("Invalid batch size %d! It should be at least 1.", size)))
+	}
+	if wait <= 0 {
+		argsErr.Add("batchWait", errors.New(fmt.Sprintf("Invalid batch wait %s! It should be positive.", wait)))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 创建批处理器。它把并发的Process调用合并为对BatchProcessor的批量调用。
+func newBatcher(processor BatchProcessor, size int, wait time.Duration) *batcher {
+	return &batcher{processor: processor, size: size, wait: wait}
+}
+
+// 批处理器。
+type batcher struct {
+	processor BatchProcessor // 被包装的批量条目处理器。
+	size      int            // 批次的最大条目数。
+	wait      time.Duration  // 批次的最长等待时间。
+	pending   []*batchEntry  // 当前批次中的条目。
+	timer     *time.Timer    // 当前批次的计时器。
+	batchNo   uint64         // 当前批次的序号。过期的计时器据此被忽略。
+	mutex     sync.Mutex     // 互斥锁。
+}
+
+// 批次中的条目及其处理结果。
+type batchEntry struct {
+	item   base.Item     // 条目。
+	result base.Item     // 处理结果。
+	err    error         // 处理错误。
+	done   chan struct{} // 处理完毕的信号。
+}
+
+func (b *batcher) Process(item base.Item) (base.Item, error) {
+	entry := &batchEntry{item: item, done: make(chan struct{})}
+	b.mutex.Lock()
+	b.pending = append(b.pending, entry)
+	if len(b.pending) >= b.size {
+		batch := b.take()
+		b.mutex.Unlock()
+		b.run(batch)
+	} else {
+		if len(b.pending) == 1 {
+			batchNo := b.batchNo
+			b.timer = time.AfterFunc(b.wait, func() {
+				b.expire(batchNo)
+			})
+		}
+		b.mutex.Unlock()
+	}
+	<-entry.done
+	return entry.result, entry.err
+}
+
+// 关闭被包装的条目处理器。
+func (b *batcher) Close() error {
+	if closer, ok := b.processor.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// 取出当前批次。调用方需要持有互斥锁。
+func (b *batcher) take() []*batchEntry {
+	batch := b.pending
+	b.pending = nil
+	b.batchNo++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// 在等待超时后处理给定序号的批次。该批次已被取出时什么也不做。
+func (b *batcher) expire(batchNo uint64) {
+	b.mutex.Lock()
+	if b.batchNo != batchNo || len(b.pending) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mutex.Unlock()
+	b.run(batch)
+}
+
+// 处理批次并通知其中的每个条目。
+func (b *batcher) run(batch []*batchEntry) {
+	defer func() {
+		if p := recover(); p != nil {
+			err := errors.New(fmt.Sprintf("Fatal batch processing error: %v", p))
+			for _, entry := range batch {
+				entry.result, entry.err = nil, err
+			}
+		}
+		for _, entry := range batch {
+			close(entry.done)
+		}
+	}()
+	items := make([]base.Item, len(batch))
+	for i, entry := range batch {
+		items[i] = entry.item
+	}
+	results, errs := b.processor.ProcessBatch(items)
+	if (results != nil && len(results) != len(batch)) || (errs != nil && len(errs) != len(batch)) {
+		err := errors.New(fmt.Sprintf("The batch processor returns %d results and %d errors for %d items!",
+			len(results), len(errs), len(batch)))
+		for _, entry := range batch {
+			entry.err = err
+		}
+		return
+	}
+	for i, entry := range batch {
+		if results != nil {
+			entry.result = results[i]
+		}
+		if errs != nil {
+			entry.err = errs[i]
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package itempipeline
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 记录批次大小的批量条目处理器。编号为奇数的条目处理失败。
+type testBatchProcessor struct {
+	sizes []int
+	mutex sync.Mutex
+}
+
+func (p *testBatchProcessor) Process(item base.Item) (base.Item, error) {
+	results, errs := p.ProcessBatch([]base.Item{item})
+	return results[0], errs[0]
+}
+
+func (p *testBatchProcessor) ProcessBatch(items []base.Item) ([]base.Item, []error) {
+	p.mutex.Lock()
+	p.sizes = append(p.sizes, len(items))
+	p.mutex.Unlock()
+	results := make([]base.Item, len(items))
+	errs := make([]error, len(items))
+	for i, item := range items {
+		if item["n"].(int)%2 == 1 {
+			errs[i] = errors.New("odd")
+			continue
+		}
+		results[i] = base.Item{"n": item["n"], "batched": true}
+	}
+	return results, errs
+}
+
+func TestPipelineServeWithBatching(t *testing.T) {
+	processor := &testBatchProcessor{}
+	seen := make(chan base.Item, 10)
+	pipeline := NewItemPipeline([]ItemProcessor{processor, ProcessItem(func(item base.Item) (base.Item, error) {
+		seen <- item
+		return item, nil
+	})}, WithBatching(4, 20*time.Millisecond))
+
+	items := make(chan base.Item)
+	var failed []base.Item
+	var mutex sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		pipeline.Serve(items, 4, func(item base.Item, errs []error) {
+			mutex.Lock()
+			failed = append(failed, item)
+			mutex.Unlock()
+		})
+		close(done)
+	}()
+	for i := 0; i < 10; i++ {
+		items <- base.Item{"n": i}
+	}
+	close(items)
+	<-done
+	close(seen)
+
+	total := 0
+	for _, size := range processor.sizes {
+		if size > 4 {
+			t.Errorf("Unexpected batch size %d!", size)
+		}
+		total += size
+	}
+	if total != 10 || len(processor.sizes) < 3 {
+		t.Errorf("Unexpected batches %v!", processor.sizes)
+	}
+	if len(failed) != 5 {
+		t.Errorf("Expected 5 failed items, but got %d!", len(failed))
+	}
+	//未启用快速失败，所以失败的条目也会到达后续的处理器，但只有成功的条目被替换
+	batched := 0
+	for item := range seen {
+		if item["batched"] == true {
+			batched++
+		}
+	}
+	if batched != 5 {
+		t.Errorf("Expected 5 batched items, but got %d!", batched)
+	}
+	if counts := pipeline.Count(); counts[2] != 10 {
+		t.Errorf("Unexpected counts %v!", counts)
+	}
+}
+
+func TestPipelineServeRecoversPanics(t *testing.T) {
+	pipeline := NewItemPipeline([]ItemProcessor{ProcessItem(func(item base.Item) (base.Item, error) {
+		panic("boom")
+	})})
+	items := make(chan base.Item, 1)
+	items <- base.Item{}
+	close(items)
+	var errs []error
+	pipeline.Serve(items, 2, func(item base.Item, e []error) {
+		errs = e
+	})
+	if len(errs) != 1 {
+		t.Errorf("Expected the panic to be reported as an error, but got %v!", errs)
+	}
+}
@@ -0,0 +1,88 @@
+package itempipeline
+
+import (
+	"errors"
+	"fmt"
+	"sys/fetch/base"
+)
+
+// 注册内置的条目处理器。
+func init() {
+	Register("copy", CopyItem)
+	RegisterFactory("set-fields", newSetFields)
+	RegisterFactory("drop-fields", newDropFields)
+	RegisterFactory("require-fields", newRequireFields)
+}
+
+// 复制条目。后续的处理器可以放心地修改复制出的条目。
+func CopyItem(item base.Item) (base.Item, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	result := make(base.Item, len(item))
+	for k, v := range item {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// 创建为条目设置固定字段的条目处理器。参数"fields"为字段名到字段值的字典。
+func newSetFields(params base.Params) (ItemProcessor, error) {
+	fields, err := params.Params("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("The param 'fields' is required!")
+	}
+	return ProcessItem(func(item base.Item) (base.Item, error) {
+		for k, v := range fields {
+			item[k] = v
+		}
+		return item, nil
+	}), nil
+}
+
+// 创建删除条目中的某些字段的条目处理器。参数"fields"为字段名的列表。
+func newDropFields(params base.Params) (ItemProcessor, error) {
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("The param 'fields' is required!")
+	}
+	return ProcessItem(func(item base.Item) (base.Item, error) {
+		for _, field := range fields {
+			delete(item, field)
+		}
+		return item, nil
+	}), nil
+}
+
+// 创建检查条目是否包含某些字段的条目处理器。参数"fields"为字段名的列表。
+// 参数"drop"为true时，缺少字段的条目被丢弃而不是被报告为错误。
+func newRequireFields(params base.Params) (ItemProcessor, error) {
+	fields, err := params.Strings("fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("The param 'fields' is required!")
+	}
+	drop, err := params.Bool("drop", false)
+	if err != nil {
+		return nil, err
+	}
+	return ProcessItem(func(item base.Item) (base.Item, error) {
+		for _, field := range fields {
+			if _, ok := item[field]; !ok {
+				if drop {
+					return nil, ErrDropItem
+				}
+				return nil, errors.New(fmt.Sprintf("The item lacks the field '%s'!", field))
+			}
+		}
+		return item, nil
+	}), nil
+}
@@ -0,0 +1,532 @@
+package itempipeline
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"sys/fetch/base"
+)
+
+// 表格中出现新字段时的处理方式。
+const (
+	NEW_FIELDS_EXTEND   = "extend"   // 扩展表头。当前的文件会被重写，已轮转的文件保留原来的表头。
+	NEW_FIELDS_DROP     = "drop"     // 丢弃新字段。
+	NEW_FIELDS_OVERFLOW = "overflow" // 把新字段以JSON对象的形式写入溢出列。
+)
+
+// 推断列时默认使用的条目数。
+const defaultCsvInferItems = 100
+
+// 默认的溢出列的名称。
+const defaultCsvOverflowColumn = "_overflow"
+
+// 路径中代表条目类型的占位符。
+const csvTypePlaceholder = "{type}"
+
+// 文件名中不允许出现的字符。
+var csvUnsafeNamePattern = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func init() {
+	RegisterFactory("csv", func(params base.Params) (ItemProcessor, error) {
+		return newCsvSinkFromParams(params, ',')
+	})
+	RegisterFactory("tsv", func(params base.Params) (ItemProcessor, error) {
+		return newCsvSinkFromParams(params, '\t')
+	})
+}
+
+// CSV输出端的选项。
+type CsvOptions struct {
+	FileSinkOptions
+	Delimiter      rune     // 分隔符。为0时使用逗号，TSV使用'\t'。
+	Columns        []string // 显式给定的列。为空时根据最初的InferItems个条目推断。
+	InferItems     int      // 推断列时使用的条目数。为0时使用默认值。
+	NewFields      string   // 出现新字段时的处理方式。为空时使用NEW_FIELDS_EXTEND。
+	OverflowColumn string   // 溢出列的名称。为空时使用"_overflow"。
+}
+
+// 检查CSV输出端的选项的有效性。
+func (options *CsvOptions) Check() error {
+	argsErr := base.NewArgsError()
+	argsErr.Add("", options.FileSinkOptions.Check())
+	if options.Delimiter == '"' || options.Delimiter == '\r' || options.Delimiter == '\n' {
+		argsErr.Add("delimiter", errors.New(fmt.Sprintf("Invalid delimiter %q!", options.Delimiter)))
+	}
+	if options.InferItems < 0 {
+		argsErr.Add("inferItems", errors.New("The number of items for inference can not be negative!"))
+	}
+	switch options.NewFields {
+	case "", NEW_FIELDS_EXTEND, NEW_FIELDS_DROP, NEW_FIELDS_OVERFLOW:
+	default:
+		argsErr.Add("newFields", errors.New(fmt.Sprintf(
+			"Unknown policy '%s'! It should be one of %s, %s and %s.", options.NewFields,
+			NEW_FIELDS_EXTEND, NEW_FIELDS_DROP, NEW_FIELDS_OVERFLOW)))
+	}
+	seen := make(map[string]bool, len(options.Columns))
+	for i, column := range options.Columns {
+		if column == "" || seen[column] {
+			argsErr.Add(fmt.Sprintf("columns[%d]", i),
+				errors.New(fmt.Sprintf("Invalid or duplicate column '%s'!", column)))
+		}
+		seen[column] = true
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 创建把条目写入CSV(或TSV)文件的输出端。每种类型(base.ITEM_TYPE_KEY)的条目被写入单独的文件：
+// 路径中的"{type}"会被替换为类型，路径中没有它时类型被插在扩展名之前。
+// 嵌套的字典被展开为以点号连接的列，列表被写为JSON数组。
+// 没有显式给定列时，列根据每种类型最初的options.InferItems个条目推断，
+// 列的顺序为字段首次出现的顺序(同一条目中的字段按字典序)。
+// 推断完成之前条目被暂存在内存中，调用Flush或Close时会立即推断。
+// 不轮转的文件已存在时，条目被追加到其中，并沿用文件原有的表头，
+// 原有的表头中没有的列按options.NewFields处理。
+func NewCsvSink(options CsvOptions) (Sink, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	if options.Delimiter == 0 {
+		options.Delimiter = ','
+	}
+	if options.InferItems == 0 {
+		options.InferItems = defaultCsvInferItems
+	}
+	if options.NewFields == "" {
+		options.NewFields = NEW_FIELDS_EXTEND
+	}
+	if options.OverflowColumn == "" {
+		options.OverflowColumn = defaultCsvOverflowColumn
+	}
+	return &myCsvSink{options: options, tables: make(map[string]*csvTable)}, nil
+}
+
+// CSV输出端的实现类型。
+type myCsvSink struct {
+	options  CsvOptions           // 选项。
+	tables   map[string]*csvTable // 条目类型到表格的映射。
+	stopSync func()               // 停止定期同步。在处理第一个条目时被设置。
+	closed   bool                 // 是否已被关闭。
+	mutex    sync.Mutex           // 互斥锁。
+}
+
+// 一种类型的条目所对应的表格。
+type csvTable struct {
+	file    *rotatingFile       // 输出的文件。
+	columns []string            // 列。在推断完成之前为nil。
+	index   map[string]int      // 列名到列序号的映射。
+	pending []map[string]string // 等待推断列的行。
+	adopted bool                // 是否已沿用已有的文件的表头。
+}
+
+func (sink *myCsvSink) Process(item base.Item) (base.Item, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	row := make(map[string]string)
+	flattenValue("", map[string]interface{}(item), row)
+	itemType, _ := item[base.ITEM_TYPE_KEY].(string)
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil, errors.New("The CSV sink is closed!")
+	}
+	if sink.stopSync == nil {
+		sink.stopSync = startSyncLoop(sink.options.SyncInterval, sink.syncFiles)
+	}
+	table := sink.table(itemType)
+	if table.columns == nil {
+		table.pending = append(table.pending, row)
+		if len(table.pending) < sink.options.InferItems {
+			return item, nil
+		}
+		return item, sink.inferColumns(table)
+	}
+	return item, sink.writeRow(table, row)
+}
+
+func (sink *myCsvSink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.flushTables()
+}
+
+func (sink *myCsvSink) Close() error {
+	sink.mutex.Lock()
+	if sink.closed {
+		sink.mutex.Unlock()
+		return nil
+	}
+	sink.closed = true
+	sink.mutex.Unlock()
+	//定期同步的函数会获取互斥锁，所以要在锁外停止它
+	if sink.stopSync != nil {
+		sink.stopSync()
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	err := sink.flushTables()
+	for _, itemType := range sink.tableTypes() {
+		if closeErr := sink.tables[itemType].file.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// 推断所有暂存的表格的列，然后同步所有文件。
+func (sink *myCsvSink) flushTables() error {
+	argsErr := base.NewArgsError()
+	for _, itemType := range sink.tableTypes() {
+		table := sink.tables[itemType]
+		if table.columns == nil && len(table.pending) > 0 {
+			argsErr.Add(itemType, sink.inferColumns(table))
+		}
+		argsErr.Add(itemType, table.file.sync())
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 定期同步已打开的文件。暂存的行不会被写入。
+func (sink *myCsvSink) syncFiles() {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	for _, table := range sink.tables {
+		table.file.sync()
+	}
+}
+
+// 获得按字典序排列的已出现的条目类型。
+func (sink *myCsvSink) tableTypes() []string {
+	types := make([]string, 0, len(sink.tables))
+	for itemType := range sink.tables {
+		types = append(types, itemType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// 获得给定类型的表格，不存在时创建它。
+func (sink *myCsvSink) table(itemType string) *csvTable {
+	table, ok := sink.tables[itemType]
+	if !ok {
+		table = &csvTable{file: newRotatingFile(csvTablePath(sink.options.Path, itemType), sink.options.FileSinkOptions)}
+		if len(sink.options.Columns) > 0 {
+			sink.setColumns(table, sink.options.Columns)
+		}
+		sink.tables[itemType] = table
+	}
+	return table
+}
+
+// 根据暂存的行推断列，然后写入这些行。
+func (sink *myCsvSink) inferColumns(table *csvTable) error {
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, row := range table.pending {
+		for _, key := range sortedKeys(row) {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sink.setColumns(table, columns)
+	pending := table.pending
+	table.pending = nil
+	for _, row := range pending {
+		if err := sink.writeRow(table, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 设置表格的列和文件的表头。
+func (sink *myCsvSink) setColumns(table *csvTable, columns []string) {
+	table.columns = append(make([]string, 0, len(columns)+1), columns...)
+	if sink.options.NewFields == NEW_FIELDS_OVERFLOW && !contains(columns, sink.options.OverflowColumn) {
+		table.columns = append(table.columns, sink.options.OverflowColumn)
+	}
+	table.index = make(map[string]int, len(table.columns))
+	for i, column := range table.columns {
+		table.index[column] = i
+	}
+	table.file.header = sink.encodeRecord(table.columns)
+}
+
+// 按照表格的列写入一行。新字段按选项中的方式处理。
+func (sink *myCsvSink) writeRow(table *csvTable, row map[string]string) error {
+	if !table.adopted {
+		table.adopted = true
+		if err := sink.adoptHeader(table); err != nil {
+			return err
+		}
+	}
+	newFields := make([]string, 0)
+	for _, key := range sortedKeys(row) {
+		if _, ok := table.index[key]; !ok {
+			newFields = append(newFields, key)
+		}
+	}
+	overflow := ""
+	if len(newFields) > 0 {
+		switch sink.options.NewFields {
+		case NEW_FIELDS_EXTEND:
+			if err := sink.extendColumns(table, newFields); err != nil {
+				return err
+			}
+		case NEW_FIELDS_OVERFLOW:
+			extra := make(map[string]string, len(newFields))
+			for _, key := range newFields {
+				extra[key] = row[key]
+			}
+			data, _ := json.Marshal(extra)
+			overflow = string(data)
+		}
+	}
+	record := make([]string, len(table.columns))
+	for key, value := range row {
+		if i, ok := table.index[key]; ok {
+			record[i] = value
+		}
+	}
+	if overflow != "" {
+		record[table.index[sink.options.OverflowColumn]] = overflow
+	}
+	return table.file.write(sink.encodeRecord(record))
+}
+
+// 在表格的末尾加入新的列，并重写当前的文件，使表头包含新的列。
+// 已轮转的文件不会被重写，它们的表头与其中的行依然是一致的。
+func (sink *myCsvSink) extendColumns(table *csvTable, newFields []string) error {
+	sink.setColumns(table, append(table.columns, newFields...))
+	return table.file.rewrite(sink.headerRewriter(table))
+}
+
+// 在追加到已有的文件之前，以文件原有的表头作为表格的列。
+// 表格的列中原有的表头所没有的列会被当作新字段：扩展表头时文件会被重写，
+// 否则它们会被丢弃或写入溢出列(溢出列不在原有的表头中时同样会重写文件)。
+func (sink *myCsvSink) adoptHeader(table *csvTable) error {
+	if table.file.file != nil || sink.options.rotating() {
+		return nil
+	}
+	path := table.file.path
+	header, err := readCsvHeader(path, sink.options.Delimiter)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Can not read the header of '%s': %s", path, err))
+	}
+	if header == nil || strings.Join(header, "\x00") == strings.Join(table.columns, "\x00") {
+		return nil
+	}
+	columns := header
+	if sink.options.NewFields == NEW_FIELDS_EXTEND {
+		for _, column := range table.columns {
+			if !contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	sink.setColumns(table, columns)
+	if len(table.columns) == len(header) {
+		return nil
+	}
+	return rewriteFile(path, sink.headerRewriter(table))
+}
+
+// 获得把文件的表头替换为表格当前的表头的重写函数。原有的行会被补齐到表格的列数。
+func (sink *myCsvSink) headerRewriter(table *csvTable) func(src io.Reader, dst io.Writer) error {
+	header := table.file.header
+	columns := len(table.columns)
+	return func(src io.Reader, dst io.Writer) error {
+		reader := csv.NewReader(src)
+		reader.Comma = sink.options.Delimiter
+		reader.FieldsPerRecord = -1
+		if _, err := reader.Read(); err != nil && err != io.EOF {
+			return err
+		}
+		if _, err := dst.Write(header); err != nil {
+			return err
+		}
+		writer := csv.NewWriter(dst)
+		writer.Comma = sink.options.Delimiter
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if len(record) < columns {
+				record = append(record, make([]string, columns-len(record))...)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+}
+
+// 读取已有的CSV文件的表头。文件不存在或为空时返回nil。
+func readCsvHeader(path string, delimiter rune) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return header, err
+}
+
+// 把一行编码为CSV记录。
+func (sink *myCsvSink) encodeRecord(record []string) []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Comma = sink.options.Delimiter
+	writer.Write(record)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+// 获得给定类型的条目所对应的文件路径。
+func csvTablePath(path string, itemType string) string {
+	name := csvUnsafeNamePattern.ReplaceAllString(itemType, "_")
+	if strings.Contains(path, csvTypePlaceholder) {
+		if name == "" {
+			name = "item"
+		}
+		return strings.Replace(path, csvTypePlaceholder, name, -1)
+	}
+	if name == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
+
+// 把值展开为以点号连接的键和单元格的内容。
+func flattenValue(prefix string, value interface{}, row map[string]string) {
+	var fields map[string]interface{}
+	switch v := value.(type) {
+	case base.Item:
+		fields = v
+	case base.Metadata:
+		fields = v
+	case base.Params:
+		fields = v
+	case map[string]interface{}:
+		fields = v
+	default:
+		row[prefix] = csvCell(value)
+		return
+	}
+	if len(fields) == 0 && prefix != "" {
+		row[prefix] = ""
+		return
+	}
+	for k, v := range fields {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flattenValue(key, v, row)
+	}
+}
+
+// 获得值在单元格中的表示。字符串原样写入，时间和URL见jsonValue，其他的值被写为JSON。
+func csvCell(value interface{}) string {
+	value = jsonValue(value)
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case fmt.Stringer:
+		if _, ok := v.(json.Marshaler); !ok {
+			return v.String()
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// 获得按字典序排列的键。
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 判断字符串列表中是否包含给定的字符串。
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据参数创建CSV输出端。除了fileSinkOptionsFromParams中的参数之外，还可以给定
+// "delimiter"、"columns"、"inferItems"、"newFields"和"overflowColumn"。
+func newCsvSinkFromParams(params base.Params, delimiter rune) (ItemProcessor, error) {
+	fileOptions, err := fileSinkOptionsFromParams(params)
+	options := CsvOptions{FileSinkOptions: fileOptions, Delimiter: delimiter}
+	argsErr := base.NewArgsError()
+	argsErr.Add("", err)
+	if d, err := params.String("delimiter", ""); err != nil {
+		argsErr.Add("", err)
+	} else if d != "" {
+		if d == "tab" || d == `\t` {
+			d = "\t"
+		}
+		if len([]rune(d)) != 1 {
+			argsErr.Add("delimiter", errors.New(fmt.Sprintf("The delimiter '%s' should be a single character!", d)))
+		} else {
+			options.Delimiter = []rune(d)[0]
+		}
+	}
+	if options.Columns, err = params.Strings("columns"); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.InferItems, err = params.Int("inferItems", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.NewFields, err = params.String("newFields", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.OverflowColumn, err = params.String("overflowColumn", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewCsvSink(options)
+}
@@ -0,0 +1,134 @@
+package itempipeline
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 读取CSV文件中的所有记录。
+func readCsv(t *testing.T, path string, comma rune) [][]string {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comma = comma
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestCsvSinkInference(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewCsvSink(CsvOptions{
+		FileSinkOptions: FileSinkOptions{Path: filepath.Join(dir, "{type}.csv")},
+		InferItems:      2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	sink.Process(base.Item{"_type": "product", "name": "Tea", "price": 4.5,
+		"offer": map[string]interface{}{"currency": "EUR"}})
+	sink.Process(base.Item{"_type": "article", "title": "Brewing, explained", "published": published})
+	sink.Process(base.Item{"_type": "product", "name": "Cup", "tags": []interface{}{"a", "b"}})
+	//推断完成之后出现的新字段会扩展表头
+	sink.Process(base.Item{"_type": "product", "name": "Pot", "color": "red"})
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	products := readCsv(t, filepath.Join(dir, "product.csv"), ',')
+	expected := [][]string{
+		{"_type", "name", "offer.currency", "price", "tags", "color"},
+		{"product", "Tea", "EUR", "4.5", "", ""},
+		{"product", "Cup", "", "", `["a","b"]`, ""},
+		{"product", "Pot", "", "", "", "red"},
+	}
+	if !reflect.DeepEqual(products, expected) {
+		t.Errorf("Unexpected products %v!", products)
+	}
+	articles := readCsv(t, filepath.Join(dir, "article.csv"), ',')
+	expected = [][]string{
+		{"_type", "published", "title"},
+		{"article", "2024-05-01T08:30:00Z", "Brewing, explained"},
+	}
+	if !reflect.DeepEqual(articles, expected) {
+		t.Errorf("Unexpected articles %v!", articles)
+	}
+}
+
+func TestCsvSinkNewFields(t *testing.T) {
+	dir := t.TempDir()
+	items := []base.Item{{"a": 1, "b": "x"}, {"a": 2, "c": "y", "d": true}}
+	cases := []struct {
+		newFields string
+		expected  [][]string
+	}{
+		{NEW_FIELDS_DROP, [][]string{{"b", "a"}, {"x", "1"}, {"", "2"}}},
+		{NEW_FIELDS_OVERFLOW, [][]string{{"b", "a", "_overflow"}, {"x", "1", ""}, {"", "2", `{"c":"y","d":"true"}`}}},
+		{NEW_FIELDS_EXTEND, [][]string{{"b", "a", "c", "d"}, {"x", "1", "", ""}, {"", "2", "y", "true"}}},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.newFields+".tsv")
+		processor, err := NewProcessor("tsv", base.Params{
+			"path": path, "columns": []interface{}{"b", "a"}, "newFields": c.newFields})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink := processor.(Sink)
+		for _, item := range items {
+			if _, err := sink.Process(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sink.Close()
+		if records := readCsv(t, path, '\t'); !reflect.DeepEqual(records, c.expected) {
+			t.Errorf("Unexpected records %v for policy %s!", records, c.newFields)
+		}
+	}
+	if _, err := NewProcessor("csv", base.Params{"path": "x.csv", "newFields": "merge"}); err == nil {
+		t.Errorf("Expected an error for the unknown policy!")
+	}
+}
+
+func TestCsvSinkAppendToExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		newFields string
+		expected  [][]string
+	}{
+		{NEW_FIELDS_DROP, [][]string{{"b", "a"}, {"x", "1"}, {"y", "2"}}},
+		{NEW_FIELDS_OVERFLOW, [][]string{{"b", "a", "_overflow"}, {"x", "1", ""}, {"y", "2", `{"c":"z"}`}}},
+		{NEW_FIELDS_EXTEND, [][]string{{"b", "a", "c"}, {"x", "1", ""}, {"y", "2", "z"}}},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, c.newFields+".csv")
+		if err := os.WriteFile(path, []byte("b,a\nx,1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sink, err := NewCsvSink(CsvOptions{
+			FileSinkOptions: FileSinkOptions{Path: path}, NewFields: c.newFields})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sink.Process(base.Item{"a": 2, "b": "y", "c": "z"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if records := readCsv(t, path, ','); !reflect.DeepEqual(records, c.expected) {
+			t.Errorf("Unexpected records %v for policy %s!", records, c.newFields)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package itempipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"sys/fetch/base"
+)
+
+func init() {
+	RegisterFactory("jsonl", newJsonLinesSinkFromParams)
+}
+
+// 创建把条目以JSON Lines格式(每行一个JSON对象)写入文件的输出端。
+// 时间被写为RFC 3339格式的字符串，URL被写为字符串。
+// 文件在写入第一个条目时才被打开。写入是带缓冲的，缓冲区每隔options.SyncInterval被刷新并同步到磁盘。
+func NewJsonLinesSink(options FileSinkOptions) (Sink, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	return &myJsonLinesSink{
+		file:         newRotatingFile(options.Path, options),
+		syncInterval: options.SyncInterval,
+	}, nil
+}
+
+// JSON Lines输出端的实现类型。
+type myJsonLinesSink struct {
+	file         *rotatingFile // 输出的文件。
+	syncInterval time.Duration // 同步的间隔时间。
+	stopSync     func()        // 停止定期同步。在写入第一个条目时被设置。
+	closed       bool          // 是否已被关闭。
+	mutex        sync.Mutex    // 互斥锁。
+}
+
+func (sink *myJsonLinesSink) Process(item base.Item) (base.Item, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(jsonValue(map[string]interface{}(item))); err != nil {
+		return nil, errors.New(fmt.Sprintf("Can not encode the item to JSON: %s", err))
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil, errors.New("The JSON Lines sink is closed!")
+	}
+	if err := sink.file.write(buffer.Bytes()); err != nil {
+		return nil, err
+	}
+	if sink.stopSync == nil {
+		sink.stopSync = startSyncLoop(sink.syncInterval, func() {
+			sink.Flush()
+		})
+	}
+	return item, nil
+}
+
+func (sink *myJsonLinesSink) Flush() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.file.sync()
+}
+
+func (sink *myJsonLinesSink) Close() error {
+	sink.mutex.Lock()
+	if sink.closed {
+		sink.mutex.Unlock()
+		return nil
+	}
+	sink.closed = true
+	sink.mutex.Unlock()
+	//定期同步的函数会获取互斥锁，所以要在锁外停止它
+	if sink.stopSync != nil {
+		sink.stopSync()
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.file.close()
+}
+
+// 把条目转换为适合JSON编码的形式，规则与JSON Lines输出端相同。原条目不会被修改。
+func JsonItem(item base.Item) base.Item {
+	if item == nil {
+		return nil
+	}
+	return base.Item(jsonValue(map[string]interface{}(item)).(map[string]interface{}))
+}
+
+// 把值转换为适合JSON编码的形式。
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case url.URL:
+		return v.String()
+	case *url.URL:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case error:
+		return v.Error()
+	case base.Item:
+		return jsonValue(map[string]interface{}(v))
+	case base.Metadata:
+		return jsonValue(map[string]interface{}(v))
+	case base.Params:
+		return jsonValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			result[k] = jsonValue(e)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = jsonValue(e)
+		}
+		return result
+	case []base.Item:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = jsonValue(e)
+		}
+		return result
+	case []map[string]interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = jsonValue(e)
+		}
+		return result
+	}
+	return value
+}
+
+// 根据参数创建JSON Lines输出端。参数见fileSinkOptionsFromParams。
+func newJsonLinesSinkFromParams(params base.Params) (ItemProcessor, error) {
+	options, err := fileSinkOptionsFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return NewJsonLinesSink(options)
+}
@@ -0,0 +1,132 @@
+package itempipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 读取目录中的所有JSON Lines文件，返回按文件名排序的每个文件的行。
+func readJsonLines(t *testing.T, dir string) [][]map[string]interface{} {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	sort.Strings(paths)
+	result := make([][]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := make([]map[string]interface{}, 0)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("Invalid line %q: %s", scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		file.Close()
+		result = append(result, lines)
+	}
+	return result
+}
+
+func TestJsonLinesSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewJsonLinesSink(FileSinkOptions{Path: filepath.Join(dir, "items.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageUrl, _ := url.Parse("http://example.com/a?b=<c>")
+	published := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	item := base.Item{
+		"url":       pageUrl,
+		"published": published,
+		"nested":    base.Item{"at": &published, "tags": []interface{}{*pageUrl}},
+	}
+	if result, err := sink.Process(item); err != nil || result["url"] != pageUrl {
+		t.Fatalf("The item should be passed through: %v, %v", result, err)
+	}
+	if files := readJsonLines(t, dir); len(files) != 1 || len(files[0]) != 0 {
+		t.Errorf("The item should be buffered before flushing!")
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	lines := readJsonLines(t, dir)[0]
+	if len(lines) != 1 || lines[0]["url"] != "http://example.com/a?b=<c>" ||
+		lines[0]["published"] != "2024-05-01T08:30:00Z" {
+		t.Errorf("Unexpected lines %v!", lines)
+	}
+	nested := lines[0]["nested"].(map[string]interface{})
+	if nested["at"] != "2024-05-01T08:30:00Z" || nested["tags"].([]interface{})[0] != "http://example.com/a?b=<c>" {
+		t.Errorf("Unexpected nested value %v!", nested)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.Process(item); err == nil {
+		t.Errorf("Expected an error for a closed sink!")
+	}
+}
+
+func TestJsonLinesSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewJsonLinesSink(FileSinkOptions{Path: filepath.Join(dir, "items.jsonl"), MaxItems: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := NewItemPipeline([]ItemProcessor{sink})
+	for i := 0; i < 5; i++ {
+		if errs := pipeline.Send(base.Item{"n": i}); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+	}
+	if err := pipeline.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := readJsonLines(t, dir)
+	if len(files) != 3 || len(files[0]) != 2 || len(files[1]) != 2 || len(files[2]) != 1 {
+		t.Errorf("Unexpected rotated files %v!", files)
+	}
+	if errs := pipeline.Send(base.Item{"n": 5}); len(errs) == 0 {
+		t.Errorf("Expected an error for a closed pipeline!")
+	}
+
+	dir = t.TempDir()
+	sink, _ = NewJsonLinesSink(FileSinkOptions{Path: filepath.Join(dir, "items.jsonl"), MaxBytes: 20})
+	for i := 0; i < 3; i++ {
+		sink.Process(base.Item{"text": "0123456789"})
+	}
+	sink.Close()
+	if files := readJsonLines(t, dir); len(files) != 3 {
+		t.Errorf("Expected 3 files rotated by size, but got %d!", len(files))
+	}
+}
+
+func TestJsonLinesSinkFromParams(t *testing.T) {
+	if _, err := NewProcessor("jsonl", nil); err == nil {
+		t.Errorf("Expected an error for the missing path!")
+	}
+	if _, err := NewProcessor("jsonl", base.Params{"path": "x.jsonl", "maxAge": "soon"}); err == nil {
+		t.Errorf("Expected an error for the invalid max age!")
+	}
+	dir := t.TempDir()
+	processor, err := NewProcessor("jsonl", base.Params{"path": filepath.Join(dir, "items.jsonl"), "maxAge": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := processor.(Sink); !ok {
+		t.Errorf("The processor should be a sink!")
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*")); len(paths) != 0 {
+		t.Errorf("The file should not be created before writing: %v!", paths)
+	}
+}
@@ -0,0 +1,444 @@
+package itempipeline
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"sys/fetch/base"
+)
+
+// 没有类型的条目所在的桶的名称。
+const kvUntypedBucket = "item"
+
+// 打开存储文件时等待文件锁的时间。
+const kvOpenTimeout = 5 * time.Second
+
+func init() {
+	RegisterFactory("kv", newKVSinkFromParams)
+}
+
+// 键值存储输出端的选项。
+type KVSinkOptions struct {
+	Path string // 存储文件的路径。
+	// 条目的键的表达式。它是以"|"分隔的字段路径，使用第一个非空的字段的值，
+	// 嵌套的字段用点号连接，例如"canonical|url"或"_meta.url"。
+	Key string
+	// 判断条目是否变化时忽略的字段。base.ITEM_META_KEY总是被忽略。
+	IgnoreFields []string
+}
+
+// 检查键值存储输出端的选项的有效性。
+func (options *KVSinkOptions) Check() error {
+	argsErr := base.NewArgsError()
+	if options.Path == "" {
+		argsErr.Add("path", errors.New("The path can not be empty!"))
+	}
+	if strings.TrimSpace(options.Key) == "" {
+		argsErr.Add("key", errors.New("The key expression can not be empty!"))
+	}
+	for i, field := range kvKeyFields(options.Key) {
+		if field == "" {
+			argsErr.Add("key", errors.New(fmt.Sprintf("The field[%d] of key '%s' is empty!", i, options.Key)))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 被存储的条目。
+type StoredItem struct {
+	Type      string    `json:"type"`       // 条目的类型。
+	Key       string    `json:"key"`        // 条目的键。
+	Item      base.Item `json:"item"`       // 最后一次写入的条目。读出的数字为float64，时间和URL为字符串。
+	FirstSeen time.Time `json:"first_seen"` // 第一次写入的时间。
+	LastSeen  time.Time `json:"last_seen"`  // 最后一次写入的时间。
+	ChangedAt time.Time `json:"changed_at"` // 条目的内容最后一次变化的时间。
+	Digest    string    `json:"digest"`     // 用于判断条目是否变化的内容摘要。
+}
+
+// 创建把条目写入嵌入式键值存储(bbolt)的输出端。每种类型的条目被存放在单独的桶中，
+// 键相同的条目会被更新而不是重复存储，并记录首次出现、最后出现和最后变化的时间。
+// 每个条目在单独的事务中写入；启用了批处理时(见WithBatching)，每批条目在同一个事务中写入。
+// 存储文件在写入第一个条目时才被打开，打开期间其他进程不能读写它。
+// 爬取结束之后可以用OpenItemStore读取其中的条目。
+func NewKVSink(options KVSinkOptions) (Sink, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	ignored := map[string]bool{base.ITEM_META_KEY: true}
+	for _, field := range options.IgnoreFields {
+		ignored[field] = true
+	}
+	return &myKVSink{
+		path:      options.Path,
+		keyFields: kvKeyFields(options.Key),
+		ignored:   ignored,
+	}, nil
+}
+
+// 键值存储输出端的实现类型。
+type myKVSink struct {
+	path      string          // 存储文件的路径。
+	keyFields []string        // 键的候选字段。
+	ignored   map[string]bool // 判断变化时忽略的字段。
+	db        *bolt.DB        // 存储。在写入第一个条目时被打开。
+	closed    bool            // 是否已被关闭。
+	mutex     sync.RWMutex    // 保护db和closed的读写锁。
+}
+
+// 将要被写入存储的条目。
+type kvEntry struct {
+	itemType string                 // 条目的类型，即桶的名称。
+	key      string                 // 条目的键。
+	content  map[string]interface{} // 可被编码为JSON的条目的内容。
+	digest   string                 // 条目内容的摘要。
+}
+
+func (sink *myKVSink) Process(item base.Item) (base.Item, error) {
+	entry, err := sink.entry(item)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sink.open()
+	if err != nil {
+		return nil, err
+	}
+	defer sink.mutex.RUnlock()
+	err = db.Update(func(tx *bolt.Tx) error {
+		return sink.put(tx, entry, time.Now().UTC())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// 在同一个事务中写入一批条目。无效的或写入失败的条目不影响其他条目，
+// 只有事务本身失败时所有的条目才都会得到错误。
+func (sink *myKVSink) ProcessBatch(items []base.Item) ([]base.Item, []error) {
+	errs := make([]error, len(items))
+	entries := make([]*kvEntry, len(items))
+	valid := 0
+	for i, item := range items {
+		if entries[i], errs[i] = sink.entry(item); errs[i] == nil {
+			valid++
+		}
+	}
+	if valid == 0 {
+		return nil, errs
+	}
+	db, err := sink.open()
+	if err == nil {
+		defer sink.mutex.RUnlock()
+		now := time.Now().UTC()
+		err = db.Update(func(tx *bolt.Tx) error {
+			for i, entry := range entries {
+				if entry != nil {
+					errs[i] = sink.put(tx, entry, now)
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		for i, entry := range entries {
+			if entry != nil {
+				errs[i] = err
+			}
+		}
+	}
+	return nil, errs
+}
+
+// 获得条目的键、类型和内容摘要。
+func (sink *myKVSink) entry(item base.Item) (*kvEntry, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	key := ""
+	for _, field := range sink.keyFields {
+		if value, ok := itemField(item, field); ok {
+			if key = csvCell(value); key != "" {
+				break
+			}
+		}
+	}
+	if key == "" {
+		return nil, errors.New(fmt.Sprintf("The item lacks the key '%s'!", strings.Join(sink.keyFields, "|")))
+	}
+	itemType, _ := item[base.ITEM_TYPE_KEY].(string)
+	if itemType == "" {
+		itemType = kvUntypedBucket
+	}
+	content := jsonValue(map[string]interface{}(item)).(map[string]interface{})
+	digest, err := sink.digest(content)
+	if err != nil {
+		return nil, err
+	}
+	return &kvEntry{itemType: itemType, key: key, content: content, digest: digest}, nil
+}
+
+// 在事务中写入条目。键已存在时保留首次出现的时间，内容未变化时保留最后变化的时间。
+func (sink *myKVSink) put(tx *bolt.Tx, entry *kvEntry, now time.Time) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(entry.itemType))
+	if err != nil {
+		return err
+	}
+	stored := &StoredItem{Type: entry.itemType, Key: entry.key, FirstSeen: now, ChangedAt: now}
+	if data := bucket.Get([]byte(entry.key)); data != nil {
+		previous := &StoredItem{}
+		if err := json.Unmarshal(data, previous); err != nil {
+			return err
+		}
+		stored.FirstSeen = previous.FirstSeen
+		if previous.Digest == entry.digest {
+			stored.ChangedAt = previous.ChangedAt
+		}
+	}
+	stored.Item = base.Item(entry.content)
+	stored.LastSeen = now
+	stored.Digest = entry.digest
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(entry.key), data)
+}
+
+// 获得已打开的存储，必要时打开它。成功时持有读锁，调用方需要释放它。
+func (sink *myKVSink) open() (*bolt.DB, error) {
+	sink.mutex.RLock()
+	if sink.closed {
+		sink.mutex.RUnlock()
+		return nil, errors.New("The key-value sink is closed!")
+	}
+	if sink.db != nil {
+		return sink.db, nil
+	}
+	sink.mutex.RUnlock()
+	sink.mutex.Lock()
+	if !sink.closed && sink.db == nil {
+		if dir := filepath.Dir(sink.path); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				sink.mutex.Unlock()
+				return nil, err
+			}
+		}
+		db, err := bolt.Open(sink.path, 0644, &bolt.Options{Timeout: kvOpenTimeout})
+		if err != nil {
+			sink.mutex.Unlock()
+			return nil, err
+		}
+		sink.db = db
+	}
+	sink.mutex.Unlock()
+	return sink.open()
+}
+
+// 计算条目内容的摘要。被忽略的字段不参与计算。
+func (sink *myKVSink) digest(content map[string]interface{}) (string, error) {
+	fields := make(map[string]interface{}, len(content))
+	for k, v := range content {
+		if !sink.ignored[k] {
+			fields[k] = v
+		}
+	}
+	//映射的键被排序，所以相同的内容总有相同的编码
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("Can not encode the item to JSON: %s", err))
+	}
+	return fmt.Sprintf("%x", sha1.Sum(data)), nil
+}
+
+// 存储在每次写入时都已提交，所以不需要刷新。
+func (sink *myKVSink) Flush() error {
+	return nil
+}
+
+func (sink *myKVSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+	if sink.db == nil {
+		return nil
+	}
+	return sink.db.Close()
+}
+
+// 被存储的条目的读取器的接口类型。
+type ItemStore interface {
+	// 获得所有条目的类型，按字典序排列。没有类型的条目的类型为"item"。
+	Types() ([]string, error)
+	// 获得给定类型和键的条目。条目不存在时返回nil。
+	Get(itemType string, key string) (*StoredItem, error)
+	// 按键的顺序遍历给定类型的条目，itemType为空时遍历所有类型。函数返回错误时停止遍历。
+	ForEach(itemType string, handle func(stored *StoredItem) error) error
+	// 获得给定类型的条目的数量，itemType为空时获得所有条目的数量。
+	Len(itemType string) (int, error)
+	// 关闭读取器。
+	Close() error
+}
+
+// 以只读的方式打开键值存储输出端写入的存储文件。文件正在被写入时会等待一段时间然后返回错误。
+func OpenItemStore(path string) (ItemStore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: kvOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &myItemStore{db: db}, nil
+}
+
+// 被存储的条目的读取器的实现类型。
+type myItemStore struct {
+	db *bolt.DB // 存储。
+}
+
+func (store *myItemStore) Types() ([]string, error) {
+	types := make([]string, 0)
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			types = append(types, string(name))
+			return nil
+		})
+	})
+	sort.Strings(types)
+	return types, err
+}
+
+func (store *myItemStore) Get(itemType string, key string) (*StoredItem, error) {
+	var stored *StoredItem
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(itemType))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		stored = &StoredItem{}
+		return json.Unmarshal(data, stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (store *myItemStore) ForEach(itemType string, handle func(stored *StoredItem) error) error {
+	types := []string{itemType}
+	if itemType == "" {
+		var err error
+		if types, err = store.Types(); err != nil {
+			return err
+		}
+	}
+	return store.db.View(func(tx *bolt.Tx) error {
+		for _, t := range types {
+			bucket := tx.Bucket([]byte(t))
+			if bucket == nil {
+				continue
+			}
+			err := bucket.ForEach(func(_ []byte, data []byte) error {
+				stored := &StoredItem{}
+				if err := json.Unmarshal(data, stored); err != nil {
+					return err
+				}
+				return handle(stored)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (store *myItemStore) Len(itemType string) (int, error) {
+	count := 0
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			if itemType == "" || string(name) == itemType {
+				count += bucket.Stats().KeyN
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+func (store *myItemStore) Close() error {
+	return store.db.Close()
+}
+
+// 把键的表达式拆分为字段路径。
+func kvKeyFields(key string) []string {
+	fields := strings.Split(key, "|")
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return fields
+}
+
+// 获得条目中给定路径的字段。嵌套的字段用点号连接。
+func itemField(item base.Item, path string) (interface{}, bool) {
+	if value, ok := item[path]; ok {
+		return value, true
+	}
+	var current interface{} = map[string]interface{}(item)
+	for _, name := range strings.Split(path, ".") {
+		var fields map[string]interface{}
+		switch v := current.(type) {
+		case base.Item:
+			fields = v
+		case base.Metadata:
+			fields = v
+		case map[string]interface{}:
+			fields = v
+		default:
+			return nil, false
+		}
+		value, ok := fields[name]
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+	return current, true
+}
+
+// 根据参数创建键值存储输出端。参数"path"和"key"是必需的，"ignoreFields"是字段名的列表。
+func newKVSinkFromParams(params base.Params) (ItemProcessor, error) {
+	options := KVSinkOptions{}
+	argsErr := base.NewArgsError()
+	var err error
+	if options.Path, err = params.String("path", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.Key, err = params.String("key", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.IgnoreFields, err = params.Strings("ignoreFields"); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewKVSink(options)
+}
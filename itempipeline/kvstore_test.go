@@ -0,0 +1,131 @@
+package itempipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+func TestKVSinkUpsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	processor, err := NewProcessor("kv", base.Params{"path": path, "key": "canonical|url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := processor.(Sink)
+	items := []base.Item{
+		{"_type": "product", "url": "http://example.com/tea?a=1", "canonical": "http://example.com/tea", "price": 4.5},
+		{"_type": "product", "url": "http://example.com/cup", "price": 2,
+			"_meta": map[string]interface{}{"fetched": time.Now()}},
+		{"url": "http://example.com/about"},
+	}
+	for _, item := range items {
+		if _, err := sink.Process(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	//只有元数据变化的条目不被当作变化
+	sink.Process(base.Item{"_type": "product", "url": "http://example.com/cup", "price": 2,
+		"_meta": map[string]interface{}{"fetched": time.Now()}})
+	sink.Process(base.Item{"_type": "product", "url": "http://example.com/tea", "canonical": "http://example.com/tea", "price": 5})
+	if _, err := sink.Process(base.Item{"title": "no key"}); err == nil {
+		t.Errorf("Expected an error for the item without key!")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenItemStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if types, _ := store.Types(); len(types) != 2 || types[0] != "item" || types[1] != "product" {
+		t.Errorf("Unexpected types %v!", types)
+	}
+	if n, _ := store.Len(""); n != 3 {
+		t.Errorf("Expected 3 stored items, but got %d!", n)
+	}
+	tea, err := store.Get("product", "http://example.com/tea")
+	if err != nil || tea == nil {
+		t.Fatalf("The item is not found: %v", err)
+	}
+	if tea.Item["price"] != 5.0 || tea.Item["url"] != "http://example.com/tea" {
+		t.Errorf("The item is not updated: %v!", tea.Item)
+	}
+	if !tea.ChangedAt.After(tea.FirstSeen) || !tea.LastSeen.Equal(tea.ChangedAt) {
+		t.Errorf("Unexpected timestamps of a changed item: %+v!", tea)
+	}
+	cup, _ := store.Get("product", "http://example.com/cup")
+	if !cup.ChangedAt.Equal(cup.FirstSeen) || !cup.LastSeen.After(cup.FirstSeen) {
+		t.Errorf("Unexpected timestamps of an unchanged item: %+v!", cup)
+	}
+	if missing, _ := store.Get("product", "http://example.com/pot"); missing != nil {
+		t.Errorf("Unexpected item %v!", missing)
+	}
+	keys := make([]string, 0)
+	store.ForEach("product", func(stored *StoredItem) error {
+		keys = append(keys, stored.Key)
+		return nil
+	})
+	if len(keys) != 2 || keys[0] != "http://example.com/cup" {
+		t.Errorf("Unexpected keys %v!", keys)
+	}
+}
+
+func TestKVSinkProcessBatch(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewKVSink(KVSinkOptions{Path: filepath.Join(dir, "items.db"), Key: "url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batchSink := sink.(BatchProcessor)
+	results, errs := batchSink.ProcessBatch([]base.Item{
+		{"url": "http://example.com/a", "n": 1},
+		{"title": "no key"},
+		{"url": "http://example.com/b", "n": 2},
+		{"url": "http://example.com/a", "n": 3},
+	})
+	if results != nil || len(errs) != 4 {
+		t.Fatalf("Unexpected results %v and errors %v!", results, errs)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 1) {
+			t.Errorf("Unexpected error %v for the item %d!", err, i)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenItemStore(filepath.Join(dir, "items.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if n, _ := store.Len(""); n != 2 {
+		t.Errorf("Expected 2 stored items, but got %d!", n)
+	}
+	//同一批次中键相同的条目以后写入的为准
+	if stored, _ := store.Get(kvUntypedBucket, "http://example.com/a"); stored == nil || stored.Item["n"] != float64(3) {
+		t.Errorf("Unexpected stored item %v!", stored)
+	}
+}
+
+func TestKVSinkMkdirError(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewKVSink(KVSinkOptions{Path: filepath.Join(blocker, "items.db"), Key: "url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sink.Process(base.Item{"url": "http://example.com/"}); err == nil {
+		t.Errorf("Expected an error when the directory can not be created!")
+	}
+	sink.Close()
+}
@@ -4,7 +4,10 @@ import (
 	"sys/fetch/base"
 	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 //条目处理管道的接口类型
@@ -18,37 +21,77 @@ type ItemPipeline interface {
 	//设置是否快速失败
 	SetFailFast(failFast bool)
 	//获得已发送、已接收和已处理的条目的技术值
-	//更确切地说，作为结果值的切片总会有3个元素值。者3个值会分别代表前述的3个计数
+	//更确切地说，作为结果值的切片总会有4个元素值。前3个值会分别代表前述的3个计数，
+	//第4个值代表被条目处理器丢弃的条目的数量。被丢弃的条目不计入已处理的条目
 	Count() []uint64
 	//获取正在被处理的条目的数量
 	ProcessingNumber() uint64
+	//获取各个条目处理器的统计信息，顺序与条目处理器的顺序相同
+	Stats() []ProcessorStats
 	//获取摘要信息
 	Summary() string
+	//以固定数量的工作协程处理通道中的条目，直到通道被关闭且所有条目都被处理完毕。
+	//通道本身就是有界的队列：所有工作协程都忙时，向通道发送条目的一方会被阻塞。
+	//每个条目的处理错误(包括处理时引发的panic)会被交给handleErrors。
+	Serve(items <-chan base.Item, workers int, handleErrors func(item base.Item, errs []error))
+	//关闭条目处理管道。它会等待正在被处理的条目，然后关闭实现了io.Closer的条目处理器。
+	//关闭之后发送的条目会被拒绝。重复调用时直接返回nil。
+	Close() error
 }
 
-func NewItemPipeline(itemProcessors []ProcessItem) ItemPipeline {
+//创建条目处理管道。函数形式的条目处理器的序列可以先用Processors转换。
+func NewItemPipeline(itemProcessors []ItemProcessor, options ...PipelineOption) ItemPipeline {
 	if itemProcessors == nil {
 		panic(errors.New(fmt.Sprintf("Invalid item processor list!")))
 	}
-	innerItemProcessors := make([]ProcessItem, 0)
+	innerItemProcessors := make([]ItemProcessor, 0)
 	for i, ip := range itemProcessors {
-		if ip == nil {
+		if IsNilProcessor(ip) {
 			panic(errors.New(fmt.Sprintf("Invalid item processor[%d]!\n", i)))
 		}
 		innerItemProcessors = append(innerItemProcessors, ip)
 	}
-	return &myItemPipeline{itemProcessors: innerItemProcessors}
+	pipeline := &myItemPipeline{}
+	//统计信息使用条目处理器的名称，处理时则使用其本身
+	for i, name := range processorNames(innerItemProcessors) {
+		pipeline.recorders = append(pipeline.recorders, newProcessorRecorder(name))
+		innerItemProcessors[i] = unwrapProcessor(innerItemProcessors[i])
+	}
+	for _, option := range options {
+		if option != nil {
+			option(pipeline)
+		}
+	}
+	if pipeline.batchSize > 0 || pipeline.batchWait > 0 {
+		if err := CheckBatching(pipeline.batchSize, pipeline.batchWait); err != nil {
+			panic(err)
+		}
+		//批量条目处理器被包装为批处理器
+		for i, ip := range innerItemProcessors {
+			if bp, ok := ip.(BatchProcessor); ok {
+				innerItemProcessors[i] = newBatcher(bp, pipeline.batchSize, pipeline.batchWait)
+			}
+		}
+	}
+	pipeline.itemProcessors = innerItemProcessors
+	return pipeline
 }
 
 
 //条目处理管道的实现类型
 type myItemPipeline struct {
-	itemProcessors 	[]ProcessItem	//条目处理器的列表
+	itemProcessors 	[]ItemProcessor	//条目处理器的列表
+	recorders 		[]*processorRecorder //各个条目处理器的统计信息
 	failFast 		bool 			//表示处理是否需要快速失败的标志位
 	sent 			uint64 			//已被发送的条目的数量
 	accepted		uint64 			//已被接收的条目的数量
 	processed 		uint64 			//已被处理的条目的数量
 	processingNumber uint64 		//正在被处理的条目的数量
+	dropped 		uint64 			//已被丢弃的条目的数量
+	batchSize 		int 			//批次的最大条目数。为0时不启用批处理
+	batchWait 		time.Duration 	//批次的最长等待时间
+	closed 			bool 			//是否已被关闭
+	closeMutex 		sync.RWMutex 	//关闭操作的读写锁
 }
 
 
@@ -61,10 +104,22 @@ func (ip *myItemPipeline) Send (item base.Item) []error {
 		errs = append(errs, errors.New("The item is invalid!"))
 		return errs
 	}
+	ip.closeMutex.RLock()
+	defer ip.closeMutex.RUnlock()
+	if ip.closed {
+		errs = append(errs, errors.New("The item pipeline is closed!"))
+		return errs
+	}
 	atomic.AddUint64(&ip.accepted, 1)
 	var currentItem base.Item = item
-	for _, itemProcessor := range ip.itemProcessors {
-		processedItem, err := itemProcessor(currentItem)
+	for i, itemProcessor := range ip.itemProcessors {
+		start := time.Now()
+		processedItem, err := itemProcessor.Process(currentItem)
+		ip.recorders[i].record(time.Since(start), err)
+		if IsDropped(err) {
+			atomic.AddUint64(&ip.dropped, 1)
+			return errs
+		}
 		if err != nil {
 			errs = append(errs, err)
 			if ip.failFast {
@@ -79,6 +134,36 @@ func (ip *myItemPipeline) Send (item base.Item) []error {
 	return errs
 }
 
+func (ip *myItemPipeline) Serve(items <-chan base.Item, workers int, handleErrors func(item base.Item, errs []error)) {
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				errs := ip.sendSafely(item)
+				if len(errs) > 0 && handleErrors != nil {
+					handleErrors(item, errs)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+//发送条目，并把处理时引发的panic转换为错误
+func (ip *myItemPipeline) sendSafely(item base.Item) (errs []error) {
+	defer func() {
+		if p := recover(); p != nil {
+			errs = append(errs, errors.New(fmt.Sprintf("Fatal item processing error: %s", p)))
+		}
+	}()
+	return ip.Send(item)
+}
+
 func (ip *myItemPipeline) FailFast() bool {
 	return ip.failFast
 }
@@ -88,23 +173,50 @@ func (ip *myItemPipeline) SetFailFast(failFast bool) {
 }
 
 func (ip *myItemPipeline) Count() []uint64 {
-	counts := make([]uint64, 3)
+	counts := make([]uint64, 4)
 	counts[0] = atomic.LoadUint64(&ip.sent)
 	counts[1] = atomic.LoadUint64(&ip.accepted)
 	counts[2] = atomic.LoadUint64(&ip.processed)
+	counts[3] = atomic.LoadUint64(&ip.dropped)
 	return counts
 }
 
+func (ip *myItemPipeline) Stats() []ProcessorStats {
+	statsList := make([]ProcessorStats, len(ip.recorders))
+	for i, recorder := range ip.recorders {
+		statsList[i] = recorder.stats()
+	}
+	return statsList
+}
+
 func (ip *myItemPipeline) ProcessingNumber() uint64 {
 	return atomic.LoadUint64(&ip.processingNumber)
 }
 
 var summaryTemplate = "failFast: %v, processorNumber:%d," +
-	" sent: %d, accepted: %d, processed: %d, processingNumber: %d"
+	" sent: %d, accepted: %d, processed: %d, dropped: %d, processingNumber: %d," +
+	" processors: [%s]"
 
 func (ip *myItemPipeline) Summary() string {
 	counts := ip.Count()
 	summary := fmt.Sprintf(summaryTemplate, ip.FailFast(), len(ip.itemProcessors),
-		counts[0], counts[1], counts[2], ip.ProcessingNumber())
+		counts[0], counts[1], counts[2], counts[3], ip.ProcessingNumber(),
+		formatProcessorStats(ip.Stats()))
 	return summary
-}
\ No newline at end of file
+}
+
+func (ip *myItemPipeline) Close() error {
+	ip.closeMutex.Lock()
+	defer ip.closeMutex.Unlock()
+	if ip.closed {
+		return nil
+	}
+	ip.closed = true
+	argsErr := base.NewArgsError()
+	for i, itemProcessor := range ip.itemProcessors {
+		if closer, ok := itemProcessor.(io.Closer); ok {
+			argsErr.Add(fmt.Sprintf("itemProcessors[%d]", i), closer.Close())
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
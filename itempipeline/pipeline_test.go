@@ -0,0 +1,90 @@
+package itempipeline
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+func TestPipelineDrop(t *testing.T) {
+	require, err := NewProcessor("require-fields", base.Params{"fields": []interface{}{"title"}, "drop": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reached := 0
+	pipeline := NewItemPipeline([]ItemProcessor{
+		require,
+		ProcessItem(func(item base.Item) (base.Item, error) {
+			if item["title"] == "spam" {
+				return nil, fmt.Errorf("spam filter: %w", ErrDropItem)
+			}
+			return nil, nil
+		}),
+		ProcessItem(func(item base.Item) (base.Item, error) {
+			reached++
+			if item["title"] == "broken" {
+				return nil, errors.New("broken")
+			}
+			return item, nil
+		}),
+	})
+	items := []base.Item{{"title": "a"}, {}, {"title": "spam"}, {"title": "broken"}}
+	errCount := 0
+	for _, item := range items {
+		errCount += len(pipeline.Send(item))
+	}
+	if reached != 2 || errCount != 1 {
+		t.Errorf("Dropped items should not be processed further nor reported: reached %d, errors %d!",
+			reached, errCount)
+	}
+	counts := pipeline.Count()
+	if len(counts) != 4 || counts[0] != 4 || counts[2] != 2 || counts[3] != 2 {
+		t.Errorf("Unexpected counts %v!", counts)
+	}
+	if summary := pipeline.Summary(); !strings.Contains(summary, "dropped: 2") {
+		t.Errorf("The summary lacks the dropped count: %s", summary)
+	}
+}
+
+func TestPipelineStats(t *testing.T) {
+	slow := ProcessItem(func(item base.Item) (base.Item, error) {
+		time.Sleep(2 * time.Millisecond)
+		return item, nil
+	})
+	sink, _ := NewJsonLinesSink(FileSinkOptions{Path: filepath.Join(t.TempDir(), "items.jsonl")})
+	pipeline := NewItemPipeline([]ItemProcessor{
+		Named("slow", slow),
+		Named("slow", slow),
+		Named("sink", sink),
+		ProcessItem(CopyItem),
+	})
+	for i := 0; i < 3; i++ {
+		pipeline.Send(base.Item{"n": i})
+	}
+	statsList := pipeline.Stats()
+	names := make([]string, len(statsList))
+	for i, stats := range statsList {
+		names[i] = stats.Name
+	}
+	if strings.Join(names, ",") != "slow,slow#2,sink,itempipeline.CopyItem" {
+		t.Errorf("Unexpected processor names %v!", names)
+	}
+	slowStats := statsList[0]
+	if slowStats.Invocations != 3 || slowStats.MeanTime() < 2*time.Millisecond ||
+		slowStats.Histogram[2].Count != 3 || slowStats.Quantile(0.5) != slowStats.MaxTime {
+		t.Errorf("Unexpected stats %+v!", slowStats)
+	}
+	if !strings.Contains(pipeline.Summary(), "slow#2: calls=3") {
+		t.Errorf("The summary lacks the processor stats: %s", pipeline.Summary())
+	}
+	//被命名的输出端依然会被关闭
+	pipeline.Close()
+	if _, err := sink.Process(base.Item{}); err == nil {
+		t.Errorf("The named sink should be closed with the pipeline!")
+	}
+}
@@ -1,7 +1,57 @@
 package itempipeline
 
-import "sys/fetch/base"
+import (
+	"errors"
 
-// 被用来处理条目的函数类型。
+	"sys/fetch/base"
+)
+
+// 被用来处理条目的函数类型。返回的结果为nil时沿用原来的条目。
+// 返回ErrDropItem(或包装了它的错误)表示丢弃条目：条目处理管道会停止处理该条目，
+// 并把它计入丢弃的条目，而不是报告错误。
 type ProcessItem func(item base.Item) (result base.Item, err error)
 
+// 表示丢弃条目的错误。
+var ErrDropItem = errors.New("The item is dropped!")
+
+// 判断错误是否表示丢弃条目。
+func IsDropped(err error) bool {
+	return errors.Is(err, ErrDropItem)
+}
+
+// 条目处理器的接口类型。需要释放资源的条目处理器(如写文件的输出端)还应实现io.Closer，
+// 条目处理管道被关闭时会调用它的Close方法。
+type ItemProcessor interface {
+	// 处理条目。返回值的含义与ProcessItem相同。
+	Process(item base.Item) (result base.Item, err error)
+}
+
+// 处理条目。ProcessItem因此也是ItemProcessor。
+func (f ProcessItem) Process(item base.Item) (base.Item, error) {
+	return f(item)
+}
+
+// 判断条目处理器是否无效，即为nil或为值为nil的ProcessItem。
+func IsNilProcessor(processor ItemProcessor) bool {
+	if processor == nil {
+		return true
+	}
+	f, ok := processor.(ProcessItem)
+	return ok && f == nil
+}
+
+// 把函数序列转换为条目处理器的序列。函数序列为nil时返回nil，以便调用方能够识别出无效的序列。
+func Processors(itemProcessors ...ProcessItem) []ItemProcessor {
+	if itemProcessors == nil {
+		return nil
+	}
+	processors := make([]ItemProcessor, 0, len(itemProcessors))
+	for _, ip := range itemProcessors {
+		if ip == nil {
+			processors = append(processors, nil)
+			continue
+		}
+		processors = append(processors, ip)
+	}
+	return processors
+}
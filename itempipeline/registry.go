@@ -0,0 +1,94 @@
+package itempipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sys/fetch/base"
+)
+
+// 生成条目处理器的工厂函数类型。参数params来自配置，可能为空。
+type ProcessorFactory func(params base.Params) (ItemProcessor, error)
+
+// 条目处理器的注册表。配置文件通过名称引用其中的条目处理器。
+var processorRegistry = struct {
+	sync.RWMutex
+	factories map[string]ProcessorFactory
+}{factories: make(map[string]ProcessorFactory)}
+
+// 以给定的名称注册条目处理器。名称为空、处理器为nil或名称重复时会引发panic。
+func Register(name string, processor ProcessItem) {
+	if processor == nil {
+		panic(errors.New(fmt.Sprintf("The item processor '%s' is invalid!", name)))
+	}
+	RegisterFactory(name, func(params base.Params) (ItemProcessor, error) {
+		if len(params) > 0 {
+			return nil, errors.New(fmt.Sprintf(
+				"The item processor '%s' does not accept any params!", name))
+		}
+		return processor, nil
+	})
+}
+
+// 以给定的名称注册生成条目处理器的工厂函数。
+// 名称为空、函数为nil或名称重复时会引发panic。
+func RegisterFactory(name string, factory ProcessorFactory) {
+	if name == "" {
+		panic(errors.New("The item processor name can not be empty!"))
+	}
+	if factory == nil {
+		panic(errors.New(fmt.Sprintf("The item processor factory '%s' is invalid!", name)))
+	}
+	processorRegistry.Lock()
+	defer processorRegistry.Unlock()
+	if _, ok := processorRegistry.factories[name]; ok {
+		panic(errors.New(fmt.Sprintf("The item processor '%s' is already registered!", name)))
+	}
+	processorRegistry.factories[name] = factory
+}
+
+// 判断给定名称的条目处理器是否已被注册。
+func HasProcessor(name string) bool {
+	processorRegistry.RLock()
+	defer processorRegistry.RUnlock()
+	_, ok := processorRegistry.factories[name]
+	return ok
+}
+
+// 根据名称和参数生成已注册的条目处理器。
+func NewProcessor(name string, params base.Params) (ItemProcessor, error) {
+	processorRegistry.RLock()
+	factory, ok := processorRegistry.factories[name]
+	processorRegistry.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown item processor '%s'! (available: %s)",
+			name, strings.Join(ProcessorNames(), ", ")))
+	}
+	processor, err := factory(params)
+	if err != nil {
+		return nil, err
+	}
+	if IsNilProcessor(processor) {
+		return nil, errors.New(fmt.Sprintf("The item processor factory '%s' returns nil!", name))
+	}
+	return processor, nil
+}
+
+// 根据名称获得已注册的不带参数的条目处理器。
+func GetProcessor(name string) (ItemProcessor, error) {
+	return NewProcessor(name, nil)
+}
+
+// 获得所有已注册的条目处理器的名称，按字典序排列。
+func ProcessorNames() []string {
+	processorRegistry.RLock()
+	defer processorRegistry.RUnlock()
+	names := make([]string, 0, len(processorRegistry.factories))
+	for name := range processorRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,745 @@
+package itempipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sys/fetch/base"
+)
+
+// 模式中在base.ValueType之外增加的值的类型。
+const (
+	TYPE_OBJECT base.ValueType = "object" // 对象，即字段名到字段值的字典。
+	TYPE_ARRAY  base.ValueType = "array"  // 数组。
+)
+
+// 条目不符合模式时的处理方式。
+const (
+	INVALID_ITEMS_FAIL     = "fail"     // 报告错误。
+	INVALID_ITEMS_DROP     = "drop"     // 丢弃条目。
+	INVALID_ITEMS_ANNOTATE = "annotate" // 把违规之处写入条目的一个字段，然后继续处理。
+)
+
+// 匹配任意类型的条目的模式的键。
+const SchemaAnyType = "*"
+
+// 默认的记录违规之处的字段的名称。
+const defaultViolationsField = "_violations"
+
+// 与JSON Schema中的类型名对应的值的类型。
+var schemaTypeAliases = map[string]base.ValueType{
+	"integer": base.TYPE_INT,
+	"number":  base.TYPE_FLOAT,
+	"boolean": base.TYPE_BOOL,
+}
+
+func init() {
+	RegisterFactory("validate", newSchemaValidatorFromParams)
+}
+
+// 值的模式。它是JSON Schema的一个简化的子集，除类型之外的约束只作用于适用的值，
+// 例如Pattern只作用于字符串和URL，Minimum只作用于数字。
+type Schema struct {
+	Type       base.ValueType     // 值的类型。为空时不检查类型。
+	Layout     string             // 类型为base.TYPE_DATE时所用的时间格式，为空时尝试常见的格式。
+	Required   []string           // 对象中必需的字段。值为nil的字段也被视为缺少。
+	Properties map[string]*Schema // 对象中的字段的模式。未列出的字段不被检查。
+	Items      *Schema            // 数组中的元素的模式。
+	Pattern    *regexp.Regexp     // 字符串需匹配的正则表达式。
+	Minimum    *float64           // 数字的最小值。
+	Maximum    *float64           // 数字的最大值。
+	MinLength  *int               // 字符串的最少字符数或数组的最少元素数。
+	MaxLength  *int               // 字符串的最多字符数或数组的最多元素数。
+}
+
+// 检查模式的有效性。
+func (schema *Schema) Check() error {
+	argsErr := base.NewArgsError()
+	switch schema.Type {
+	case TYPE_OBJECT, TYPE_ARRAY:
+	default:
+		if !schema.Type.Valid() {
+			argsErr.Add("type", errors.New(fmt.Sprintf("Unsupported value type '%s'!", schema.Type)))
+		}
+	}
+	if schema.Minimum != nil && schema.Maximum != nil && *schema.Minimum > *schema.Maximum {
+		argsErr.Add("minimum", errors.New(fmt.Sprintf("The minimum %v is greater than the maximum %v!",
+			*schema.Minimum, *schema.Maximum)))
+	}
+	if schema.MinLength != nil && *schema.MinLength < 0 {
+		argsErr.Add("minLength", errors.New(fmt.Sprintf("Invalid min length %d!", *schema.MinLength)))
+	}
+	if schema.MaxLength != nil && *schema.MaxLength < 0 {
+		argsErr.Add("maxLength", errors.New(fmt.Sprintf("Invalid max length %d!", *schema.MaxLength)))
+	}
+	for i, name := range schema.Required {
+		if name == "" {
+			argsErr.Add(fmt.Sprintf("required[%d]", i), errors.New("The field name can not be empty!"))
+		}
+	}
+	for _, name := range sortedKeys(schema.Properties) {
+		if schema.Properties[name] == nil {
+			argsErr.Add("properties."+name, errors.New("The schema is invalid!"))
+			continue
+		}
+		argsErr.Add("properties."+name, schema.Properties[name].Check())
+	}
+	if schema.Items != nil {
+		argsErr.Add("items", schema.Items.Check())
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 违反模式之处。
+type SchemaViolation struct {
+	Path    string // 值的路径，例如"offer.price"或"tags[2]"。空路径代表整个条目。
+	Message string // 说明。
+}
+
+func (violation SchemaViolation) String() string {
+	if violation.Path == "" {
+		return violation.Message
+	}
+	return violation.Path + ": " + violation.Message
+}
+
+// 条目不符合模式的错误。
+type SchemaError struct {
+	Type       string            // 条目的类型。
+	Violations []SchemaViolation // 违反模式之处。
+}
+
+func (err *SchemaError) Error() string {
+	parts := make([]string, len(err.Violations))
+	for i, violation := range err.Violations {
+		parts[i] = violation.String()
+	}
+	return fmt.Sprintf("The item '%s' violates the schema: %s", err.Type, strings.Join(parts, "; "))
+}
+
+// 校验值是否符合模式。参数coerce为true时，字符串会被转换为模式所要求的数字、布尔值或时间。
+// 结果值是转换之后的值，存在转换时对象和数组会被复制，原来的值不会被修改。
+func (schema *Schema) Validate(value interface{}, coerce bool) (interface{}, []SchemaViolation) {
+	return schema.ValidateAt(value, coerce, nil)
+}
+
+// 与Validate相同，只是在转换字符串时，相对URL会依据baseUrl被解析为绝对URL。参数baseUrl可以为nil。
+func (schema *Schema) ValidateAt(value interface{}, coerce bool, baseUrl *url.URL) (interface{}, []SchemaViolation) {
+	v := &schemaValidator{coerce: coerce, baseUrl: baseUrl}
+	result := v.validate(schema, "", value)
+	return result, v.violations
+}
+
+// 模式校验的状态。
+type schemaValidator struct {
+	coerce     bool              // 是否转换字符串。
+	baseUrl    *url.URL          // 解析相对URL所依据的URL，可以为nil。
+	violations []SchemaViolation // 已发现的违规之处。
+}
+
+// 记录违规之处。
+func (v *schemaValidator) report(path string, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// 校验单个值并返回转换之后的值。
+func (v *schemaValidator) validate(schema *Schema, path string, value interface{}) interface{} {
+	switch schema.Type {
+	case TYPE_OBJECT:
+		fields, ok := objectFields(value)
+		if !ok {
+			v.report(path, "should be an object, but it's %s", describeValue(value))
+			return value
+		}
+		return v.validateObject(schema, path, fields, value)
+	case TYPE_ARRAY:
+		elems, ok := arrayElems(value)
+		if !ok {
+			v.report(path, "should be an array, but it's %s", describeValue(value))
+			return value
+		}
+		return v.validateArray(schema, path, elems, value)
+	case "":
+		if fields, ok := objectFields(value); ok {
+			return v.validateObject(schema, path, fields, value)
+		}
+		if elems, ok := arrayElems(value); ok {
+			return v.validateArray(schema, path, elems, value)
+		}
+	default:
+		var ok bool
+		if value, ok = v.validateScalar(schema, path, value); !ok {
+			return value
+		}
+	}
+	if s, ok := value.(string); ok {
+		v.checkString(schema, path, s)
+	} else if u, ok := value.(*url.URL); ok && u != nil {
+		v.checkString(schema, path, u.String())
+	} else if n, ok := numberValue(value); ok {
+		if schema.Minimum != nil && n < *schema.Minimum {
+			v.report(path, "%v is less than the minimum %v", n, *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			v.report(path, "%v is greater than the maximum %v", n, *schema.Maximum)
+		}
+	}
+	return value
+}
+
+// 校验对象。
+func (v *schemaValidator) validateObject(schema *Schema, path string, fields map[string]interface{}, value interface{}) interface{} {
+	for _, name := range schema.Required {
+		if fields[name] == nil {
+			v.report(joinPath(path, name), "is required")
+		}
+	}
+	var result map[string]interface{}
+	for _, name := range sortedKeys(schema.Properties) {
+		fieldValue, ok := fields[name]
+		if !ok || fieldValue == nil {
+			continue
+		}
+		validated := v.validate(schema.Properties[name], joinPath(path, name), fieldValue)
+		if v.coerce && !sameValue(validated, fieldValue) {
+			if result == nil {
+				result = make(map[string]interface{}, len(fields))
+				for k, e := range fields {
+					result[k] = e
+				}
+			}
+			result[name] = validated
+		}
+	}
+	if result == nil {
+		return value
+	}
+	switch value.(type) {
+	case base.Item:
+		return base.Item(result)
+	case base.Metadata:
+		return base.Metadata(result)
+	}
+	return result
+}
+
+// 校验数组。
+func (v *schemaValidator) validateArray(schema *Schema, path string, elems []interface{}, value interface{}) interface{} {
+	if schema.MinLength != nil && len(elems) < *schema.MinLength {
+		v.report(path, "has %d elements, but should have at least %d", len(elems), *schema.MinLength)
+	}
+	if schema.MaxLength != nil && len(elems) > *schema.MaxLength {
+		v.report(path, "has %d elements, but should have at most %d", len(elems), *schema.MaxLength)
+	}
+	if schema.Items == nil {
+		return value
+	}
+	changed := false
+	result := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		if elem == nil {
+			v.report(elemPath, "should not be null")
+			result[i] = elem
+			continue
+		}
+		result[i] = v.validate(schema.Items, elemPath, elem)
+		if !sameValue(result[i], elem) {
+			changed = true
+		}
+	}
+	if v.coerce && changed {
+		return result
+	}
+	return value
+}
+
+// 校验标量值的类型，必要时转换字符串。第二个结果为false时表示类型不符。
+func (v *schemaValidator) validateScalar(schema *Schema, path string, value interface{}) (interface{}, bool) {
+	s, isString := value.(string)
+	switch schema.Type {
+	case base.TYPE_STRING:
+		if isString {
+			return value, true
+		}
+	case base.TYPE_INT:
+		if n, ok := numberValue(value); ok {
+			if n == math.Trunc(n) {
+				return value, true
+			}
+		} else if isString && v.coerce {
+			return v.coerceString(schema, path, s)
+		}
+	case base.TYPE_FLOAT:
+		if _, ok := numberValue(value); ok {
+			return value, true
+		}
+		if isString && v.coerce {
+			return v.coerceString(schema, path, s)
+		}
+	case base.TYPE_BOOL:
+		if _, ok := value.(bool); ok {
+			return value, true
+		}
+		if isString && v.coerce {
+			return v.coerceString(schema, path, s)
+		}
+	case base.TYPE_DATE:
+		switch t := value.(type) {
+		case time.Time:
+			return value, true
+		case *time.Time:
+			if t != nil {
+				return value, true
+			}
+		}
+		if isString {
+			if v.coerce {
+				return v.coerceString(schema, path, s)
+			}
+			//未转换的字符串只要能被解析为时间即可
+			if _, err := base.CoerceValue(s, base.TYPE_DATE, schema.Layout, nil); err == nil {
+				return value, true
+			}
+			v.report(path, "'%s' is not a valid date", s)
+			return value, false
+		}
+	case base.TYPE_URL:
+		switch u := value.(type) {
+		case *url.URL:
+			if u != nil && u.IsAbs() {
+				return value, true
+			}
+		case url.URL:
+			if u.IsAbs() {
+				return value, true
+			}
+		case string:
+			parsed, err := url.Parse(strings.TrimSpace(u))
+			if err == nil && v.coerce && v.baseUrl != nil {
+				//转换时相对URL先依据页面的URL被解析
+				parsed = v.baseUrl.ResolveReference(parsed)
+			}
+			if err == nil && parsed.IsAbs() && parsed.Host != "" {
+				if v.coerce {
+					return parsed.String(), true
+				}
+				return value, true
+			}
+			v.report(path, "'%s' is not an absolute url", u)
+			return value, false
+		}
+	}
+	v.report(path, "should be a %s, but it's %s", schema.Type, describeValue(value))
+	return value, false
+}
+
+// 把字符串转换为模式所要求的类型。
+func (v *schemaValidator) coerceString(schema *Schema, path string, s string) (interface{}, bool) {
+	result, err := base.CoerceValue(s, schema.Type, schema.Layout, v.baseUrl)
+	if err != nil {
+		v.report(path, "%s", err)
+		return s, false
+	}
+	return result, true
+}
+
+// 检查字符串的长度和模式。
+func (v *schemaValidator) checkString(schema *Schema, path string, s string) {
+	length := utf8.RuneCountInString(s)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		v.report(path, "has %d characters, but should have at least %d", length, *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		v.report(path, "has %d characters, but should have at most %d", length, *schema.MaxLength)
+	}
+	if schema.Pattern != nil && !schema.Pattern.MatchString(s) {
+		v.report(path, "'%s' does not match the pattern '%s'", s, schema.Pattern)
+	}
+}
+
+// 获得对象的字段。
+func objectFields(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case base.Item:
+		return v, true
+	case base.Metadata:
+		return v, true
+	case base.Params:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// 获得数组的元素。字节切片不被视为数组。
+func arrayElems(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []byte, nil:
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elems := make([]interface{}, rv.Len())
+	for i := range elems {
+		elems[i] = rv.Index(i).Interface()
+	}
+	return elems, true
+}
+
+// 获得数字的值。
+func numberValue(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// 判断转换前后的值是否为同一个值。映射和切片比较其地址。
+func sameValue(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if ra.Kind() != rb.Kind() || ra.Type() != rb.Type() {
+		return false
+	}
+	switch ra.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr:
+		return ra.Pointer() == rb.Pointer()
+	}
+	return ra.Comparable() && a == b
+}
+
+// 描述值的类型，用于违规的说明。
+func describeValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	switch value.(type) {
+	case string:
+		return fmt.Sprintf("the string '%s'", value)
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// 连接值的路径。
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// 模式校验器的选项。
+type SchemaOptions struct {
+	// 各类型的条目的模式，键为条目的类型。键SchemaAnyType的模式适用于其他所有类型的条目，
+	// 没有适用的模式的条目不被校验。模式的类型为空时被视为对象。
+	Schemas map[string]*Schema
+	// 是否把字符串转换为模式所要求的数字、布尔值、时间或URL。
+	// 相对URL会依据条目的来源信息中的页面URL被解析。
+	Coerce bool
+	// 条目不符合模式时的处理方式。为空时使用INVALID_ITEMS_FAIL。
+	OnInvalid string
+	// 记录违规之处的字段。仅在OnInvalid为INVALID_ITEMS_ANNOTATE时有效，为空时使用"_violations"。
+	ViolationsField string
+}
+
+// 检查模式校验器的选项的有效性。
+func (options *SchemaOptions) Check() error {
+	argsErr := base.NewArgsError()
+	if len(options.Schemas) == 0 {
+		argsErr.Add("schemas", errors.New("The schema list is empty!"))
+	}
+	for _, itemType := range sortedKeys(options.Schemas) {
+		schema := options.Schemas[itemType]
+		field := "schemas." + itemType
+		if schema == nil {
+			argsErr.Add(field, errors.New("The schema is invalid!"))
+			continue
+		}
+		if schema.Type != "" && schema.Type != TYPE_OBJECT {
+			argsErr.Add(field, errors.New(fmt.Sprintf("The item schema should be an object, but it's a %s!", schema.Type)))
+		}
+		argsErr.Add(field, schema.Check())
+	}
+	switch options.OnInvalid {
+	case "", INVALID_ITEMS_FAIL, INVALID_ITEMS_DROP, INVALID_ITEMS_ANNOTATE:
+	default:
+		argsErr.Add("onInvalid", errors.New(fmt.Sprintf("Unknown policy '%s'! It should be %s, %s or %s.",
+			options.OnInvalid, INVALID_ITEMS_FAIL, INVALID_ITEMS_DROP, INVALID_ITEMS_ANNOTATE)))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 创建按条目的类型校验条目的条目处理器。不符合模式的条目会按options.OnInvalid被报告为
+// *SchemaError、被丢弃或被标注。启用转换时，后续的处理器得到的是转换之后的条目。
+func NewSchemaValidator(options SchemaOptions) (ItemProcessor, error) {
+	if err := options.Check(); err != nil {
+		return nil, err
+	}
+	if options.OnInvalid == "" {
+		options.OnInvalid = INVALID_ITEMS_FAIL
+	}
+	if options.ViolationsField == "" {
+		options.ViolationsField = defaultViolationsField
+	}
+	schemas := make(map[string]*Schema, len(options.Schemas))
+	for itemType, schema := range options.Schemas {
+		if schema.Type == "" {
+			copied := *schema
+			copied.Type = TYPE_OBJECT
+			schema = &copied
+		}
+		schemas[itemType] = schema
+	}
+	options.Schemas = schemas
+	return &mySchemaValidator{options: options}, nil
+}
+
+// 模式校验器的实现类型。
+type mySchemaValidator struct {
+	options SchemaOptions // 选项。
+}
+
+func (sv *mySchemaValidator) Process(item base.Item) (base.Item, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	itemType, _ := item[base.ITEM_TYPE_KEY].(string)
+	schema, ok := sv.options.Schemas[itemType]
+	if !ok {
+		if schema, ok = sv.options.Schemas[SchemaAnyType]; !ok {
+			return item, nil
+		}
+	}
+	result, violations := schema.ValidateAt(item, sv.options.Coerce, itemPageUrl(item))
+	validated := result.(base.Item)
+	if len(violations) == 0 {
+		return validated, nil
+	}
+	switch sv.options.OnInvalid {
+	case INVALID_ITEMS_DROP:
+		return nil, ErrDropItem
+	case INVALID_ITEMS_ANNOTATE:
+		annotations := make([]interface{}, len(violations))
+		for i, violation := range violations {
+			annotations[i] = violation.String()
+		}
+		if sameValue(validated, item) {
+			validated = make(base.Item, len(item)+1)
+			for k, e := range item {
+				validated[k] = e
+			}
+		}
+		validated[sv.options.ViolationsField] = annotations
+		return validated, nil
+	}
+	return nil, &SchemaError{Type: itemType, Violations: violations}
+}
+
+// 获得条目所在页面的URL，即条目的来源信息中的"url"。没有或无效时返回nil。
+func itemPageUrl(item base.Item) *url.URL {
+	meta, ok := objectFields(item[base.ITEM_META_KEY])
+	if !ok {
+		return nil
+	}
+	pageUrl, _ := meta["url"].(string)
+	u, err := url.Parse(pageUrl)
+	if err != nil || !u.IsAbs() {
+		return nil
+	}
+	return u
+}
+
+// 根据参数创建模式校验器。参数"schemas"是条目的类型到模式的字典，
+// 参数"schemaFile"是内容为这种字典的JSON文件的路径，两者至少给出一个，同一类型以前者为准。
+// 模式可以是类型名，也可以包含type、layout、required、properties、items、pattern、
+// minimum、maximum、minLength和maxLength，类型名也可以是integer、number和boolean。
+// 其他参数为"coerce"、"onInvalid"和"violationsField"，见SchemaOptions。
+func newSchemaValidatorFromParams(params base.Params) (ItemProcessor, error) {
+	options := SchemaOptions{Schemas: make(map[string]*Schema)}
+	argsErr := base.NewArgsError()
+	if path, err := params.String("schemaFile", ""); err != nil {
+		argsErr.Add("", err)
+	} else if path != "" {
+		fileSchemas, err := readSchemaFile(path)
+		if err != nil {
+			argsErr.Add("schemaFile", err)
+		}
+		for _, itemType := range sortedKeys(fileSchemas) {
+			schema, err := schemaFromParam(fileSchemas[itemType])
+			if err != nil {
+				argsErr.Add("schemaFile."+itemType, err)
+				continue
+			}
+			options.Schemas[itemType] = schema
+		}
+	}
+	schemasParams, err := params.Params("schemas")
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	for _, itemType := range sortedKeys(schemasParams) {
+		schema, err := schemaFromParam(schemasParams[itemType])
+		if err != nil {
+			argsErr.Add("schemas."+itemType, err)
+			continue
+		}
+		options.Schemas[itemType] = schema
+	}
+	if options.Coerce, err = params.Bool("coerce", false); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.OnInvalid, err = params.String("onInvalid", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.ViolationsField, err = params.String("violationsField", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if err := argsErr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return NewSchemaValidator(options)
+}
+
+// 读取模式文件。
+func readSchemaFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]interface{})
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid schema file '%s': %s", path, err))
+	}
+	return schemas, nil
+}
+
+// 根据参数生成模式。参数可以是类型名或模式的字典。
+func schemaFromParam(param interface{}) (*Schema, error) {
+	if name, ok := param.(string); ok {
+		return &Schema{Type: schemaType(name)}, nil
+	}
+	var params base.Params
+	switch p := param.(type) {
+	case base.Params:
+		params = p
+	case map[string]interface{}:
+		params = base.Params(p)
+	default:
+		return nil, errors.New(fmt.Sprintf(
+			"The schema should be a type name or a map, but it's %T!", param))
+	}
+	schema := &Schema{}
+	argsErr := base.NewArgsError()
+	if name, err := params.String("type", ""); err != nil {
+		argsErr.Add("", err)
+	} else {
+		schema.Type = schemaType(name)
+	}
+	var err error
+	if schema.Layout, err = params.String("layout", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	if schema.Required, err = params.Strings("required"); err != nil {
+		argsErr.Add("", err)
+	}
+	properties, err := params.Params("properties")
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	if len(properties) > 0 {
+		schema.Properties = make(map[string]*Schema, len(properties))
+	}
+	for _, name := range sortedKeys(properties) {
+		property, err := schemaFromParam(properties[name])
+		if err != nil {
+			argsErr.Add("properties."+name, err)
+			continue
+		}
+		schema.Properties[name] = property
+	}
+	if params.Has("items") {
+		if schema.Items, err = schemaFromParam(params["items"]); err != nil {
+			argsErr.Add("items", err)
+		}
+	}
+	if pattern, err := params.String("pattern", ""); err != nil {
+		argsErr.Add("", err)
+	} else if pattern != "" {
+		if schema.Pattern, err = regexp.Compile(pattern); err != nil {
+			argsErr.Add("pattern", err)
+		}
+	}
+	for key, bound := range map[string]**float64{"minimum": &schema.Minimum, "maximum": &schema.Maximum} {
+		if !params.Has(key) {
+			continue
+		}
+		if n, err := params.Float(key, 0); err != nil {
+			argsErr.Add("", err)
+		} else {
+			*bound = &n
+		}
+	}
+	for key, bound := range map[string]**int{"minLength": &schema.MinLength, "maxLength": &schema.MaxLength} {
+		if !params.Has(key) {
+			continue
+		}
+		if n, err := params.Int(key, 0); err != nil {
+			argsErr.Add("", err)
+		} else {
+			*bound = &n
+		}
+	}
+	return schema, argsErr.ErrorOrNil()
+}
+
+// 获得类型名对应的值的类型。
+func schemaType(name string) base.ValueType {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if vt, ok := schemaTypeAliases[name]; ok {
+		return vt
+	}
+	return base.ValueType(name)
+}
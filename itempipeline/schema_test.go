@@ -0,0 +1,173 @@
+package itempipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 测试所用的商品条目的模式。
+var testProductSchema = base.Params{
+	"required": []interface{}{"name", "price"},
+	"properties": map[string]interface{}{
+		"name":  map[string]interface{}{"type": "string", "minLength": 2},
+		"price": map[string]interface{}{"type": "number", "minimum": 0},
+		"sku":   map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}-\\d+$"},
+		"url":   "url",
+		"added": map[string]interface{}{"type": "date", "layout": "2006-01-02"},
+		"stock": map[string]interface{}{"type": "integer"},
+		"tags":  map[string]interface{}{"type": "array", "items": "string", "maxLength": 2},
+		"offer": map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"currency"},
+			"properties": map[string]interface{}{"currency": map[string]interface{}{"pattern": "^[A-Z]{3}$"}},
+		},
+	},
+}
+
+func TestSchemaValidatorViolations(t *testing.T) {
+	processor, err := NewProcessor("validate", base.Params{"schemas": base.Params{"product": testProductSchema}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := base.Item{
+		"_type": "product",
+		"name":  "T",
+		"price": -1.5,
+		"sku":   "abc-1",
+		"url":   "/tea",
+		"stock": "7",
+		"tags":  []interface{}{"a", 2, "c"},
+		"offer": map[string]interface{}{"currency": "euro"},
+	}
+	_, err = processor.Process(item)
+	schemaErr, ok := err.(*SchemaError)
+	if !ok {
+		t.Fatalf("Expected a schema error, but got %v!", err)
+	}
+	paths := make([]string, 0)
+	for _, violation := range schemaErr.Violations {
+		paths = append(paths, violation.Path)
+	}
+	expected := []string{"name", "offer.currency", "price", "sku", "stock", "tags", "tags[1]", "url"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Unexpected violations %v!", schemaErr.Violations)
+	}
+	if !strings.Contains(err.Error(), "price: -1.5 is less than the minimum 0") {
+		t.Errorf("Unexpected error message %s!", err)
+	}
+	//其他类型的条目不被校验
+	if _, err := processor.Process(base.Item{"_type": "article"}); err != nil {
+		t.Errorf("Unexpected error %s!", err)
+	}
+}
+
+func TestSchemaValidatorCoerce(t *testing.T) {
+	processor, err := NewProcessor("validate", base.Params{
+		"schemas": base.Params{"*": testProductSchema},
+		"coerce":  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer := map[string]interface{}{"currency": "EUR"}
+	item := base.Item{
+		"name":  "Tea",
+		"price": "1,250.5",
+		"added": "2024-05-01",
+		"stock": "7",
+		"offer": offer,
+	}
+	result, err := processor.Process(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["price"] != 1250.5 || result["stock"] != int64(7) ||
+		result["added"] != time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("Unexpected coerced item %v!", result)
+	}
+	if item["price"] != "1,250.5" {
+		t.Errorf("The original item should not be modified, but got %v!", item)
+	}
+	if _, err := processor.Process(base.Item{"name": "Tea", "price": "free"}); err == nil {
+		t.Errorf("Expected an error for the price 'free'!")
+	}
+}
+
+// 转换时相对URL依据条目所在页面的URL被解析，没有页面URL时仍被拒绝。
+func TestSchemaValidatorCoerceRelativeUrl(t *testing.T) {
+	processor, err := NewProcessor("validate", base.Params{
+		"schemas": base.Params{"*": testProductSchema},
+		"coerce":  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := base.Item{
+		"name":  "Tea",
+		"price": 1.5,
+		"url":   "../tea?id=1",
+		"_meta": base.Metadata{"url": "http://example.com/shop/list/"},
+	}
+	result, err := processor.Process(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["url"] != "http://example.com/shop/tea?id=1" {
+		t.Errorf("Unexpected url %v!", result["url"])
+	}
+	//经JSON编码和解码的来源信息同样有效
+	item["_meta"] = map[string]interface{}{"url": "http://example.com/"}
+	if result, err := processor.Process(item); err != nil || result["url"] != "http://example.com/tea?id=1" {
+		t.Errorf("Unexpected result %v, %v!", result, err)
+	}
+	delete(item, "_meta")
+	if _, err := processor.Process(item); err == nil || !strings.Contains(err.Error(), "not an absolute url") {
+		t.Errorf("Expected an error for the relative url, but got %v!", err)
+	}
+}
+
+func TestSchemaValidatorPolicies(t *testing.T) {
+	schemas := base.Params{"*": base.Params{"required": []interface{}{"title"}}}
+	drop, err := NewProcessor("validate", base.Params{"schemas": schemas, "onInvalid": "drop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := drop.Process(base.Item{}); !IsDropped(err) {
+		t.Errorf("Expected the item to be dropped, but got %v!", err)
+	}
+	annotate, err := NewProcessor("validate", base.Params{"schemas": schemas, "onInvalid": "annotate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := annotate.Process(base.Item{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result["_violations"], []interface{}{"title: is required"}) {
+		t.Errorf("Unexpected annotated item %v!", result)
+	}
+
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	os.WriteFile(path, []byte(`{"page": {"properties": {"depth": "int"}}}`), 0644)
+	if _, err := NewProcessor("validate", base.Params{"schemaFile": path}); err != nil {
+		t.Errorf("Unexpected error %s!", err)
+	}
+	invalid := []base.Params{
+		{},
+		{"schemas": base.Params{"x": "decimal"}},
+		{"schemas": base.Params{"x": "string"}},
+		{"schemas": schemas, "onInvalid": "ignore"},
+		{"schemas": base.Params{"x": base.Params{"properties": base.Params{"a": base.Params{"pattern": "("}}}}},
+	}
+	for _, params := range invalid {
+		if _, err := NewProcessor("validate", params); err == nil {
+			t.Errorf("Expected an error for the params %v!", params)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package itempipeline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 缓冲区的默认大小。
+const defaultSinkBufferSize = 64 * 1024
+
+// 同步到磁盘的默认间隔时间。
+const defaultSinkSyncInterval = time.Second
+
+// 输出端的接口类型。输出端把条目写到外部的存储中，并原样返回条目。
+type Sink interface {
+	ItemProcessor
+	// 把缓冲的数据写入存储。
+	Flush() error
+	// 刷新并关闭输出端。关闭之后处理的条目会被拒绝。
+	Close() error
+}
+
+// 文件输出端的选项。
+type FileSinkOptions struct {
+	Path         string        // 文件的路径。设定了轮转条件时，它被用作轮转文件的名称模板。
+	MaxBytes     int64         // 单个文件的最大字节数。为0时不限制。
+	MaxItems     int           // 单个文件的最多条目数。为0时不限制。
+	MaxAge       time.Duration // 单个文件的最长写入时间。为0时不限制。
+	SyncInterval time.Duration // 刷新缓冲区并同步到磁盘的间隔时间。为0时使用默认值，为负数时只在关闭时同步。
+	BufferSize   int           // 缓冲区的字节数。为0时使用默认值。
+}
+
+// 检查文件输出端的选项的有效性。
+func (options *FileSinkOptions) Check() error {
+	argsErr := base.NewArgsError()
+	if options.Path == "" {
+		argsErr.Add("path", errors.New("The path can not be empty!"))
+	}
+	if options.MaxBytes < 0 {
+		argsErr.Add("maxBytes", errors.New("The max bytes can not be negative!"))
+	}
+	if options.MaxItems < 0 {
+		argsErr.Add("maxItems", errors.New("The max items can not be negative!"))
+	}
+	if options.MaxAge < 0 {
+		argsErr.Add("maxAge", errors.New("The max age can not be negative!"))
+	}
+	if options.BufferSize < 0 {
+		argsErr.Add("bufferSize", errors.New("The buffer size can not be negative!"))
+	}
+	return argsErr.ErrorOrNil()
+}
+
+// 判断是否需要轮转文件。
+func (options *FileSinkOptions) rotating() bool {
+	return options.MaxBytes > 0 || options.MaxItems > 0 || options.MaxAge > 0
+}
+
+// 从参数中读取文件输出端的选项。参数与FileSinkOptions的字段对应，时间间隔为形如"10m"的字符串。
+func fileSinkOptionsFromParams(params base.Params) (FileSinkOptions, error) {
+	options := FileSinkOptions{}
+	argsErr := base.NewArgsError()
+	var err error
+	if options.Path, err = params.String("path", ""); err != nil {
+		argsErr.Add("", err)
+	}
+	maxBytes, err := params.Int("maxBytes", 0)
+	if err != nil {
+		argsErr.Add("", err)
+	}
+	options.MaxBytes = int64(maxBytes)
+	if options.MaxItems, err = params.Int("maxItems", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.MaxAge, err = params.Duration("maxAge", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.SyncInterval, err = params.Duration("syncInterval", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	if options.BufferSize, err = params.Int("bufferSize", 0); err != nil {
+		argsErr.Add("", err)
+	}
+	return options, argsErr.ErrorOrNil()
+}
+
+// 按大小、条目数或时间轮转的带缓冲的文件。它不是并发安全的。
+type rotatingFile struct {
+	options  FileSinkOptions // 选项。
+	path     string          // 文件的路径或轮转文件的名称模板。
+	header   []byte          // 每个文件开头的内容。
+	current  string          // 当前文件的路径。
+	file     *os.File        // 当前的文件。
+	writer   *bufio.Writer   // 当前文件的缓冲区。
+	size     int64           // 当前文件的字节数。
+	count    int             // 当前文件的记录数。
+	openedAt time.Time       // 当前文件的打开时间。
+	seq      int             // 轮转文件的序号。
+}
+
+// 创建轮转文件。文件在第一次写入时才会被打开。
+func newRotatingFile(path string, options FileSinkOptions) *rotatingFile {
+	return &rotatingFile{options: options, path: path}
+}
+
+// 写入一条记录。达到轮转条件时，记录会被写到新的文件中。
+func (rf *rotatingFile) write(record []byte) error {
+	if rf.file != nil && rf.full(len(record)) {
+		if err := rf.close(); err != nil {
+			return err
+		}
+	}
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return err
+		}
+	}
+	n, err := rf.writer.Write(record)
+	rf.size += int64(n)
+	rf.count++
+	return err
+}
+
+// 判断写入给定长度的记录之前是否需要轮转文件。
+func (rf *rotatingFile) full(length int) bool {
+	options := rf.options
+	if options.MaxItems > 0 && rf.count >= options.MaxItems {
+		return true
+	}
+	if options.MaxBytes > 0 && rf.count > 0 && rf.size+int64(length) > options.MaxBytes {
+		return true
+	}
+	if options.MaxAge > 0 && time.Since(rf.openedAt) >= options.MaxAge {
+		return true
+	}
+	return false
+}
+
+// 打开新的文件并写入开头的内容。
+func (rf *rotatingFile) open() error {
+	path := rf.path
+	if rf.options.rotating() {
+		rf.seq++
+		ext := filepath.Ext(path)
+		path = fmt.Sprintf("%s-%s-%d%s", strings.TrimSuffix(path, ext),
+			time.Now().Format("20060102T150405"), rf.seq, ext)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := rf.openPath(path); err != nil {
+		return err
+	}
+	rf.count = 0
+	rf.openedAt = time.Now()
+	//追加到已有的文件时不再写入开头的内容
+	if len(rf.header) > 0 && rf.size == 0 {
+		n, err := rf.writer.Write(rf.header)
+		rf.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+// 以追加的方式打开给定路径的文件。
+func (rf *rotatingFile) openPath(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	bufferSize := rf.options.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultSinkBufferSize
+	}
+	rf.current = path
+	rf.file = file
+	rf.writer = bufio.NewWriterSize(file, bufferSize)
+	rf.size = info.Size()
+	return nil
+}
+
+// 用给定的函数重写当前的文件。函数从src读取原有的内容，并把新的内容写入dst。
+// 重写之后文件的记录数和打开时间保持不变。当前没有打开的文件时什么也不做。
+func (rf *rotatingFile) rewrite(transform func(src io.Reader, dst io.Writer) error) error {
+	if rf.file == nil {
+		return nil
+	}
+	if err := rf.sync(); err != nil {
+		return err
+	}
+	path := rf.current
+	rf.file.Close()
+	rf.file = nil
+	rf.writer = nil
+	err := rewriteFile(path, transform)
+	if openErr := rf.openPath(path); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+// 通过临时文件重写文件。
+func rewriteFile(path string, transform func(src io.Reader, dst io.Writer) error) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	err = transform(bufio.NewReader(src), writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 把缓冲的数据写入文件并同步到磁盘。
+func (rf *rotatingFile) sync() error {
+	if rf.file == nil {
+		return nil
+	}
+	if err := rf.writer.Flush(); err != nil {
+		return err
+	}
+	return rf.file.Sync()
+}
+
+// 同步并关闭当前的文件。之后的写入会打开新的文件。
+func (rf *rotatingFile) close() error {
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.sync()
+	if closeErr := rf.file.Close(); err == nil {
+		err = closeErr
+	}
+	rf.file = nil
+	rf.writer = nil
+	return err
+}
+
+// 定期调用给定的函数，直到返回的函数被调用。间隔时间为负数时什么也不做。
+func startSyncLoop(interval time.Duration, tick func()) (stop func()) {
+	if interval == 0 {
+		interval = defaultSinkSyncInterval
+	}
+	if interval < 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tick()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
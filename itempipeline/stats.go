@@ -0,0 +1,217 @@
+package itempipeline
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sys/fetch/base"
+)
+
+// 耗时直方图的各个桶的上界。最后一个桶没有上界。
+var latencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// 有名称的条目处理器的接口类型。条目处理管道用名称区分各个条目处理器的统计信息。
+type NamedProcessor interface {
+	ItemProcessor
+	// 获得条目处理器的名称。
+	Name() string
+}
+
+// 为条目处理器命名。被命名的条目处理器的其他能力(如批处理和关闭)会被条目处理管道保留。
+func Named(name string, processor ItemProcessor) ItemProcessor {
+	if IsNilProcessor(processor) {
+		return processor
+	}
+	return &myNamedProcessor{name: name, processor: processor}
+}
+
+// 有名称的条目处理器的实现类型。
+type myNamedProcessor struct {
+	name      string        // 名称。
+	processor ItemProcessor // 被命名的条目处理器。
+}
+
+func (np *myNamedProcessor) Process(item base.Item) (base.Item, error) {
+	return np.processor.Process(item)
+}
+
+func (np *myNamedProcessor) Name() string {
+	return np.name
+}
+
+// 获得被命名的条目处理器。
+func (np *myNamedProcessor) Unwrap() ItemProcessor {
+	return np.processor
+}
+
+// 去掉条目处理器的命名，获得其本身。
+func unwrapProcessor(processor ItemProcessor) ItemProcessor {
+	for {
+		np, ok := processor.(*myNamedProcessor)
+		if !ok {
+			return processor
+		}
+		processor = np.processor
+	}
+}
+
+// 获得条目处理器的名称。没有名称的函数使用其函数名，其他的使用其类型名。
+func processorName(processor ItemProcessor) string {
+	if np, ok := processor.(NamedProcessor); ok && np.Name() != "" {
+		return np.Name()
+	}
+	if f, ok := processor.(ProcessItem); ok {
+		if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+			name := fn.Name()
+			return name[strings.LastIndex(name, "/")+1:]
+		}
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", processor), "*")
+}
+
+// 为条目处理器生成互不相同的名称。重复的名称会被加上"#序号"。
+func processorNames(processors []ItemProcessor) []string {
+	names := make([]string, len(processors))
+	seen := make(map[string]int)
+	for i, processor := range processors {
+		name := processorName(processor)
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// 耗时直方图的桶。
+type LatencyBucket struct {
+	UpperBound time.Duration // 耗时的上界(包含)。为0时表示没有上界。
+	Count      uint64        // 耗时落在该桶中的调用的次数。
+}
+
+// 单个条目处理器的统计信息。
+type ProcessorStats struct {
+	Name        string          // 条目处理器的名称。
+	Invocations uint64          // 调用的次数。
+	Errors      uint64          // 返回错误(不包括丢弃)的次数。
+	Drops       uint64          // 丢弃条目的次数。
+	TotalTime   time.Duration   // 总耗时。
+	MaxTime     time.Duration   // 单次调用的最长耗时。
+	Histogram   []LatencyBucket // 耗时的直方图。
+}
+
+// 获得单次调用的平均耗时。
+func (stats ProcessorStats) MeanTime() time.Duration {
+	if stats.Invocations == 0 {
+		return 0
+	}
+	return stats.TotalTime / time.Duration(stats.Invocations)
+}
+
+// 估计耗时的分位数，q的取值范围是[0, 1]。结果为分位数所在的桶的上界，最后一个桶使用最长耗时。
+func (stats ProcessorStats) Quantile(q float64) time.Duration {
+	if stats.Invocations == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(stats.Invocations) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var count uint64
+	for _, bucket := range stats.Histogram {
+		count += bucket.Count
+		if count >= rank {
+			if bucket.UpperBound == 0 || bucket.UpperBound > stats.MaxTime {
+				return stats.MaxTime
+			}
+			return bucket.UpperBound
+		}
+	}
+	return stats.MaxTime
+}
+
+func (stats ProcessorStats) String() string {
+	return fmt.Sprintf("%s: calls=%d, errors=%d, drops=%d, mean=%s, p99=%s, max=%s",
+		stats.Name, stats.Invocations, stats.Errors, stats.Drops,
+		stats.MeanTime(), stats.Quantile(0.99), stats.MaxTime)
+}
+
+// 单个条目处理器的统计信息的记录器。它是并发安全的。
+type processorRecorder struct {
+	name        string   // 条目处理器的名称。
+	invocations uint64   // 调用的次数。
+	errors      uint64   // 返回错误的次数。
+	drops       uint64   // 丢弃条目的次数。
+	totalNanos  uint64   // 总耗时的纳秒数。
+	maxNanos    uint64   // 最长耗时的纳秒数。
+	buckets     []uint64 // 直方图的各个桶的计数。
+}
+
+// 创建统计信息的记录器。
+func newProcessorRecorder(name string) *processorRecorder {
+	return &processorRecorder{name: name, buckets: make([]uint64, len(latencyBounds)+1)}
+}
+
+// 记录一次调用。
+func (rec *processorRecorder) record(elapsed time.Duration, err error) {
+	atomic.AddUint64(&rec.invocations, 1)
+	if IsDropped(err) {
+		atomic.AddUint64(&rec.drops, 1)
+	} else if err != nil {
+		atomic.AddUint64(&rec.errors, 1)
+	}
+	nanos := uint64(elapsed)
+	atomic.AddUint64(&rec.totalNanos, nanos)
+	for {
+		max := atomic.LoadUint64(&rec.maxNanos)
+		if nanos <= max || atomic.CompareAndSwapUint64(&rec.maxNanos, max, nanos) {
+			break
+		}
+	}
+	i := 0
+	for i < len(latencyBounds) && elapsed > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&rec.buckets[i], 1)
+}
+
+// 获得统计信息的快照。
+func (rec *processorRecorder) stats() ProcessorStats {
+	stats := ProcessorStats{
+		Name:        rec.name,
+		Invocations: atomic.LoadUint64(&rec.invocations),
+		Errors:      atomic.LoadUint64(&rec.errors),
+		Drops:       atomic.LoadUint64(&rec.drops),
+		TotalTime:   time.Duration(atomic.LoadUint64(&rec.totalNanos)),
+		MaxTime:     time.Duration(atomic.LoadUint64(&rec.maxNanos)),
+		Histogram:   make([]LatencyBucket, len(rec.buckets)),
+	}
+	for i := range rec.buckets {
+		stats.Histogram[i].Count = atomic.LoadUint64(&rec.buckets[i])
+		if i < len(latencyBounds) {
+			stats.Histogram[i].UpperBound = latencyBounds[i]
+		}
+	}
+	return stats
+}
+
+// 把各个条目处理器的统计信息写成一行，用于摘要信息。
+func formatProcessorStats(statsList []ProcessorStats) string {
+	parts := make([]string, len(statsList))
+	for i, stats := range statsList {
+		parts[i] = stats.String()
+	}
+	return strings.Join(parts, "; ")
+}
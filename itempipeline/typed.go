@@ -0,0 +1,63 @@
+package itempipeline
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"sys/fetch/base"
+)
+
+// 创建处理类型化条目的条目处理器。条目被转换为T(见base.DecodeItem)之后交给process，
+// 结果再被写回条目(见base.MergeItem)，T之外的字段会被保留。
+// 若T实现了base.ItemTyper，类型不同的条目会被原样放过。
+// 转换失败时返回*base.ItemConversionError，process返回的错误(包括ErrDropItem)会被原样返回。
+func Typed[T any](process func(value T) (T, error)) ItemProcessor {
+	return &myTypedProcessor[T]{
+		process:  process,
+		itemType: typedItemType[T](),
+		name:     fmt.Sprintf("typed[%s]", reflect.TypeOf((*T)(nil)).Elem()),
+	}
+}
+
+// 类型化条目处理器的实现类型。
+type myTypedProcessor[T any] struct {
+	process  func(value T) (T, error) // 处理类型化条目的函数。
+	itemType string                   // 被处理的条目的类型。为空时处理所有条目。
+	name     string                   // 名称。
+}
+
+func (tp *myTypedProcessor[T]) Process(item base.Item) (base.Item, error) {
+	if item == nil {
+		return nil, errors.New("Invalid item!")
+	}
+	if tp.itemType != "" {
+		if itemType, _ := item[base.ITEM_TYPE_KEY].(string); itemType != tp.itemType {
+			return item, nil
+		}
+	}
+	value, err := base.ItemAs[T](item)
+	if err != nil {
+		return nil, err
+	}
+	if value, err = tp.process(value); err != nil {
+		return nil, err
+	}
+	return base.MergeItem(item, value)
+}
+
+func (tp *myTypedProcessor[T]) Name() string {
+	return tp.name
+}
+
+// 获得T所说明的条目类型。T没有实现base.ItemTyper时返回空字符串。
+func typedItemType[T any]() string {
+	value := new(T)
+	if typer, ok := any(*value).(base.ItemTyper); ok {
+		return typer.ItemType()
+	}
+	if typer, ok := any(value).(base.ItemTyper); ok {
+		return typer.ItemType()
+	}
+	return ""
+}
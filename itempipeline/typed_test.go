@@ -0,0 +1,143 @@
+package itempipeline
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+)
+
+type testOffer struct {
+	Price    float64 `item:"price"`
+	Currency string  `item:"currency,omitempty"`
+}
+
+type testSource struct {
+	Url   *url.URL `item:"url"`
+	Depth uint32   `item:"depth"`
+}
+
+type testProduct struct {
+	testSource
+	Name     string      `item:"name"`
+	Stock    int         `json:"stock"`
+	Added    time.Time   `item:"added"`
+	Tags     []string    `item:"tags,omitempty"`
+	Offers   []testOffer `item:"offers"`
+	Featured *bool       `item:"featured,omitempty"`
+	Internal string      `item:"-"`
+}
+
+func (p testProduct) ItemType() string {
+	return "product"
+}
+
+func TestTypedItemRoundTrip(t *testing.T) {
+	item := base.Item{
+		"_type":  "product",
+		"name":   "Tea",
+		"stock":  "12",
+		"added":  "2024-05-01",
+		"url":    "http://example.com/tea",
+		"depth":  float64(2),
+		"tags":   []interface{}{"green", "loose"},
+		"offers": []interface{}{map[string]interface{}{"price": 4.5, "currency": "EUR"}},
+		"extra":  true,
+	}
+	product, err := base.ItemAs[testProduct](item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Name != "Tea" || product.Stock != 12 || product.Depth != 2 ||
+		product.Url.Host != "example.com" || !product.Added.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) ||
+		!reflect.DeepEqual(product.Tags, []string{"green", "loose"}) ||
+		!reflect.DeepEqual(product.Offers, []testOffer{{Price: 4.5, Currency: "EUR"}}) {
+		t.Errorf("Unexpected product %+v!", product)
+	}
+
+	product.Tags = nil
+	product.Offers = append(product.Offers, testOffer{Price: 5})
+	encoded, err := base.EncodeItem(product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded["_type"] != "product" || encoded["stock"] != 12 || encoded["url"] != product.Url {
+		t.Errorf("Unexpected encoded item %v!", encoded)
+	}
+	if _, ok := encoded["tags"]; ok {
+		t.Errorf("The empty tags should be omitted, but got %v!", encoded["tags"])
+	}
+	offers := []interface{}{
+		map[string]interface{}{"price": 4.5, "currency": "EUR"},
+		map[string]interface{}{"price": float64(5)},
+	}
+	if !reflect.DeepEqual(encoded["offers"], offers) {
+		t.Errorf("Unexpected encoded offers %v!", encoded["offers"])
+	}
+	merged, err := base.MergeItem(item, product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged["extra"] != true || merged["tags"] != nil || item["tags"] == nil {
+		t.Errorf("Unexpected merged item %v!", merged)
+	}
+}
+
+func TestTypedItemErrors(t *testing.T) {
+	_, err := base.ItemAs[testProduct](base.Item{
+		"name":   []interface{}{"a"},
+		"stock":  2.5,
+		"offers": []interface{}{map[string]interface{}{"price": "cheap"}},
+	})
+	convErr, ok := err.(*base.ItemConversionError)
+	if !ok {
+		t.Fatalf("Expected a conversion error, but got %v!", err)
+	}
+	for _, field := range []string{"name:", "stock:", "offers[0].price:"} {
+		if !strings.Contains(convErr.Error(), field) {
+			t.Errorf("Expected the error of '%s' in %s!", field, convErr)
+		}
+	}
+	if _, err := base.EncodeItem("tea"); err == nil {
+		t.Errorf("Expected an error for encoding a string!")
+	}
+}
+
+func TestTypedProcessor(t *testing.T) {
+	processor := Typed(func(p testProduct) (testProduct, error) {
+		if p.Stock == 0 {
+			return p, ErrDropItem
+		}
+		if p.Name == "" {
+			return p, errors.New("unnamed")
+		}
+		p.Name = strings.ToUpper(p.Name)
+		return p, nil
+	})
+	result, err := processor.Process(base.Item{"_type": "product", "name": "tea", "stock": 1, "note": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["name"] != "TEA" || result["note"] != "x" {
+		t.Errorf("Unexpected result %v!", result)
+	}
+	//其他类型的条目被原样放过
+	article := base.Item{"_type": "article", "stock": "many"}
+	if result, err := processor.Process(article); err != nil || !reflect.DeepEqual(result, article) {
+		t.Errorf("Unexpected result %v and error %v!", result, err)
+	}
+	if _, err := processor.Process(base.Item{"_type": "product", "stock": 0}); !IsDropped(err) {
+		t.Errorf("Expected the item to be dropped, but got %v!", err)
+	}
+	var convErr *base.ItemConversionError
+	if _, err := processor.Process(base.Item{"_type": "product", "stock": "many"}); !errors.As(err, &convErr) {
+		t.Errorf("Expected a conversion error, but got %v!", err)
+	}
+	if name := processorName(processor); name != "typed[itempipeline.testProduct]" {
+		t.Errorf("Unexpected processor name %s!", name)
+	}
+}
@@ -47,6 +47,8 @@ func (ss *myStopSign) Sign() bool {
 }
 
 func (ss *myStopSign) Signed() bool {
+	ss.rwmutex.RLock()
+	defer ss.rwmutex.RUnlock()
 	return ss.signed
 }
 
@@ -87,6 +89,8 @@ func (ss *myStopSign) DealTotal() uint32 {
 }
 
 func (ss *myStopSign) Summary() string {
+	ss.rwmutex.RLock()
+	defer ss.rwmutex.RUnlock()
 	if ss.signed {
 		return fmt.Sprintf("signed: true, dealCount: %v", ss.dealCountMap)
 	} else {
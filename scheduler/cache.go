@@ -25,6 +25,8 @@ type requestCache interface {
 	close()
 	//获取请求缓存的摘要信息
 	summary() string
+	//获取请求缓存中所有请求的快照。请求缓存被关闭之后依然可用
+	snapshot() []*base.Request
 }
 
 //创建请求缓存
@@ -84,6 +86,14 @@ func (rcache *reqCacheBySlice) close() {
 	rcache.status = 1
 }
 
+func (rcache *reqCacheBySlice) snapshot() []*base.Request {
+	rcache.mutex.Lock()
+	defer rcache.mutex.Unlock()
+	reqs := make([]*base.Request, len(rcache.cache))
+	copy(reqs, rcache.cache)
+	return reqs
+}
+
 //摘要信息模板
 var summaryTemplate = "status: %s," + "length: %d," + "capacity: %d"
 
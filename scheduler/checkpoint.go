@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sys/fetch/base"
+	"time"
+)
+
+// 调度器的检查点。它记录了尚未完成的请求和已请求过的URL，可被用来恢复爬取。
+type Checkpoint struct {
+	CreatedAt time.Time           `json:"createdAt"` // 创建时间。
+	Pending   []CheckpointRequest `json:"pending"`   // 尚未完成的请求。
+	Seen      []string            `json:"seen"`      // 已请求过的URL。
+}
+
+// 检查点中的请求。
+type CheckpointRequest struct {
+	Method string        `json:"method"`           // HTTP方法。
+	Url    string        `json:"url"`              // URL。
+	Header http.Header   `json:"header,omitempty"` // 请求头。
+	Depth  uint32        `json:"depth"`            // 深度值。
+	Meta   base.Metadata `json:"meta,omitempty"`   // 元数据。
+}
+
+// 设定恢复爬取所用的检查点。
+// 检查点中尚未完成的请求会被放入请求缓存，已请求过的URL不会被再次请求。
+func WithCheckpoint(checkpoint *Checkpoint) ConfigOption {
+	return func(config *Config) {
+		config.checkpoint = checkpoint
+	}
+}
+
+// 根据请求生成检查点中的请求。
+func newCheckpointRequest(req *base.Request) CheckpointRequest {
+	httpReq := req.HttpReq()
+	return CheckpointRequest{
+		Method: httpReq.Method,
+		Url:    httpReq.URL.String(),
+		Header: httpReq.Header,
+		Depth:  req.Depth(),
+		Meta:   req.Meta(),
+	}
+}
+
+// 还原出请求。
+func (cr *CheckpointRequest) request() (*base.Request, error) {
+	method := cr.Method
+	if method == "" {
+		method = "GET"
+	}
+	httpReq, err := http.NewRequest(method, cr.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range cr.Header {
+		httpReq.Header[name] = values
+	}
+	return base.NewRequestWithMeta(httpReq, cr.Depth, cr.Meta.Clone()), nil
+}
+
+// 检查检查点的有效性。
+func (cp *Checkpoint) Check() error {
+	argsErr := base.NewArgsError()
+	for i := range cp.Pending {
+		if _, err := cp.Pending[i].request(); err != nil {
+			argsErr.Add(fmt.Sprintf("pending[%d]", i), err)
+		}
+	}
+	for i, u := range cp.Seen {
+		if u == "" {
+			argsErr.Add(fmt.Sprintf("seen[%d]", i), errors.New("The url is empty!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+func (cp *Checkpoint) String() string {
+	return fmt.Sprintf("{ createdAt: %s, pending: %d, seen: %d }",
+		cp.CreatedAt.Format(time.RFC3339), len(cp.Pending), len(cp.Seen))
+}
@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sys/fetch/base"
+	ipl "sys/fetch/itempipeline"
+	mdw "sys/fetch/middleware"
+)
+
+// 用于测试的爬取。页面的内容即其路径，每个响应生成一个记录了路径的条目。
+type testCrawl struct {
+	server *httptest.Server
+	paths  []string
+	mutex  sync.Mutex
+}
+
+func newTestCrawl() *testCrawl {
+	crawl := &testCrawl{paths: make([]string, 0)}
+	crawl.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+	}))
+	return crawl
+}
+
+func (crawl *testCrawl) url(path string) string {
+	return crawl.server.URL + path
+}
+
+// 开启调度器，在收到count个条目并且调度器空闲之后停止它。
+func (crawl *testCrawl) run(t *testing.T, count int, options ...ConfigOption) Scheduler {
+	received := make(chan struct{}, count)
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		item := base.Item{"path": httpResp.Request.URL.Path}
+		return []base.Data{&item}, nil
+	}
+	collect := func(item base.Item) (base.Item, error) {
+		crawl.mutex.Lock()
+		crawl.paths = append(crawl.paths, item["path"].(string))
+		crawl.mutex.Unlock()
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		return item, nil
+	}
+	config := NewConfig(
+		WithChannelArgs(base.NewChannelArgs(10, 10, 10, 10)),
+		WithPoolBaseArgs(base.NewPoolBaseArgs(3, 3)),
+		WithCrawlDepth(2),
+		WithHttpClientGenerator(func() *http.Client { return &http.Client{} }),
+		WithRespParsers(parser),
+		WithItemProcessors(ipl.Processors(collect)...))
+	config.Apply(options...)
+	scheduler := NewScheduler()
+	if err := scheduler.StartWithConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			scheduler.Stop()
+			t.Fatalf("Timeout waiting for the item %d!", i+1)
+		}
+	}
+	for i := 0; i < 100 && !scheduler.Idle(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !scheduler.Stop() {
+		t.Fatal("The scheduler should be running!")
+	}
+	return scheduler
+}
+
+// 获得已收到的条目中的路径，按字典序排列。
+func (crawl *testCrawl) crawled() []string {
+	crawl.mutex.Lock()
+	defer crawl.mutex.Unlock()
+	paths := append([]string{}, crawl.paths...)
+	sort.Strings(paths)
+	return paths
+}
+
+func (crawl *testCrawl) close() {
+	crawl.server.Close()
+}
+
+// 从检查点恢复爬取：已请求过的URL被跳过，只有尚未完成的请求被下载。
+func TestStartWithCheckpoint(t *testing.T) {
+	crawl := newTestCrawl()
+	defer crawl.close()
+	seed, _ := http.NewRequest("GET", crawl.url("/"), nil)
+	checkpoint := &Checkpoint{
+		CreatedAt: time.Now(),
+		Pending: []CheckpointRequest{
+			{Method: "GET", Url: crawl.url("/b"), Depth: 1,
+				Meta: base.Metadata{base.META_SEED_ID: crawl.url("/")}},
+		},
+		Seen: []string{crawl.url("/"), crawl.url("/a"), crawl.url("/b")},
+	}
+	scheduler := crawl.run(t, 1, WithSeeds(seed), WithCheckpoint(checkpoint))
+
+	if paths := crawl.crawled(); fmt.Sprint(paths) != "[/b]" {
+		t.Errorf("Expected only the pending request /b to be crawled, but got %v!", paths)
+	}
+	state := scheduler.Checkpoint()
+	if len(state.Pending) != 0 {
+		t.Errorf("Expected no pending request, but got %d!", len(state.Pending))
+	}
+	sort.Strings(state.Seen)
+	expected := []string{crawl.url("/"), crawl.url("/a"), crawl.url("/b")}
+	if fmt.Sprint(state.Seen) != fmt.Sprint(expected) {
+		t.Errorf("Expected the seen urls %v, but got %v!", expected, state.Seen)
+	}
+}
+
+func TestCheckpointRequestRoundTrip(t *testing.T) {
+	httpReq, _ := http.NewRequest("POST", "http://example.com/search?q=tea", nil)
+	httpReq.Header.Set("Accept", "application/json")
+	req := base.NewRequestWithMeta(httpReq, 3, base.Metadata{
+		base.META_SEED_ID:      "http://example.com/",
+		base.META_IGNORE_DEPTH: true,
+	})
+	data, err := json.Marshal(newCheckpointRequest(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &CheckpointRequest{}
+	if err := json.Unmarshal(data, cr); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := cr.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoredReq := restored.HttpReq()
+	if restoredReq.Method != "POST" || restoredReq.URL.String() != "http://example.com/search?q=tea" ||
+		restoredReq.Header.Get("Accept") != "application/json" || restored.Depth() != 3 ||
+		restored.Meta().String(base.META_SEED_ID) != "http://example.com/" ||
+		!restored.Meta().Bool(base.META_IGNORE_DEPTH) {
+		t.Errorf("Unexpected restored request %s (depth=%d, meta=%v)!",
+			restoredReq.URL, restored.Depth(), restored.Meta())
+	}
+	//还原出的请求的元数据是副本
+	restored.SetMeta("x", 1)
+	if _, ok := cr.Meta["x"]; ok {
+		t.Errorf("The metadata should be copied!")
+	}
+
+	invalid := &Checkpoint{Pending: []CheckpointRequest{{Url: "://"}}, Seen: []string{""}}
+	if err := invalid.Check(); err == nil ||
+		!strings.Contains(err.Error(), "pending[0]") || !strings.Contains(err.Error(), "seen[0]") {
+		t.Errorf("Expected errors for pending[0] and seen[0], but got %v!", err)
+	}
+}
+
+// 调度器生成的检查点经JSON编码和解码之后被用来恢复爬取：
+// 请求缓存中的和正在被下载的请求被重新下载，已请求过的URL被跳过。
+func TestCheckpointRoundTrip(t *testing.T) {
+	crawl := newTestCrawl()
+	defer crawl.close()
+	seed, _ := http.NewRequest("GET", crawl.url("/"), nil)
+	config := NewConfig(WithSeeds(seed))
+	sched := &myScheduler{
+		crawlDepth: 2,
+		stopSign:   mdw.NewStopSign(),
+		reqCache:   newRequestCache(),
+		urlMap:     make(map[string]bool),
+		inFlight:   make(map[string]*base.Request),
+	}
+	cs, err := newCrawlScope(nil, scopeSeeds(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.scope = cs
+	sched.putInitialRequests(config)
+	//模拟爬取中断时的状态：种子已被下载，/a正在被下载，/b在请求缓存中
+	sched.reqCache.get()
+	for _, path := range []string{"/a", "/b"} {
+		httpReq, _ := http.NewRequest("GET", crawl.url(path), nil)
+		req := base.NewRequestWithMeta(httpReq, 1, base.Metadata{base.META_SEED_ID: crawl.url("/")})
+		if !sched.saveReqToCache(*req, SCHEDULER_CODE) {
+			t.Fatalf("Failed to save the request %s!", path)
+		}
+	}
+	sched.markInFlight(sched.reqCache.get())
+
+	data, err := json.Marshal(sched.Checkpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoint.Pending) != 2 || len(checkpoint.Seen) != 3 {
+		t.Fatalf("Unexpected checkpoint %s!", checkpoint)
+	}
+
+	crawl.run(t, 2, WithSeeds(seed), WithCheckpoint(checkpoint))
+	if paths := crawl.crawled(); fmt.Sprint(paths) != "[/a /b]" {
+		t.Errorf("Expected the pending requests /a and /b to be crawled, but got %v!", paths)
+	}
+}
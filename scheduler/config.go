@@ -0,0 +1,348 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+	ipl "sys/fetch/itempipeline"
+)
+
+// 调度器配置项的函数类型。每个配置项负责设定配置中的一部分内容。
+type ConfigOption func(config *Config)
+
+// 调度器的配置。它汇集了开启调度器所需的全部参数。
+// 新的设定应以新的配置项的形式加入，而不是再为Start方法增加参数。
+type Config struct {
+	channelArgs         base.ChannelArgs     // 通道参数的容器。
+	poolBaseArgs        base.PoolBaseArgs    // 池基本参数的容器。
+	crawlDepth          uint32               // 需要被爬取的网页的最大深度。
+	httpClientGenerator GenHttpClient        // 生成HTTP客户端的函数。
+	respParsers         []anlz.ParseResponse // 响应解析函数的序列。
+	namedParsers        anlz.NamedParsers    // 请求可以通过名称引用的响应解析函数实例。
+	itemProcessors      []ipl.ItemProcessor  // 条目处理器的序列。
+	seeds               []*http.Request      // 种子请求的序列。
+	scope               *Scope               // 爬取范围的规则。
+	checkpoint          *Checkpoint          // 恢复爬取所用的检查点。
+	sitemapDiscovery    bool                 // 是否发现站点地图。
+	directives          anlz.Directives      // 分析器遵守的页面指令。
+	nearDuplicates      bool                 // 是否检测近似重复的页面。
+	nearDuplicateDist   int                  // 近似重复的页面的指纹间的最大海明距离。
+	itemWorkers         uint32               // 处理条目的工作协程的数量。
+	itemBatchSize       int                  // 条目批次的最大条目数。为0时不启用批处理。
+	itemBatchWait       time.Duration        // 条目批次的最长等待时间。
+	deadLetterStore     DeadLetterStore      // 死信存储。
+	deadLetters         []*DeadLetter        // 需要重新投递的死信。
+}
+
+// 创建调度器的配置。
+func NewConfig(options ...ConfigOption) *Config {
+	config := &Config{}
+	return config.Apply(options...)
+}
+
+// 应用配置项。
+func (config *Config) Apply(options ...ConfigOption) *Config {
+	for _, option := range options {
+		if option != nil {
+			option(config)
+		}
+	}
+	return config
+}
+
+// 设定通道参数的容器。
+func WithChannelArgs(channelArgs base.ChannelArgs) ConfigOption {
+	return func(config *Config) {
+		config.channelArgs = channelArgs
+	}
+}
+
+// 设定池基本参数的容器。
+func WithPoolBaseArgs(poolBaseArgs base.PoolBaseArgs) ConfigOption {
+	return func(config *Config) {
+		config.poolBaseArgs = poolBaseArgs
+	}
+}
+
+// 设定需要被爬取的网页的最大深度。
+func WithCrawlDepth(crawlDepth uint32) ConfigOption {
+	return func(config *Config) {
+		config.crawlDepth = crawlDepth
+	}
+}
+
+// 设定生成HTTP客户端的函数。
+func WithHttpClientGenerator(httpClientGenerator GenHttpClient) ConfigOption {
+	return func(config *Config) {
+		config.httpClientGenerator = httpClientGenerator
+	}
+}
+
+// 追加响应解析函数。
+func WithRespParsers(respParsers ...anlz.ParseResponse) ConfigOption {
+	return func(config *Config) {
+		if config.respParsers == nil {
+			config.respParsers = make([]anlz.ParseResponse, 0, len(respParsers))
+		}
+		config.respParsers = append(config.respParsers, respParsers...)
+	}
+}
+
+// 追加请求可以通过名称引用的响应解析函数实例，名称相同时后者为准。
+// 实例在开启调度器之前生成，所有分析器共用它们，见anlz.WithNamedParsers。
+func WithNamedParsers(parsers anlz.NamedParsers) ConfigOption {
+	return func(config *Config) {
+		if config.namedParsers == nil {
+			config.namedParsers = make(anlz.NamedParsers, len(parsers))
+		}
+		for name, parser := range parsers {
+			config.namedParsers[name] = parser
+		}
+	}
+}
+
+// 追加条目处理器。
+func WithItemProcessors(itemProcessors ...ipl.ItemProcessor) ConfigOption {
+	return func(config *Config) {
+		if config.itemProcessors == nil {
+			config.itemProcessors = make([]ipl.ItemProcessor, 0, len(itemProcessors))
+		}
+		config.itemProcessors = append(config.itemProcessors, itemProcessors...)
+	}
+}
+
+// 设定处理条目的工作协程的数量。未设定时与分析器池的大小相同。
+// 条目通道满时，分析器会等待工作协程取走条目。
+func WithItemWorkers(workers uint32) ConfigOption {
+	return func(config *Config) {
+		config.itemWorkers = workers
+	}
+}
+
+// 为实现了ipl.BatchProcessor的条目处理器启用批处理，见ipl.WithBatching。
+// 处理条目的工作协程的数量不应少于size。
+func WithItemBatching(size int, wait time.Duration) ConfigOption {
+	return func(config *Config) {
+		config.itemBatchSize = size
+		config.itemBatchWait = wait
+	}
+}
+
+// 追加种子请求。调度器会以这些请求为起始点开始执行爬取流程。
+func WithSeeds(seeds ...*http.Request) ConfigOption {
+	return func(config *Config) {
+		config.seeds = append(config.seeds, seeds...)
+	}
+}
+
+// 检查配置的有效性。所有的问题都会被汇总在同一个错误中。
+func (config *Config) Check() error {
+	argsErr := base.NewArgsError()
+	argsErr.Add("channelArgs", config.channelArgs.Check())
+	argsErr.Add("poolBaseArgs", config.poolBaseArgs.Check())
+	if config.httpClientGenerator == nil {
+		argsErr.Add("httpClientGenerator",
+			errors.New("The HTTP client generator is invalid!"))
+	}
+	if config.respParsers == nil {
+		argsErr.Add("respParsers", errors.New("The response parser list is invalid!"))
+	}
+	for i, rp := range config.respParsers {
+		if rp == nil {
+			argsErr.Add(fmt.Sprintf("respParsers[%d]", i),
+				errors.New("The response parser is invalid!"))
+		}
+	}
+	argsErr.Add("namedParsers", config.namedParsers.Check())
+	if config.itemProcessors == nil {
+		argsErr.Add("itemProcessors", errors.New("The item processor list is invalid!"))
+	}
+	for i, ip := range config.itemProcessors {
+		if ipl.IsNilProcessor(ip) {
+			argsErr.Add(fmt.Sprintf("itemProcessors[%d]", i),
+				errors.New("The item processor is invalid!"))
+		}
+	}
+	if len(config.seeds) == 0 && len(config.deadLetters) == 0 {
+		argsErr.Add("seeds", errors.New("The seed request list is empty!"))
+	}
+	for i, seed := range config.seeds {
+		field := fmt.Sprintf("seeds[%d]", i)
+		if seed == nil || seed.URL == nil {
+			argsErr.Add(field, errors.New("The seed request is invalid!"))
+			continue
+		}
+		if _, err := getPrimaryDomain(seed.Host); err != nil {
+			argsErr.Add(field, err)
+		}
+	}
+	if config.scope != nil {
+		argsErr.Add("scope", config.scope.Check())
+	}
+	if config.checkpoint != nil {
+		argsErr.Add("checkpoint", config.checkpoint.Check())
+	}
+	if config.nearDuplicates {
+		if _, err := anlz.NewSimHashIndex(config.nearDuplicateDist); err != nil {
+			argsErr.Add("nearDuplicates", err)
+		}
+	}
+	if config.itemBatchSize != 0 || config.itemBatchWait != 0 {
+		argsErr.Add("itemBatching", ipl.CheckBatching(config.itemBatchSize, config.itemBatchWait))
+	}
+	for i, letter := range config.deadLetters {
+		field := fmt.Sprintf("deadLetters[%d]", i)
+		if letter == nil {
+			argsErr.Add(field, errors.New("The dead letter is invalid!"))
+			continue
+		}
+		argsErr.Add(field, letter.Check())
+	}
+	return argsErr.ErrorOrNil()
+}
+
+func (config *Config) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("{ channelArgs: %s, poolBaseArgs: %s, crawlDepth: %d,"+
+		" respParsers: %d, itemProcessors: %d, seeds: [",
+		config.channelArgs.String(),
+		config.poolBaseArgs.String(),
+		config.crawlDepth,
+		len(config.respParsers),
+		len(config.itemProcessors)))
+	for i, seed := range config.seeds {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		if seed != nil && seed.URL != nil {
+			buffer.WriteString(seed.URL.String())
+		}
+	}
+	buffer.WriteString("]")
+	if config.scope != nil {
+		buffer.WriteString(", scope: ")
+		buffer.WriteString(config.scope.String())
+	}
+	if config.checkpoint != nil {
+		buffer.WriteString(", checkpoint: ")
+		buffer.WriteString(config.checkpoint.String())
+	}
+	if len(config.namedParsers) > 0 {
+		buffer.WriteString(fmt.Sprintf(", namedParsers: %d", len(config.namedParsers)))
+	}
+	if config.sitemapDiscovery {
+		buffer.WriteString(", sitemapDiscovery: true")
+	}
+	if config.directives != (anlz.Directives{}) {
+		buffer.WriteString(fmt.Sprintf(", directives: %+v", config.directives))
+	}
+	if config.nearDuplicates {
+		buffer.WriteString(fmt.Sprintf(", nearDuplicates: %d", config.nearDuplicateDist))
+	}
+	if config.itemWorkers > 0 {
+		buffer.WriteString(fmt.Sprintf(", itemWorkers: %d", config.itemWorkers))
+	}
+	if config.itemBatchSize > 0 {
+		buffer.WriteString(fmt.Sprintf(", itemBatching: %d/%s", config.itemBatchSize, config.itemBatchWait))
+	}
+	if config.deadLetterStore != nil {
+		buffer.WriteString(", deadLetterStore: true")
+	}
+	if len(config.deadLetters) > 0 {
+		buffer.WriteString(fmt.Sprintf(", deadLetters: %d", len(config.deadLetters)))
+	}
+	buffer.WriteString(" }")
+	return buffer.String()
+}
+
+// 获得通道参数的容器。
+func (config *Config) ChannelArgs() base.ChannelArgs {
+	return config.channelArgs
+}
+
+// 获得池基本参数的容器。
+func (config *Config) PoolBaseArgs() base.PoolBaseArgs {
+	return config.poolBaseArgs
+}
+
+// 获得需要被爬取的网页的最大深度。
+func (config *Config) CrawlDepth() uint32 {
+	return config.crawlDepth
+}
+
+// 获得生成HTTP客户端的函数。
+func (config *Config) HttpClientGenerator() GenHttpClient {
+	return config.httpClientGenerator
+}
+
+// 获得响应解析函数的序列。
+func (config *Config) RespParsers() []anlz.ParseResponse {
+	return config.respParsers
+}
+
+// 获得请求可以通过名称引用的响应解析函数实例。
+func (config *Config) NamedParsers() anlz.NamedParsers {
+	return config.namedParsers
+}
+
+// 获得条目处理器的序列。
+func (config *Config) ItemProcessors() []ipl.ItemProcessor {
+	return config.itemProcessors
+}
+
+// 获得种子请求的序列。
+func (config *Config) Seeds() []*http.Request {
+	return config.seeds
+}
+
+// 获得爬取范围的规则。
+func (config *Config) Scope() *Scope {
+	return config.scope
+}
+
+// 获得恢复爬取所用的检查点。
+func (config *Config) Checkpoint() *Checkpoint {
+	return config.checkpoint
+}
+
+// 获得是否发现站点地图。
+func (config *Config) SitemapDiscovery() bool {
+	return config.sitemapDiscovery
+}
+
+// 获得分析器遵守的页面指令。
+func (config *Config) Directives() anlz.Directives {
+	return config.directives
+}
+
+// 获得近似重复的页面的指纹间的最大海明距离，以及是否检测近似重复的页面。
+func (config *Config) NearDuplicates() (int, bool) {
+	return config.nearDuplicateDist, config.nearDuplicates
+}
+
+// 获得处理条目的工作协程的数量。
+func (config *Config) ItemWorkers() uint32 {
+	if config.itemWorkers == 0 {
+		return config.poolBaseArgs.AnalyzerPoolSize()
+	}
+	return config.itemWorkers
+}
+
+// 获得条目批次的最大条目数和最长等待时间，以及是否启用了批处理。
+func (config *Config) ItemBatching() (int, time.Duration, bool) {
+	return config.itemBatchSize, config.itemBatchWait, config.itemBatchSize > 0
+}
+
+// 获得死信存储。
+func (config *Config) DeadLetterStore() DeadLetterStore {
+	return config.deadLetterStore
+}
+
+// 获得需要重新投递的死信。
+func (config *Config) DeadLetters() []*DeadLetter {
+	return config.deadLetters
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+	ipl "sys/fetch/itempipeline"
+)
+
+// 获得有效的配置所需的配置项。
+func validConfigOptions() []ConfigOption {
+	seed, _ := http.NewRequest("GET", "http://example.com/", nil)
+	return []ConfigOption{
+		WithChannelArgs(base.NewChannelArgs(10, 10, 10, 10)),
+		WithPoolBaseArgs(base.NewPoolBaseArgs(3, 4)),
+		WithCrawlDepth(1),
+		WithHttpClientGenerator(func() *http.Client { return &http.Client{} }),
+		WithRespParsers(func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			return nil, nil
+		}),
+		WithItemProcessors(ipl.Processors(ipl.CopyItem)...),
+		WithSeeds(seed),
+	}
+}
+
+// 获得参数检查错误中的字段路径。
+func errorFields(err error) []string {
+	fields := make([]string, 0)
+	argsErr, ok := err.(*base.ArgsError)
+	if !ok {
+		return fields
+	}
+	for _, e := range argsErr.Errors() {
+		if fe, ok := e.(*base.FieldError); ok {
+			fields = append(fields, fe.Field)
+		} else {
+			fields = append(fields, "")
+		}
+	}
+	return fields
+}
+
+func TestConfigCheck(t *testing.T) {
+	invalidSeed, _ := http.NewRequest("GET", "http://localhost/", nil)
+	invalidSeed.Host = ""
+	testCases := []struct {
+		name    string
+		options []ConfigOption
+		fields  []string
+	}{
+		{"valid", nil, []string{}},
+		{
+			name:    "empty",
+			options: []ConfigOption{func(config *Config) { *config = Config{} }},
+			fields: []string{
+				"channelArgs.reqChanLen", "channelArgs.respChanLen", "channelArgs.itemChanLen",
+				"channelArgs.errorChanLen", "poolBaseArgs.pageDownloaderPoolSize",
+				"poolBaseArgs.analyzerPoolSize", "httpClientGenerator", "respParsers",
+				"itemProcessors", "seeds",
+			},
+		},
+		{
+			name: "nil elements",
+			options: []ConfigOption{
+				WithRespParsers(nil),
+				WithNamedParsers(anlz.NamedParsers{"detail": nil}),
+				WithItemProcessors(ipl.ProcessItem(nil), nil),
+				WithSeeds(nil, invalidSeed),
+			},
+			fields: []string{"respParsers[1]", "namedParsers.detail", "itemProcessors[1]", "itemProcessors[2]",
+				"seeds[1]", "seeds[2]"},
+		},
+		{
+			name: "nested",
+			options: []ConfigOption{
+				WithScope(&Scope{Schemes: []string{" "}, Deny: []*regexp.Regexp{nil}}),
+				WithCheckpoint(&Checkpoint{Seen: []string{""}}),
+				WithItemBatching(0, time.Second),
+				WithNearDuplicates(-1),
+				WithDeadLetters(nil, &DeadLetter{Kind: DEAD_LETTER_ITEM}),
+			},
+			fields: []string{
+				"scope.schemes[0]", "scope.deny[0]", "checkpoint.seen[0]", "nearDuplicates",
+				"itemBatching.batchSize", "deadLetters[0]", "deadLetters[1]",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		config := NewConfig(validConfigOptions()...).Apply(tc.options...)
+		err := config.Check()
+		if fields := errorFields(err); !reflect.DeepEqual(fields, tc.fields) {
+			t.Errorf("%s: Expected the error fields %v, but got %v (%v)!", tc.name, tc.fields, fields, err)
+		}
+		if len(tc.fields) == 0 && err != nil {
+			t.Errorf("%s: Unexpected error %s!", tc.name, err)
+		}
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	config := NewConfig(validConfigOptions()...)
+	if config.ItemWorkers() != 4 {
+		t.Errorf("The item workers should default to the analyzer pool size, but got %d!", config.ItemWorkers())
+	}
+	if _, _, ok := config.ItemBatching(); ok {
+		t.Errorf("The item batching should be disabled by default!")
+	}
+	if _, ok := config.NearDuplicates(); ok {
+		t.Errorf("The near duplicate detection should be disabled by default!")
+	}
+	if config.Scope() != nil || config.Checkpoint() != nil || config.SitemapDiscovery() ||
+		config.Directives() != (anlz.Directives{}) || config.DeadLetterStore() != nil || config.DeadLetters() != nil {
+		t.Errorf("Unexpected defaults %s!", config)
+	}
+
+	//nil配置项被忽略，追加的配置项不会覆盖已有的内容
+	seed, _ := http.NewRequest("GET", "http://example.org/", nil)
+	config.Apply(nil, WithItemWorkers(2), WithItemBatching(5, time.Second), WithSeeds(seed),
+		WithRespParsers(func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			return nil, nil
+		}))
+	if config.ItemWorkers() != 2 {
+		t.Errorf("Expected 2 item workers, but got %d!", config.ItemWorkers())
+	}
+	if size, wait, ok := config.ItemBatching(); !ok || size != 5 || wait != time.Second {
+		t.Errorf("Unexpected item batching %d/%s!", size, wait)
+	}
+	if len(config.Seeds()) != 2 || len(config.RespParsers()) != 2 || len(config.ItemProcessors()) != 1 {
+		t.Errorf("Unexpected config %s!", config)
+	}
+	if err := config.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sys/fetch/base"
+	ipl "sys/fetch/itempipeline"
+)
+
+// 死信的种类。
+const (
+	DEAD_LETTER_REQUEST = "request" // 下载失败的请求。
+	DEAD_LETTER_ITEM    = "item"    // 处理失败的条目。
+)
+
+// 死信。它记录了处理失败的请求或条目，以便在修复问题之后重新投递。
+// 死信文件中每行是一条JSON格式的死信。
+type DeadLetter struct {
+	Kind    string             `json:"kind"`              // 种类。
+	Code    string             `json:"code"`              // 出错的组件的代号。
+	Error   string             `json:"error"`             // 错误信息。
+	Time    time.Time          `json:"time"`              // 出错的时间。
+	Request *CheckpointRequest `json:"request,omitempty"` // 下载失败的请求。
+	Item    base.Item          `json:"item,omitempty"`    // 处理失败的条目。读出的数字为float64，时间和URL为字符串。
+}
+
+// 根据下载失败的请求生成死信。
+func newRequestDeadLetter(req *base.Request, code string, err error) *DeadLetter {
+	cr := newCheckpointRequest(req)
+	return &DeadLetter{
+		Kind:    DEAD_LETTER_REQUEST,
+		Code:    code,
+		Error:   err.Error(),
+		Time:    time.Now(),
+		Request: &cr,
+	}
+}
+
+// 根据处理失败的条目生成死信。多个错误的信息以"; "连接。
+func newItemDeadLetter(item base.Item, code string, errs []error) *DeadLetter {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return &DeadLetter{
+		Kind:  DEAD_LETTER_ITEM,
+		Code:  code,
+		Error: strings.Join(msgs, "; "),
+		Time:  time.Now(),
+		Item:  ipl.JsonItem(item),
+	}
+}
+
+// 检查死信的有效性。
+func (letter *DeadLetter) Check() error {
+	switch letter.Kind {
+	case DEAD_LETTER_REQUEST:
+		if letter.Request == nil {
+			return errors.New("The request of the dead letter is missing!")
+		}
+		_, err := letter.Request.request()
+		return err
+	case DEAD_LETTER_ITEM:
+		if letter.Item == nil {
+			return errors.New("The item of the dead letter is missing!")
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Unknown dead letter kind '%s'!", letter.Kind))
+}
+
+func (letter *DeadLetter) String() string {
+	target := ""
+	if letter.Request != nil {
+		target = letter.Request.Url
+	} else if letter.Item != nil {
+		target = fmt.Sprintf("%d fields", len(letter.Item))
+	}
+	return fmt.Sprintf("{ kind: %s, code: %s, time: %s, target: %s, error: %s }",
+		letter.Kind, letter.Code, letter.Time.Format(time.RFC3339), target, letter.Error)
+}
+
+// 死信存储的接口类型。它的实现需要是并发安全的。
+type DeadLetterStore interface {
+	// 存入一条死信。
+	Put(letter *DeadLetter) error
+	// 获得已存入的死信的数量。
+	Count() uint64
+	// 关闭存储。
+	Close() error
+}
+
+// 设定死信存储。下载失败的请求和处理失败的条目会被存入其中，
+// 错误依然会被发送到错误通道。调度器不会关闭死信存储。
+func WithDeadLetterStore(store DeadLetterStore) ConfigOption {
+	return func(config *Config) {
+		config.deadLetterStore = store
+	}
+}
+
+// 追加需要重新投递的死信。死信中的请求会被放入请求缓存而不再检查爬取范围和深度，
+// 死信中的条目会被直接交给条目处理管道。有死信时种子请求可以为空。
+func WithDeadLetters(letters ...*DeadLetter) ConfigOption {
+	return func(config *Config) {
+		config.deadLetters = append(config.deadLetters, letters...)
+	}
+}
+
+// 创建把死信写入文件的存储。文件以追加的方式在存入第一条死信时才被打开，
+// 每条死信都被直接写入文件，所以程序异常退出时也不会丢失。
+func NewDeadLetterFile(path string) (DeadLetterStore, error) {
+	if path == "" {
+		return nil, errors.New("The dead letter file path can not be empty!")
+	}
+	return &myDeadLetterFile{path: path}, nil
+}
+
+// 死信文件存储的实现类型。
+type myDeadLetterFile struct {
+	path   string     // 文件的路径。
+	file   *os.File   // 文件。在存入第一条死信时被打开。
+	closed bool       // 是否已被关闭。
+	count  uint64     // 已存入的死信的数量。
+	mutex  sync.Mutex // 互斥锁。
+}
+
+func (df *myDeadLetterFile) Put(letter *DeadLetter) error {
+	if letter == nil {
+		return errors.New("Invalid dead letter!")
+	}
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Can not encode the dead letter to JSON: %s", err))
+	}
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+	if df.closed {
+		return errors.New("The dead letter file is closed!")
+	}
+	if df.file == nil {
+		if dir := filepath.Dir(df.path); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		file, err := os.OpenFile(df.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		df.file = file
+	}
+	if _, err := df.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	df.count++
+	return nil
+}
+
+func (df *myDeadLetterFile) Count() uint64 {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+	return df.count
+}
+
+func (df *myDeadLetterFile) Close() error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+	if df.closed {
+		return nil
+	}
+	df.closed = true
+	if df.file == nil {
+		return nil
+	}
+	return df.file.Close()
+}
+
+// 读取死信文件中的全部死信。
+func ReadDeadLetters(path string) ([]*DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	letters := make([]*DeadLetter, 0)
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			letter := &DeadLetter{}
+			if err := json.Unmarshal(data, letter); err != nil {
+				return nil, errors.New(
+					fmt.Sprintf("Invalid dead letter at %s:%d: %s", path, line, err))
+			}
+			if err := letter.Check(); err != nil {
+				return nil, errors.New(
+					fmt.Sprintf("Invalid dead letter at %s:%d: %s", path, line, err))
+			}
+			letters = append(letters, letter)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return letters, nil
+}
+
+// 存入死信。存储未设定时什么也不做，存入失败时只记录日志。
+func (sched *myScheduler) putDeadLetter(letter *DeadLetter) {
+	if sched.deadLetterStore == nil {
+		return
+	}
+	if err := sched.deadLetterStore.Put(letter); err != nil {
+		logger.Errorf("Failed to store the dead letter %s: %s\n", letter, err)
+	}
+}
+
+// 重新投递死信中的请求。请求被放入请求缓存，已请求过的URL会被跳过。
+// 调用方需持有urlMutex。
+func (sched *myScheduler) redeliverRequests(letters []*DeadLetter) {
+	for _, letter := range letters {
+		if letter.Kind != DEAD_LETTER_REQUEST {
+			continue
+		}
+		if err := letter.Check(); err != nil {
+			logger.Warnf("Ignore the dead letter! Its request can not be rebuilt: %s (letter=%s)\n", err, letter)
+			continue
+		}
+		req, _ := letter.Request.request()
+		reqUrl := req.HttpReq().URL.String()
+		if sched.urlMap[reqUrl] {
+			continue
+		}
+		sched.urlMap[reqUrl] = true
+		sched.reqCache.put(req)
+	}
+}
+
+// 重新投递死信中的条目。条目在后台被发送到条目通道。
+func (sched *myScheduler) redeliverItems(letters []*DeadLetter) {
+	items := make([]base.Item, 0)
+	for _, letter := range letters {
+		if letter.Kind == DEAD_LETTER_ITEM {
+			items = append(items, letter.Item)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Errorf("Failed to redeliver the dead letter items: %s\n", p)
+			}
+		}()
+		for _, item := range items {
+			if !sched.sendItem(item, SCHEDULER_CODE) {
+				return
+			}
+		}
+	}()
+}
+
+// 获得用于确定爬取范围的请求，即种子请求和死信中的请求。
+func scopeSeeds(config *Config) []*http.Request {
+	seeds := append([]*http.Request{}, config.Seeds()...)
+	for _, letter := range config.DeadLetters() {
+		if letter.Kind != DEAD_LETTER_REQUEST {
+			continue
+		}
+		if err := letter.Check(); err != nil {
+			logger.Warnf("Ignore the dead letter for the crawl scope! Its request can not be rebuilt: %s (letter=%s)\n",
+				err, letter)
+			continue
+		}
+		req, _ := letter.Request.request()
+		seeds = append(seeds, req.HttpReq())
+	}
+	return seeds
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sys/fetch/base"
+)
+
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead", "letters.jsonl")
+	store, err := NewDeadLetterFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//没有死信时不会创建文件
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file before the first letter, but got %v!", err)
+	}
+	httpReq, _ := http.NewRequest("GET", "http://example.com/a", nil)
+	httpReq.Header.Set("Accept", "text/html")
+	req := base.NewRequest(httpReq, 2)
+	req.SetMeta(base.META_SEED_ID, "http://example.com/")
+	link, _ := url.Parse("http://example.com/b")
+	letters := []*DeadLetter{
+		newRequestDeadLetter(req, "downloader-1", errors.New("timeout")),
+		newItemDeadLetter(base.Item{"url": link, "n": 1}, ITEMPIPELINE_CODE,
+			[]error{errors.New("bad"), errors.New("worse")}),
+	}
+	for _, letter := range letters {
+		if err := store.Put(letter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.Count() != 2 {
+		t.Errorf("Expected 2 letters, but got %d!", store.Count())
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(letters[0]); err == nil {
+		t.Errorf("Expected an error after closing!")
+	}
+
+	read, err := ReadDeadLetters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("Expected 2 letters, but got %d!", len(read))
+	}
+	restored, err := read[0].Request.request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.HttpReq().URL.String() != "http://example.com/a" || restored.Depth() != 2 ||
+		restored.HttpReq().Header.Get("Accept") != "text/html" ||
+		restored.Meta()[base.META_SEED_ID] != "http://example.com/" {
+		t.Errorf("Unexpected request %v!", read[0].Request)
+	}
+	if read[0].Code != "downloader-1" || read[0].Error != "timeout" {
+		t.Errorf("Unexpected letter %s!", read[0])
+	}
+	item := read[1].Item
+	if read[1].Kind != DEAD_LETTER_ITEM || read[1].Error != "bad; worse" ||
+		item["url"] != "http://example.com/b" || item["n"] != float64(1) {
+		t.Errorf("Unexpected letter %s with item %v!", read[1], item)
+	}
+}
+
+func TestDeadLetterCheck(t *testing.T) {
+	invalid := []*DeadLetter{
+		{Kind: "response"},
+		{Kind: DEAD_LETTER_REQUEST},
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: "://"}},
+		{Kind: DEAD_LETTER_ITEM},
+	}
+	for _, letter := range invalid {
+		if letter.Check() == nil {
+			t.Errorf("Expected an error for the letter %s!", letter)
+		}
+	}
+	config := NewConfig(WithDeadLetters(&DeadLetter{Kind: DEAD_LETTER_ITEM, Item: base.Item{"a": 1}}))
+	if err := config.Check(); err == nil || strings.Contains(err.Error(), "seed") {
+		t.Errorf("The seeds should be optional with dead letters, but got %v!", err)
+	}
+}
+
+func TestDeadLetterFileMkdirError(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewDeadLetterFile(filepath.Join(blocker, "letters.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	letter := &DeadLetter{Kind: DEAD_LETTER_ITEM, Item: base.Item{"a": 1}}
+	//错误应来自创建目录，而不是随后打开文件
+	if err := store.Put(letter); err == nil || !strings.Contains(err.Error(), "mkdir") || store.Count() != 0 {
+		t.Errorf("Expected an error when the directory can not be created, but got %v!", err)
+	}
+}
+
+// 无法还原出请求的死信被跳过，其余的死信照常被重新投递。
+func TestRedeliverInvalidRequests(t *testing.T) {
+	letters := []*DeadLetter{
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: "http://example.com/a"}},
+		{Kind: DEAD_LETTER_REQUEST},
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Method: "GET ", Url: "http://example.com/b"}},
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: "http://example.com/c"}},
+	}
+	sched := &myScheduler{reqCache: newRequestCache(), urlMap: make(map[string]bool)}
+	sched.redeliverRequests(letters)
+	if sched.reqCache.length() != 2 || !sched.urlMap["http://example.com/a"] || !sched.urlMap["http://example.com/c"] {
+		t.Errorf("Expected only the valid requests to be redelivered, but got %v!", sched.urlMap)
+	}
+	seeds := scopeSeeds(NewConfig(WithDeadLetters(letters...)))
+	if len(seeds) != 2 {
+		t.Errorf("Expected 2 requests for the crawl scope, but got %d!", len(seeds))
+	}
+}
+
+// 只有死信而没有种子请求时，死信中的请求被下载，条目被直接处理。
+func TestStartWithDeadLetters(t *testing.T) {
+	crawl := newTestCrawl()
+	defer crawl.close()
+	letters := []*DeadLetter{
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: crawl.url("/c"), Depth: 1}},
+		{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: crawl.url("/c"), Depth: 1}},
+		{Kind: DEAD_LETTER_ITEM, Item: base.Item{"path": "/item"}},
+	}
+	crawl.run(t, 2, WithDeadLetters(letters...))
+	if paths := crawl.crawled(); fmt.Sprint(paths) != "[/c /item]" {
+		t.Errorf("Expected the redelivered request and item, but got %v!", paths)
+	}
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+)
+
+// 设定分析器遵守的页面指令。遵守noindex时带有noindex的页面不会产生条目，
+// 遵守nofollow时带有nofollow的页面不会产生请求。识别rel=canonical时，
+// 页面的规范URL会被视为已请求过的URL，规范URL已被请求过或已被其他页面声明过的页面被当作副本，
+// 它不会产生条目。
+func WithDirectives(directives anlz.Directives) ConfigOption {
+	return func(config *Config) {
+		config.directives = directives
+	}
+}
+
+// 把响应中的规范URL记入已请求的URL的字典，并判断页面是否为其他页面的副本。
+// 规范URL就是页面自身(包括重定向前后的URL)时，页面不是副本。
+func (sched *myScheduler) markCanonical(resp base.Response) (string, bool) {
+	canonical := resp.Meta().String(base.META_CANONICAL)
+	httpResp := resp.HttpResp()
+	if canonical == "" || httpResp == nil || httpResp.Request == nil {
+		return canonical, false
+	}
+	self := canonical == originalReqUrl(httpResp) || canonical == httpResp.Request.URL.String()
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
+	duplicate := !self && sched.urlMap[canonical]
+	sched.urlMap[canonical] = true
+	return canonical, duplicate
+}
+
+// 去掉数据列表中的条目。
+func withoutItems(dataList []base.Data) []base.Data {
+	result := make([]base.Data, 0, len(dataList))
+	for _, data := range dataList {
+		if _, ok := data.(*base.Item); !ok {
+			result = append(result, data)
+		}
+	}
+	return result
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	anlz "sys/fetch/analyzer"
+)
+
+// 设定检测近似重复的页面。调度器会为每次爬取创建一个SimHash索引，并让所有分析器共用它。
+// HTML页面可见文本的指纹与已分析的页面的指纹的海明距离不超过maxDistance时，
+// 页面被当作近似重复的页面，它既不产生条目也不产生请求，并被计入调度器摘要信息。
+// maxDistance的取值范围是[0, 31]，通常取3。
+func WithNearDuplicates(maxDistance int) ConfigOption {
+	return func(config *Config) {
+		config.nearDuplicates = true
+		config.nearDuplicateDist = maxDistance
+	}
+}
+
+// 根据配置生成分析器的选项。
+func analyzerOptions(config *Config) ([]anlz.AnalyzerOption, error) {
+	options := []anlz.AnalyzerOption{
+		anlz.WithDirectives(config.Directives()),
+		anlz.WithNamedParsers(config.NamedParsers()),
+	}
+	if maxDistance, ok := config.NearDuplicates(); ok {
+		index, err := anlz.NewSimHashIndex(maxDistance)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, anlz.WithNearDuplicateIndex(index))
+	}
+	return options, nil
+}
@@ -31,11 +31,11 @@ func generatePageDownloaderPool(
 	return dlPool, nil
 }
 
-func generateAnalyzerPool(poolSize uint32) (anlz.AnalyzerPool, error) {
+func generateAnalyzerPool(poolSize uint32, options ...anlz.AnalyzerOption) (anlz.AnalyzerPool, error) {
 	analyzerPool, err := anlz.NewAnalyzerPool(
 		poolSize,
 		func() anlz.Analyzer {
-			return anlz.NewAnalyzer()
+			return anlz.NewAnalyzer(options...)
 		},
 	)
 	if err != nil {
@@ -44,8 +44,8 @@ func generateAnalyzerPool(poolSize uint32) (anlz.AnalyzerPool, error) {
 	return analyzerPool, nil
 }
 
-func generateItemPipeline(itemProcessors []ipl.ProcessItem) ipl.ItemPipeline {
-	return ipl.NewItemPipeline(itemProcessors)
+func generateItemPipeline(itemProcessors []ipl.ItemProcessor, options ...ipl.PipelineOption) ipl.ItemPipeline {
+	return ipl.NewItemPipeline(itemProcessors, options...)
 }
 
 // 生成组件实例代号。
@@ -9,10 +9,10 @@ import (
 	"fmt"
 	"sys/fetch/logging"
 	"errors"
+	"sync"
 	"sync/atomic"
 	"time"
 	"sys/fetch/base"
-	"strings"
 )
 
 var logger logging.Logger = base.NewLogger()
@@ -44,7 +44,12 @@ type Scheduler interface {
 		itemProcessors []ipl.ProcessItem,  //需要被置入条目处理管道中的条目处理器的序列
 		firstHttpReq *http.Request) (err error)
 
-	//停止调度器的运行
+	// 根据配置开启调度器。
+	// 配置涵盖了Start方法的全部参数，并且可以容纳更多的设定。
+	// 配置会在调度器创建任何组件之前被检查，所有问题会被汇总在同一个错误中报告。
+	StartWithConfig(config *Config) (err error)
+
+	//停止调度器的运行。已进入条目通道的条目会先被处理完，之后条目处理器才会被关闭
 	Stop() bool
 
 	//判断调度器是否正在运行
@@ -59,6 +64,13 @@ type Scheduler interface {
 
 	//活动区摘要信息
 	Summary(prefix string) SchedSummary
+
+	//生成检查点。检查点包含请求缓存中的请求、正在被下载或分析的请求以及已请求过的URL。
+	//调度器被停止之后依然可以生成检查点。
+	Checkpoint() *Checkpoint
+
+	//获得条目处理管道中各个条目处理器的统计信息。调度器未开启过时返回nil
+	ItemProcessorStats() []ipl.ProcessorStats
 }
 
 //创建调度器
@@ -73,15 +85,20 @@ type myScheduler struct {
 	channelArgs   base.ChannelArgs      //通道参数的容器
 	poolBaseArgs  base.PoolBaseArgs     //池基本参数的容器
 	crawlDepth    uint32                //爬取的最大深度。首次请求的深度为0
-	primaryDomain string                //主域名
+	scope         *crawlScope           //爬取范围
 	chanman       mdw.ChannelManager    //通道管理器
 	stopSign      mdw.StopSign          //停止信号
 	dlpool        dl.PageDownloaderPool //网页下载器池
 	analyzerPool  anlz.AnalyzerPool     //分析器池
 	itemPipeline  ipl.ItemPipeline      //条目处理管道
+	itemsDone     chan struct{}         //条目处理管道的工作协程全部退出时被关闭的通道
 	running       uint32                //运行标记。0表示未运行，1表示已运行，2表示已停止
 	reqCache      requestCache          //请求缓存
 	urlMap        map[string]bool       //已请求的URL的字典
+	inFlight      map[string]*base.Request //正在被下载或分析的请求的字典
+	nearDuplicates uint64               //被跳过的近似重复页面的数量
+	deadLetterStore DeadLetterStore     //死信存储
+	urlMutex      sync.Mutex            //针对以上两个字典的互斥锁
 }
 
 
@@ -93,7 +110,18 @@ func (sched *myScheduler) Start(
 	respParsers []anlz.ParseResponse,
 	itemProcessors []ipl.ProcessItem,
 	firstHttpReq *http.Request) (err error) {
+	config := NewConfig(
+		WithChannelArgs(channelArgs),
+		WithPoolBaseArgs(poolBaseArgs),
+		WithCrawlDepth(crawlDepth),
+		WithHttpClientGenerator(httpClientGenerator),
+		WithSeeds(firstHttpReq))
+	config.respParsers = respParsers
+	config.itemProcessors = ipl.Processors(itemProcessors...)
+	return sched.StartWithConfig(config)
+}
 
+func (sched *myScheduler) StartWithConfig(config *Config) (err error) {
 	defer func() {
 		if p := recover(); p != nil {
 			errMsg := fmt.Sprintf("Fatal Scheduler Error:%s\n", p)
@@ -105,46 +133,50 @@ func (sched *myScheduler) Start(
 	if atomic.LoadUint32(&sched.running) == 1 {
 		return errors.New("The scheduler has been started!\n")
 	}
-	atomic.StoreUint32(&sched.running, 1)
-
-	if err := channelArgs.Check(); err != nil {
-		return err
+	if config == nil {
+		return errors.New("The scheduler config is invalid!")
 	}
-	sched.channelArgs = channelArgs
-	if err := poolBaseArgs.Check(); err != nil {
+	if err := config.Check(); err != nil {
 		return err
 	}
-	sched.poolBaseArgs = poolBaseArgs
-	sched.crawlDepth = crawlDepth
+	atomic.StoreUint32(&sched.running, 1)
+	//初始化失败(包括发生运行时恐慌)时调度器不算已开启，否则Stop会等待从未打开的条目处理管道
+	initialized := false
+	defer func() {
+		if !initialized {
+			atomic.StoreUint32(&sched.running, 0)
+		}
+	}()
+	sched.itemsDone = nil
+
+	sched.channelArgs = config.ChannelArgs()
+	sched.poolBaseArgs = config.PoolBaseArgs()
+	sched.crawlDepth = config.CrawlDepth()
 	sched.chanman = generateChannelManager(sched.channelArgs)
-	if httpClientGenerator == nil {
-		return errors.New("The HTTP client generator list is invalid!")
-	}
 	dlpool, err := generatePageDownloaderPool(
 		sched.poolBaseArgs.PageDownloaderPoolSize(),
-		httpClientGenerator)
+		config.HttpClientGenerator())
 	if err != nil {
 		errMsg := fmt.Sprintf("Occur error when get page downloader pool:%s\n", err)
 		return errors.New(errMsg)
 	}
 
 	sched.dlpool = dlpool
-	analyzerPool, err := generateAnalyzerPool(sched.poolBaseArgs.AnalyzerPoolSize())
+	analyzerOptions, err := analyzerOptions(config)
+	if err != nil {
+		return err
+	}
+	analyzerPool, err := generateAnalyzerPool(sched.poolBaseArgs.AnalyzerPoolSize(), analyzerOptions...)
 	if err != nil {
 		errMsg := fmt.Sprintf("Occur error when get analyzer pool:%s\n", err)
 		return errors.New(errMsg)
 	}
 	sched.analyzerPool = analyzerPool
-
-	if itemProcessors == nil {
-		return errors.New("The item processor list is invalid!")
-	}
-	for i, ip := range itemProcessors {
-		if ip == nil {
-			return errors.New(fmt.Sprintf("The %dth item processor is invalid!", i))
-		}
+	var pipelineOptions []ipl.PipelineOption
+	if size, wait, ok := config.ItemBatching(); ok {
+		pipelineOptions = append(pipelineOptions, ipl.WithBatching(size, wait))
 	}
-	sched.itemPipeline = generateItemPipeline(itemProcessors)
+	sched.itemPipeline = generateItemPipeline(config.ItemProcessors(), pipelineOptions...)
 
 	if sched.stopSign == nil {
 		sched.stopSign = mdw.NewStopSign()
@@ -155,22 +187,22 @@ func (sched *myScheduler) Start(
 	//创建请求缓存
 	sched.reqCache = newRequestCache()
 	sched.urlMap = make(map[string]bool)
-	sched.startDownloading()
-	sched.activateAnalyzers(respParsers)
-	sched.openItemPipeline()
-	sched.schedule(10 * time.Millisecond)
-
-	if firstHttpReq == nil {
-		return errors.New("The first HTTP request is invalid!")
-	}
-	pd, err := getPrimaryDomain(firstHttpReq.Host)
+	sched.inFlight = make(map[string]*base.Request)
+	atomic.StoreUint64(&sched.nearDuplicates, 0)
+	scope, err := newCrawlScope(config.Scope(), scopeSeeds(config))
 	if err != nil {
 		return err
 	}
-	sched.primaryDomain = pd
-
-	firstReq := base.NewRequest(firstHttpReq, 0)
-	sched.reqCache.put(firstReq)
+	sched.scope = scope
+	sched.deadLetterStore = config.DeadLetterStore()
+	//在各个组件开始运行之前放入初始的请求
+	sched.putInitialRequests(config)
+	sched.startDownloading()
+	sched.activateAnalyzers(config.RespParsers())
+	sched.openItemPipeline(config.ItemWorkers())
+	sched.schedule(10 * time.Millisecond)
+	sched.redeliverItems(config.DeadLetters())
+	initialized = true
 	return nil
 }
 
@@ -181,6 +213,13 @@ func (sched *myScheduler) Stop() bool {
 	sched.stopSign.Sign()
 	sched.chanman.Close()
 	sched.reqCache.close()
+	//等待工作协程处理完条目通道中剩余的条目，然后刷新并关闭条目处理器(如文件输出端)
+	if sched.itemsDone != nil {
+		<-sched.itemsDone
+	}
+	if err := sched.itemPipeline.Close(); err != nil {
+		logger.Errorf("Failed to close the item pipeline: %s\n", err)
+	}
 	atomic.StoreUint32(&sched.running, 2)
 	return true
 }
@@ -210,11 +249,109 @@ func (sched *myScheduler) Summary(prefix string) SchedSummary {
 	return NewSchedSummary(sched, prefix)
 }
 
+func (sched *myScheduler) ItemProcessorStats() []ipl.ProcessorStats {
+	if sched.itemPipeline == nil {
+		return nil
+	}
+	return sched.itemPipeline.Stats()
+}
+
+func (sched *myScheduler) Checkpoint() *Checkpoint {
+	checkpoint := &Checkpoint{
+		CreatedAt: time.Now(),
+		Pending:   make([]CheckpointRequest, 0),
+		Seen:      make([]string, 0),
+	}
+	if sched.reqCache == nil {
+		return checkpoint
+	}
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
+	for _, req := range sched.inFlight {
+		checkpoint.Pending = append(checkpoint.Pending, newCheckpointRequest(req))
+	}
+	for _, req := range sched.reqCache.snapshot() {
+		checkpoint.Pending = append(checkpoint.Pending, newCheckpointRequest(req))
+	}
+	for u := range sched.urlMap {
+		checkpoint.Seen = append(checkpoint.Seen, u)
+	}
+	return checkpoint
+}
+
+//放入初始的请求，即检查点中未完成的请求、死信中的请求、种子请求和发现站点地图所用的请求。
+//已请求过的URL也会被恢复
+func (sched *myScheduler) putInitialRequests(config *Config) {
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
+	if checkpoint := config.Checkpoint(); checkpoint != nil {
+		for _, u := range checkpoint.Seen {
+			sched.urlMap[u] = true
+		}
+		for i := range checkpoint.Pending {
+			req, _ := checkpoint.Pending[i].request()
+			sched.reqCache.put(req)
+		}
+	}
+	sched.redeliverRequests(config.DeadLetters())
+	for _, seed := range config.Seeds() {
+		seedUrl := seed.URL.String()
+		if sched.urlMap[seedUrl] {
+			continue
+		}
+		sched.urlMap[seedUrl] = true
+		seedReq := base.NewRequest(seed, 0)
+		seedReq.SetMeta(base.META_SEED_ID, seedUrl)
+		sched.reqCache.put(seedReq)
+	}
+	if config.SitemapDiscovery() {
+		for _, req := range sitemapDiscoveryRequests(config.Seeds()) {
+			reqUrl := req.HttpReq().URL.String()
+			if sched.urlMap[reqUrl] {
+				continue
+			}
+			if ok, reason := sched.scope.check(req.HttpReq()); !ok {
+				logger.Warnf("Ignore the sitemap discovery request! %s (requestUrl=%s)\n", reason, reqUrl)
+				continue
+			}
+			sched.urlMap[reqUrl] = true
+			sched.reqCache.put(req)
+		}
+	}
+}
+
+//把请求标记为正在被下载或分析
+func (sched *myScheduler) markInFlight(req *base.Request) {
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
+	sched.inFlight[req.HttpReq().URL.String()] = req
+}
+
+//取消请求的正在被下载或分析的标记
+func (sched *myScheduler) unmarkInFlight(reqUrl string) {
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
+	delete(sched.inFlight, reqUrl)
+}
+
+//获得响应所对应的最初的HTTP请求的URL。发生重定向时，HTTP响应中的请求是重定向之后的请求
+func originalReqUrl(httpResp *http.Response) string {
+	httpReq := httpResp.Request
+	if httpReq == nil {
+		return ""
+	}
+	for httpReq.Response != nil && httpReq.Response.Request != nil {
+		httpReq = httpReq.Response.Request
+	}
+	return httpReq.URL.String()
+}
+
 //开始下载
 func (sched *myScheduler) startDownloading() {
+	reqChan := sched.getReqChan()
 	go func() {
 		for {
-			req, ok := <-sched.getReqChan()
+			req, ok := <-reqChan
 			if !ok {
 				break
 			}
@@ -248,7 +385,14 @@ func (sched *myScheduler) download(req base.Request) {
 	code := generateCode(DOWNLOADER_CODE, downloader.Id())
 	respp, err := downloader.Download(req)
 	if respp != nil {
-		sched.sendResp(*respp, code)
+		if !sched.sendResp(*respp, code) {
+			sched.unmarkInFlight(req.HttpReq().URL.String())
+		}
+	} else {
+		sched.unmarkInFlight(req.HttpReq().URL.String())
+		if err != nil {
+			sched.putDeadLetter(newRequestDeadLetter(&req, code, err))
+		}
 	}
 	if err != nil {
 		sched.sendError(err, code)
@@ -257,9 +401,10 @@ func (sched *myScheduler) download(req base.Request) {
 
 //激活分析器
 func (sched *myScheduler) activateAnalyzers(respParsers []anlz.ParseResponse) {
+	respChan := sched.getRespChan()
 	go func() {
 		for {
-			resp, ok := <-sched.getRespChan()
+			resp, ok := <-respChan
 			if !ok {
 				break
 			}
@@ -276,6 +421,9 @@ func (sched *myScheduler) analyze(respParsers []anlz.ParseResponse, resp base.Re
 			logger.Fatal(errMsg)
 		}
 	}()
+	if httpResp := resp.HttpResp(); httpResp != nil {
+		defer sched.unmarkInFlight(originalReqUrl(httpResp))
+	}
 	analyzer, err := sched.analyzerPool.Take()
 	if err != nil {
 		errMsg := fmt.Sprintf("Analyzer pool error: %s", err)
@@ -292,6 +440,14 @@ func (sched *myScheduler) analyze(respParsers []anlz.ParseResponse, resp base.Re
 
 	code := generateCode(ANALYZER_CODE, analyzer.Id())
 	dataList, errs := analyzer.Analyze(respParsers, resp)
+	if _, ok := resp.Meta().Get(base.META_DUPLICATE_OF); ok {
+		atomic.AddUint64(&sched.nearDuplicates, 1)
+	}
+	if canonical, duplicate := sched.markCanonical(resp); duplicate {
+		logger.Warnf("Ignore the items! The page is a duplicate of %s. (requestUrl=%s)\n",
+			canonical, resp.HttpResp().Request.URL)
+		dataList = withoutItems(dataList)
+	}
 	if dataList != nil {
 		for _, data := range dataList {
 			if data == nil {
@@ -315,28 +471,23 @@ func (sched *myScheduler) analyze(respParsers []anlz.ParseResponse, resp base.Re
 	}
 }
 
-//打开条目处理管道
-func (sched *myScheduler) openItemPipeline() {
+//打开条目处理管道。固定数量的工作协程从条目通道中取出条目并处理。
+//条目通道被关闭并且剩余的条目都被处理之后，itemsDone会被关闭
+func (sched *myScheduler) openItemPipeline(workers uint32) {
+	sched.itemPipeline.SetFailFast(true)
+	code := ITEMPIPELINE_CODE
+	itemChan := sched.getItemChan()
+	done := make(chan struct{})
+	sched.itemsDone = done
 	go func() {
-		sched.itemPipeline.SetFailFast(true)
-		code := ITEMPIPELINE_CODE
-		for item := range sched.getItemChan() {
-			go func(item base.Item) {
-				defer func() {
-					if p := recover(); p != nil {
-						errMsg := fmt.Sprintf("Fatal Item Processing Error: %s\n", p)
-						logger.Fatal(errMsg)
-					}
-				}()
-				errs := sched.itemPipeline.Send(item)
-				if errs != nil {
-					for _, err := range errs {
-						sched.sendError(err, code)
-					}
+		defer close(done)
+		sched.itemPipeline.Serve(itemChan, int(workers),
+			func(item base.Item, errs []error) {
+				sched.putDeadLetter(newItemDeadLetter(item, code, errs))
+				for _, err := range errs {
+					sched.sendError(err, code)
 				}
-
-			}(item)
-		}
+			})
 	}()
 }
 
@@ -352,21 +503,17 @@ func (sched *myScheduler) saveReqToCache(req base.Request, code string) bool {
 		logger.Warnln("Ignore the request! It's url is invalid!")
 		return false
 	}
-	if strings.ToLower(reqUrl.Scheme) != "http" {
-		logger.Warnf("Ignore the request! It's url scheme '%s', but should be 'http'!\n", reqUrl.Scheme)
-		return false
-	}
-
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
 	if _, ok := sched.urlMap[reqUrl.String()]; ok {
 		logger.Warnf("Ignore the request! It's url is repeated.(requestUrl=%s)\n", reqUrl)
 		return false
 	}
-	if pd, _ := getPrimaryDomain(httpReq.Host); pd != sched.primaryDomain {
-		logger.Warnf("Ignore the request! It's host '%s' not in primary domain '%s'.(requestUrl=%s)\n",
-			httpReq.Host, sched.primaryDomain, reqUrl)
+	if ok, reason := sched.scope.check(httpReq); !ok {
+		logger.Warnf("Ignore the request! %s (requestUrl=%s)\n", reason, reqUrl)
 		return false
 	}
-	if req.Depth() > sched.crawlDepth {
+	if req.Depth() > sched.crawlDepth && !req.Meta().Bool(base.META_IGNORE_DEPTH) {
 		logger.Warnf("Ignore the request! It's depth %d greater than %d. (requestUrl=%s)\n",
 			req.Depth(), sched.crawlDepth, reqUrl)
 		return false
@@ -448,6 +595,7 @@ func (sched *myScheduler) schedule(interval time.Duration) {
 					sched.stopSign.Deal(SCHEDULER_CODE)
 					return
 				}
+				sched.markInFlight(temp)
 				sched.getReqChan() <- *temp
 				remainder--
 			}
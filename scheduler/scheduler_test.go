@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+)
+
+// 处理得较慢的输出端。它记录了被关闭时已处理的条目的数量。
+type testSlowSink struct {
+	processed int
+	atClose   int
+	closed    bool
+	mutex     sync.Mutex
+}
+
+func (sink *testSlowSink) Process(item base.Item) (base.Item, error) {
+	time.Sleep(5 * time.Millisecond)
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.processed++
+	return item, nil
+}
+
+func (sink *testSlowSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.atClose = sink.processed
+	sink.closed = true
+	return nil
+}
+
+// 停止调度器时，条目通道中剩余的条目会在输出端被关闭之前处理完。
+func TestStopDrainsItems(t *testing.T) {
+	crawl := newTestCrawl()
+	defer crawl.close()
+	seed, _ := http.NewRequest("GET", crawl.url("/"), nil)
+	sink := &testSlowSink{}
+	config := NewConfig(
+		WithChannelArgs(base.NewChannelArgs(10, 10, 10, 10)),
+		WithPoolBaseArgs(base.NewPoolBaseArgs(1, 1)),
+		WithHttpClientGenerator(func() *http.Client { return &http.Client{} }),
+		WithRespParsers(func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+			return nil, nil
+		}),
+		WithItemProcessors(sink),
+		WithItemWorkers(1),
+		WithSeeds(seed))
+	scheduler := NewScheduler()
+	if err := scheduler.StartWithConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	sched := scheduler.(*myScheduler)
+	for i := 0; i < 8; i++ {
+		if !sched.sendItem(base.Item{"n": i}, SCHEDULER_CODE) {
+			t.Fatal("Failed to send the item!")
+		}
+	}
+	if !scheduler.Stop() {
+		t.Fatal("The scheduler should be running!")
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if !sink.closed {
+		t.Fatal("The sink should be closed!")
+	}
+	if sink.atClose != 8 || sink.processed != 8 {
+		t.Errorf("Expected 8 items processed before closing, but got %d (%d in total)!",
+			sink.atClose, sink.processed)
+	}
+}
+
+// 初始化失败时调度器不算已开启，Stop也不会阻塞。
+func TestStartFailureIsNotRunning(t *testing.T) {
+	//死信中的请求的主机无法被识别，创建爬取范围时会失败
+	letter := &DeadLetter{Kind: DEAD_LETTER_REQUEST, Request: &CheckpointRequest{Url: "http://localhost:8080/a"}}
+	config := NewConfig(validConfigOptions()...)
+	config.seeds = nil
+	config.Apply(WithDeadLetters(letter))
+	scheduler := NewScheduler()
+	if err := scheduler.StartWithConfig(config); err == nil {
+		t.Fatal("Expected an error for the unrecognized host!")
+	}
+	if scheduler.Running() {
+		t.Errorf("The scheduler should not be running after a failed start!")
+	}
+	stopped := make(chan bool, 1)
+	go func() { stopped <- scheduler.Stop() }()
+	select {
+	case ok := <-stopped:
+		if ok {
+			t.Errorf("Stop should report that the scheduler is not running!")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked after a failed start!")
+	}
+}
+
+func TestStartWithNilProcessors(t *testing.T) {
+	parser := func(httpResp *http.Response, respDepth uint32) ([]base.Data, []error) {
+		return nil, nil
+	}
+	seed, _ := http.NewRequest("GET", "http://example.com/", nil)
+	scheduler := NewScheduler()
+	err := scheduler.Start(base.NewChannelArgs(10, 10, 10, 10), base.NewPoolBaseArgs(3, 3), 1,
+		func() *http.Client { return &http.Client{} },
+		[]anlz.ParseResponse{parser}, nil, seed)
+	if err == nil || !strings.Contains(err.Error(), "The item processor list is invalid!") {
+		t.Errorf("Expected an error for the nil item processor list, but got %v!", err)
+	}
+	if scheduler.Running() {
+		scheduler.Stop()
+		t.Errorf("The scheduler should not be running after a failed start!")
+	}
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sys/fetch/base"
+)
+
+// 爬取范围的规则。只有处于范围之内的请求才会被放入请求缓存。
+type Scope struct {
+	Domains []string         // 允许的主域名。为空时使用种子请求的主域名。
+	Schemes []string         // 允许的URL协议。为空时使用种子请求的协议，没有种子请求时只允许http。
+	Allow   []*regexp.Regexp // URL必须至少匹配其中的一个。为空时不做限制。
+	Deny    []*regexp.Regexp // URL只要匹配其中的任意一个就会被忽略。
+}
+
+// 设定爬取范围的规则。
+func WithScope(scope *Scope) ConfigOption {
+	return func(config *Config) {
+		config.scope = scope
+	}
+}
+
+// 检查爬取范围的规则的有效性。
+func (scope *Scope) Check() error {
+	argsErr := base.NewArgsError()
+	for i, domain := range scope.Domains {
+		if _, err := getPrimaryDomain(domain); err != nil {
+			argsErr.Add(fmt.Sprintf("domains[%d]", i), err)
+		}
+	}
+	for i, scheme := range scope.Schemes {
+		if strings.TrimSpace(scheme) == "" {
+			argsErr.Add(fmt.Sprintf("schemes[%d]", i), errors.New("The scheme is empty!"))
+		}
+	}
+	for i, re := range scope.Allow {
+		if re == nil {
+			argsErr.Add(fmt.Sprintf("allow[%d]", i), errors.New("The pattern is invalid!"))
+		}
+	}
+	for i, re := range scope.Deny {
+		if re == nil {
+			argsErr.Add(fmt.Sprintf("deny[%d]", i), errors.New("The pattern is invalid!"))
+		}
+	}
+	return argsErr.ErrorOrNil()
+}
+
+func (scope *Scope) String() string {
+	return fmt.Sprintf("{ domains: %v, schemes: %v, allow: %v, deny: %v }",
+		scope.Domains, scope.Schemes, scope.Allow, scope.Deny)
+}
+
+// 调度器内部使用的爬取范围。
+type crawlScope struct {
+	primaryDomains map[string]bool  // 允许的主域名的集合。
+	schemes        map[string]bool  // 允许的URL协议的集合。
+	allow          []*regexp.Regexp // 白名单。
+	deny           []*regexp.Regexp // 黑名单。
+}
+
+// 根据规则和种子请求创建爬取范围。
+func newCrawlScope(scope *Scope, seeds []*http.Request) (*crawlScope, error) {
+	cs := &crawlScope{
+		primaryDomains: make(map[string]bool),
+		schemes:        make(map[string]bool),
+	}
+	if scope == nil {
+		scope = &Scope{}
+	}
+	domains := scope.Domains
+	if len(domains) == 0 {
+		for _, seed := range seeds {
+			domains = append(domains, seed.Host)
+		}
+	}
+	for _, domain := range domains {
+		pd, err := getPrimaryDomain(domain)
+		if err != nil {
+			return nil, err
+		}
+		cs.primaryDomains[pd] = true
+	}
+	schemes := scope.Schemes
+	if len(schemes) == 0 {
+		for _, seed := range seeds {
+			schemes = append(schemes, seed.URL.Scheme)
+		}
+	}
+	if len(schemes) == 0 {
+		schemes = []string{"http"}
+	}
+	for _, scheme := range schemes {
+		cs.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+	cs.allow = scope.Allow
+	cs.deny = scope.Deny
+	return cs, nil
+}
+
+// 判断HTTP请求是否处于爬取范围之内。若不在范围之内，则同时返回原因。
+func (cs *crawlScope) check(httpReq *http.Request) (bool, string) {
+	reqUrl := httpReq.URL
+	scheme := strings.ToLower(reqUrl.Scheme)
+	if !cs.schemes[scheme] {
+		return false, fmt.Sprintf("It's url scheme '%s', but should be in %v!",
+			reqUrl.Scheme, keysOf(cs.schemes))
+	}
+	if pd, _ := getPrimaryDomain(httpReq.Host); !cs.primaryDomains[pd] {
+		return false, fmt.Sprintf("It's host '%s' not in primary domains %v.",
+			httpReq.Host, keysOf(cs.primaryDomains))
+	}
+	urlStr := reqUrl.String()
+	for _, re := range cs.deny {
+		if re.MatchString(urlStr) {
+			return false, fmt.Sprintf("It's url matches the deny pattern '%s'.", re)
+		}
+	}
+	if len(cs.allow) == 0 {
+		return true, ""
+	}
+	for _, re := range cs.allow {
+		if re.MatchString(urlStr) {
+			return true, ""
+		}
+	}
+	return false, "It's url matches none of the allow patterns."
+}
+
+// 获得集合中的所有键。
+func keysOf(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
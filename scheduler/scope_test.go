@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCrawlScopeDefaultSchemes(t *testing.T) {
+	newReq := func(rawUrl string) *http.Request {
+		req, err := http.NewRequest("GET", rawUrl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	testCases := []struct {
+		seeds   []*http.Request
+		scope   *Scope
+		allowed map[string]bool
+	}{
+		{
+			seeds:   []*http.Request{newReq("https://example.com/")},
+			allowed: map[string]bool{"https://example.com/a": true, "http://example.com/a": false},
+		},
+		{
+			seeds:   []*http.Request{newReq("http://example.com/"), newReq("HTTPS://example.com/")},
+			allowed: map[string]bool{"https://example.com/a": true, "http://example.com/a": true},
+		},
+		{
+			seeds:   []*http.Request{newReq("https://example.com/")},
+			scope:   &Scope{Schemes: []string{"http"}},
+			allowed: map[string]bool{"https://example.com/a": false, "http://example.com/a": true},
+		},
+		{
+			scope:   &Scope{Domains: []string{"example.com"}},
+			allowed: map[string]bool{"https://example.com/a": false, "http://example.com/a": true},
+		},
+	}
+	for i, tc := range testCases {
+		cs, err := newCrawlScope(tc.scope, tc.seeds)
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		for rawUrl, expected := range tc.allowed {
+			if ok, reason := cs.check(newReq(rawUrl)); ok != expected {
+				t.Errorf("case %d: Expected %v for %s, but got %v (%s)!", i, expected, rawUrl, ok, reason)
+			}
+		}
+	}
+}
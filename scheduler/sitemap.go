@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"net/http"
+	"net/url"
+
+	anlz "sys/fetch/analyzer"
+	"sys/fetch/base"
+)
+
+// 设定是否发现站点地图。启用时调度器在启动后会为每个种子请求所在的站点请求
+// robots.txt和/sitemap.xml，robots.txt中的"Sitemap:"行所指的站点地图也会被请求。
+// 站点地图中的URL会被当作种子请求。发现站点地图所用的请求和这些请求同样要受爬取范围的限制。
+func WithSitemapDiscovery(discover bool) ConfigOption {
+	return func(config *Config) {
+		config.sitemapDiscovery = discover
+	}
+}
+
+// 生成发现站点地图所用的请求。每个站点只会生成一次。
+func sitemapDiscoveryRequests(seeds []*http.Request) []*base.Request {
+	reqs := make([]*base.Request, 0)
+	sites := make(map[string]bool)
+	for _, seed := range seeds {
+		site := url.URL{Scheme: seed.URL.Scheme, Host: seed.URL.Host}
+		if sites[site.String()] {
+			continue
+		}
+		sites[site.String()] = true
+		for _, target := range []struct{ path, parser string }{
+			{"/robots.txt", anlz.ROBOTS_SITEMAP_PARSER},
+			{"/sitemap.xml", anlz.SITEMAP_PARSER},
+		} {
+			u := site
+			u.Path = target.path
+			httpReq, err := http.NewRequest("GET", u.String(), nil)
+			if err != nil {
+				logger.Warnf("Can not create the sitemap discovery request (url=%s): %s\n", u.String(), err)
+				continue
+			}
+			req := base.NewRequest(httpReq, 0)
+			req.SetMeta(base.META_SEED, true)
+			req.SetParsers(target.parser)
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
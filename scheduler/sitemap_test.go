@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// 发现站点地图所用的请求同样要受爬取范围的限制。
+func TestSitemapDiscoveryRequestsInScope(t *testing.T) {
+	seed, _ := http.NewRequest("GET", "http://example.com/", nil)
+	scope := &Scope{Deny: []*regexp.Regexp{regexp.MustCompile(`/robots\.txt$`)}}
+	config := NewConfig(WithSeeds(seed), WithScope(scope), WithSitemapDiscovery(true))
+	cs, err := newCrawlScope(config.Scope(), scopeSeeds(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched := &myScheduler{
+		scope:    cs,
+		reqCache: newRequestCache(),
+		urlMap:   make(map[string]bool),
+	}
+	sched.putInitialRequests(config)
+
+	urls := make([]string, 0)
+	for _, req := range sched.reqCache.snapshot() {
+		urls = append(urls, req.HttpReq().URL.String())
+	}
+	sort.Strings(urls)
+	expected := "[http://example.com/ http://example.com/sitemap.xml]"
+	if fmt.Sprint(urls) != expected {
+		t.Errorf("Expected the requests %s, but got %v!", expected, urls)
+	}
+	if sched.urlMap["http://example.com/robots.txt"] {
+		t.Errorf("The url out of scope should not be marked as requested!")
+	}
+}
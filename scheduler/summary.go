@@ -3,6 +3,7 @@ package scheduler
 import (
 	"bytes"
 	"fmt"
+	"sync/atomic"
 	"sys/fetch/base"
 )
 
@@ -16,6 +17,8 @@ type SchedSummary interface {
 
 //创建调度器摘要信息
 func NewSchedSummary(sched *myScheduler, prefix string) SchedSummary {
+	sched.urlMutex.Lock()
+	defer sched.urlMutex.Unlock()
 	urlCount := len(sched.urlMap)
 	var urlDetail string
 	if urlCount > 0 {
@@ -47,6 +50,7 @@ func NewSchedSummary(sched *myScheduler, prefix string) SchedSummary {
 		itemPipelineSummary: sched.itemPipeline.Summary(),
 		urlCount:            urlCount,
 		urlDetail:           urlDetail,
+		nearDuplicates:      atomic.LoadUint64(&sched.nearDuplicates),
 		stopSignSummary:     sched.stopSign.Summary(),
 	}
 }
@@ -66,6 +70,7 @@ type mySchedSummary struct {
 	itemPipelineSummary string            //条目处理管道的摘要信息
 	urlCount            int               //已请求的url的计数
 	urlDetail           string            //已请求的url的详细信息
+	nearDuplicates      uint64            //被跳过的近似重复页面的数量
 	stopSignSummary     string            //停止信号的摘要信息
 }
 
@@ -92,6 +97,7 @@ func (ss *mySchedSummary) getSummary(detail bool) string {
 		prefix + "Analyzer pool: %d/%d\n" +
 		prefix + "Item pipeline: %s\n" +
 		prefix + "Urls(%d): %s" +
+		prefix + "Near duplicates: %d\n" +
 		prefix + "Stop sign: %s\n"
 	return fmt.Sprintf(template,
 		func() bool {
@@ -113,6 +119,7 @@ func (ss *mySchedSummary) getSummary(detail bool) string {
 				return "<concealed>\n"
 			}
 		}(),
+		ss.nearDuplicates,
 		ss.stopSignSummary)
 }
 
@@ -131,6 +138,7 @@ func (ss *mySchedSummary) Same(other SchedSummary) bool {
 		ss.analyzerPoolLen != otherSs.analyzerPoolLen ||
 		ss.analyzerPoolCap != otherSs.analyzerPoolCap ||
 		ss.urlCount != otherSs.urlCount ||
+		ss.nearDuplicates != otherSs.nearDuplicates ||
 		ss.stopSignSummary != otherSs.stopSignSummary ||
 		ss.reqCacheSummary != otherSs.reqCacheSummary ||
 		ss.poolBaseArgs.String() != otherSs.poolBaseArgs.String() ||
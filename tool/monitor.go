@@ -105,43 +105,47 @@ func recordSummary(
 	detailSummary bool,
 	record Record,
 	stopNotifier <-chan byte) {
-	var recordCount uint64 = 1
-	startTime := time.Now()
-	var prevSchedSummary sched.SchedSummary
-	var prevNumGoroutine int
+	go func() {
+		//等待调度器开启
+		waitForSchedulerStart(scheduler)
+		var recordCount uint64 = 1
+		startTime := time.Now()
+		var prevSchedSummary sched.SchedSummary
+		var prevNumGoroutine int
 
-	for {
-		//产看监控停止通知器
-		select {
-		case <-stopNotifier:
-			return
-		default:
-		}
-		//获取摘要信息的各组成部分
-		currNumGoroutine := runtime.NumGoroutine()
-		currSchedSummary := scheduler.Summary("   ")
-		if currNumGoroutine != prevNumGoroutine || !currSchedSummary.Same(prevSchedSummary) {
-			schedSummaryStr := func() string {
-				if detailSummary {
-					return currSchedSummary.Detail()
-				} else {
-					return currSchedSummary.String()
-				}
-			}()
-			//记录摘要信息
-			info := fmt.Sprintf(summaryForMonitoring,
-				recordCount,
-				currNumGoroutine,
-				schedSummaryStr,
-				time.Since(startTime).String(),
-			)
-			record(0, info)
-			prevNumGoroutine = currNumGoroutine
-			prevSchedSummary = currSchedSummary
-			recordCount++
+		for {
+			//产看监控停止通知器
+			select {
+			case <-stopNotifier:
+				return
+			default:
+			}
+			//获取摘要信息的各组成部分
+			currNumGoroutine := runtime.NumGoroutine()
+			currSchedSummary := scheduler.Summary("   ")
+			if currNumGoroutine != prevNumGoroutine || !currSchedSummary.Same(prevSchedSummary) {
+				schedSummaryStr := func() string {
+					if detailSummary {
+						return currSchedSummary.Detail()
+					} else {
+						return currSchedSummary.String()
+					}
+				}()
+				//记录摘要信息
+				info := fmt.Sprintf(summaryForMonitoring,
+					recordCount,
+					currNumGoroutine,
+					schedSummaryStr,
+					time.Since(startTime).String(),
+				)
+				record(0, info)
+				prevNumGoroutine = currNumGoroutine
+				prevSchedSummary = currSchedSummary
+				recordCount++
+			}
+			time.Sleep(time.Millisecond)
 		}
-		time.Sleep(time.Millisecond)
-	}
+	}()
 }
 
 func checkStatus(
@@ -159,6 +163,8 @@ func checkStatus(
 			stopNotifier <- 2
 			checkCountChan <- checkCount
 		}()
+		//等待调度器开启
+		waitForSchedulerStart(scheduler)
 		var idleCount uint //连续空闲状态的计数
 		var firstIdleTime time.Time
 